@@ -0,0 +1,56 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grbac
+
+import (
+    "github.com/storyicon/grbac/pkg/audit"
+    "github.com/storyicon/grbac/pkg/logging"
+)
+
+// WithAudit is used to record the decisions of the controller by the auditor,
+// the auditor is not closed by the controller.
+func WithAudit(auditor *audit.Auditor) ControllerOption {
+    return func(c *Controller) error {
+        c.auditor = auditor
+        return nil
+    }
+}
+
+// Version is used to get the version of the current rules, which is the content hash of the rules
+func (c *Controller) Version() string {
+    c.rulesLock.RLock()
+    defer c.rulesLock.RUnlock()
+    return c.version
+}
+
+// audit is used to record a decision
+func (c *Controller) audit(q *Query, roles []string, decision *Decision) {
+    err := c.auditor.Record(&audit.Record{
+        Host:    q.Host,
+        Path:    q.Path,
+        Method:  q.Method,
+        // the record is written asynchronously, the roles of the caller may be reused meanwhile
+        Roles:   append([]string(nil), roles...),
+        Outcome: audit.Outcome(decision.State),
+        RuleID:  decision.RuleID(),
+        Version: decision.Version,
+    })
+    if err != nil {
+        c.logger.Error("grbac failed to record the decision",
+            logging.Err(err),
+            logging.Any("rule_id", decision.RuleID()),
+        )
+    }
+}
@@ -0,0 +1,92 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grbac
+
+import (
+    "sync/atomic"
+    "testing"
+
+    "github.com/storyicon/grbac/pkg/audit"
+    "github.com/stretchr/testify/assert"
+)
+
+type memorySink struct {
+    records []*audit.Record
+}
+
+func (s *memorySink) Write(record *audit.Record) error {
+    s.records = append(s.records, record)
+    return nil
+}
+
+func (s *memorySink) Close() error {
+    return nil
+}
+
+func TestWithAudit(t *testing.T) {
+    sink := &memorySink{}
+    auditor := audit.New(sink, audit.Options{})
+    c, err := New(WithRules(reverseRules), WithAudit(auditor))
+    assert.Equal(t, nil, err)
+    assert.Equal(t, 64, len(c.Version()))
+
+    _, err = c.IsQueryGranted(&Query{Host: "domain.com", Path: "/article/draft", Method: "PUT"}, []string{"intern"})
+    assert.Equal(t, nil, err)
+    assert.Equal(t, nil, auditor.Close())
+
+    assert.Equal(t, 1, len(sink.records))
+    record := sink.records[0]
+    assert.Equal(t, "/article/draft", record.Path)
+    assert.Equal(t, []string{"intern"}, record.Roles)
+    assert.Equal(t, audit.OutcomeUngranted, record.Outcome)
+    assert.Equal(t, 2, record.RuleID)
+    assert.Equal(t, c.Version(), record.Version)
+}
+
+func TestWithAuditReload(t *testing.T) {
+    // the rules are reloaded alternately with and without the rule 2,
+    // every record must carry the version of the rules which made the decision
+    sets := []Rules{reverseRules, reverseRules[:2]}
+    decisive := map[string]int{}
+    for i, id := range []int{2, 1} {
+        version, err := sets[i].Version()
+        assert.Equal(t, nil, err)
+        decisive[version] = id
+    }
+    var count int32
+    sink := &memorySink{}
+    auditor := audit.New(sink, audit.Options{})
+    c, err := New(WithLoader(func() (Rules, error) {
+        return sets[atomic.AddInt32(&count, 1)%2], nil
+    }, -1), WithAudit(auditor))
+    assert.Equal(t, nil, err)
+
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        for i := 0; i < 1000; i++ {
+            c.reload()
+        }
+    }()
+    for i := 0; i < 1000; i++ {
+        _, err := c.IsQueryGranted(&Query{Host: "domain.com", Path: "/article/draft", Method: "PUT"}, []string{"editor"})
+        assert.Equal(t, nil, err)
+    }
+    <-done
+    assert.Equal(t, nil, auditor.Close())
+    for _, record := range sink.records {
+        assert.Equal(t, decisive[record.Version], record.RuleID)
+    }
+}
@@ -0,0 +1,65 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grbac
+
+import (
+    "context"
+
+    "github.com/storyicon/grbac/pkg/meta"
+)
+
+// define the name of the span of a batch and the key of its size
+const (
+    SpanBatch          = "grbac.IsQueriesGranted"
+    AttributeBatchSize = "grbac.batch_size"
+)
+
+// IsQueriesGranted is used to query the permissions of many queries at once,
+// such as the actions a user may perform on a page. The states are returned in the order of the queries.
+// All the queries are evaluated against the same rules, even if they are reloaded meanwhile,
+// and the roles are preprocessed only once.
+// The batch is traced as a single span, and it is not shadowed, audited or observed,
+// since it asks what a visitor may do rather than enforcing a request.
+func (c *Controller) IsQueriesGranted(queries []*Query, roles []string) ([]PermissionState, error) {
+    return c.IsQueriesGrantedContext(context.Background(), queries, roles)
+}
+
+// IsQueriesGrantedContext is like IsQueriesGranted, the span of the batch is a child of the span of the context
+func (c *Controller) IsQueriesGrantedContext(ctx context.Context, queries []*Query, roles []string) ([]PermissionState, error) {
+    // the version of the span is read with the tree evaluating the queries
+    _, version, t := c.snapshot()
+    if c.tracer != nil {
+        var span Span
+        _, span = c.tracer.Start(ctx, SpanBatch)
+        defer span.End()
+        span.SetAttributes(
+            Attribute{Key: AttributeBatchSize, Value: len(queries)},
+            Attribute{Key: AttributeVersion, Value: version},
+        )
+    }
+    set := meta.NewRoleSet(roles)
+    states := make([]PermissionState, len(queries))
+    for i, q := range queries {
+        if q == nil {
+            return nil, ErrInvalidRequest
+        }
+        rules, err := findRules(t, q)
+        if err != nil {
+            return nil, err
+        }
+        states[i] = rules.IsRoleSetGranted(set)
+    }
+    return states, nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grbac
+
+import (
+    "context"
+    "testing"
+
+    "github.com/storyicon/grbac/pkg/meta"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestController_IsQueriesGranted(t *testing.T) {
+    c, err := New(WithRules(reverseRules[1:]))
+    assert.Equal(t, nil, err)
+
+    queries := []*Query{
+        {Host: "domain.com", Path: "/article/1", Method: "DELETE"},
+        {Host: "domain.com", Path: "/article/draft", Method: "PUT"},
+        {Host: "domain.com", Path: "/login", Method: "POST"},
+        {Host: "domain.com", Path: "/", Method: "GET"},
+    }
+    for _, roles := range [][]string{{"editor"}, {"intern", "editor"}, {"editor", "intern"}, nil} {
+        states, err := c.IsQueriesGranted(queries, roles)
+        assert.Equal(t, nil, err)
+        assert.Equal(t, len(queries), len(states))
+        for i, q := range queries {
+            want, err := c.IsQueryGranted(q, roles)
+            assert.Equal(t, nil, err)
+            assert.Equal(t, want, states[i], "%v %v", roles, q)
+        }
+    }
+
+    states, err := c.IsQueriesGranted(queries, []string{"intern", "editor"})
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []PermissionState{meta.PermissionGranted, meta.PermissionUngranted, meta.PermissionGranted, meta.PermissionNeglected}, states)
+
+    _, err = c.IsQueriesGranted([]*Query{nil}, nil)
+    assert.Equal(t, ErrInvalidRequest, err)
+}
+
+func TestIsQueriesGrantedReload(t *testing.T) {
+    sets := []Rules{reverseRules, reverseRules[:2]}
+    loads := 0
+    tracer := &recordingTracer{}
+    c, err := New(WithLoader(func() (Rules, error) {
+        loads++
+        return sets[loads%2], nil
+    }, -1), WithTracer(tracer))
+    assert.Equal(t, nil, err)
+    version := c.Version()
+
+    // the rules are reloaded when the span of the batch starts
+    tracer.onStart = func(name string) {
+        if name == SpanBatch {
+            c.reload()
+        }
+    }
+    states, err := c.IsQueriesGrantedContext(context.Background(), []*Query{
+        {Host: "domain.com", Path: "/article/draft", Method: "PUT"},
+    }, []string{"intern", "editor"})
+    assert.Equal(t, nil, err)
+    assert.NotEqual(t, version, c.Version())
+    assert.Equal(t, []PermissionState{meta.PermissionGranted}, states)
+    for _, span := range tracer.spans {
+        if span.name == SpanBatch {
+            assert.Equal(t, version, span.attributes[AttributeVersion])
+        }
+    }
+}
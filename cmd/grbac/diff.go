@@ -0,0 +1,92 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "os"
+    "strings"
+
+    jsoniter "github.com/json-iterator/go"
+    "github.com/storyicon/grbac/pkg/diff"
+    "github.com/storyicon/grbac/pkg/meta"
+)
+
+func runDiff(args []string) error {
+    flags := flag.NewFlagSet("diff", flag.ExitOnError)
+    roles := flags.String("roles", "", "comma separated roles of the requests, derived from the rules by default")
+    requests := flags.String("requests", "", "file of sample requests, one '<method> <url>' per line, derived from the rules by default")
+    failOnChange := flags.Bool("fail-on-change", false, "exit with an error when the access of a request changes")
+    flags.Parse(args)
+
+    if flags.NArg() != 2 {
+        return errors.New("usage: grbac diff [flags] <old rule file> <new rule file>")
+    }
+    old, err := readRules(flags.Arg(0))
+    if err != nil {
+        return err
+    }
+    new, err := readRules(flags.Arg(1))
+    if err != nil {
+        return err
+    }
+    var opts diff.Options
+    if *roles != "" {
+        opts.Roles = strings.Split(*roles, ",")
+    }
+    if *requests != "" {
+        f, err := os.Open(*requests)
+        if err != nil {
+            return err
+        }
+        opts.Queries, err = diff.ParseQueries(f)
+        f.Close()
+        if err != nil {
+            return err
+        }
+    }
+    report, err := diff.Compare(old, new, opts)
+    if err != nil {
+        return err
+    }
+    fmt.Printf("rules: %d changed\n", len(report.Changes))
+    for _, change := range report.Changes {
+        switch change.Kind {
+        case diff.KindAdded:
+            fmt.Printf("    + %d %s\n", change.ID, describe(change.New))
+        case diff.KindRemoved:
+            fmt.Printf("    - %d %s\n", change.ID, describe(change.Old))
+        default:
+            fmt.Printf("    ~ %d %s\n", change.ID, describe(change.Old))
+            fmt.Printf("      => %s\n", describe(change.New))
+        }
+    }
+    fmt.Printf("access: %d changed\n", len(report.Impacts))
+    for _, impact := range report.Impacts {
+        fmt.Printf("    %s\n", impact)
+    }
+    if *failOnChange && len(report.Impacts) > 0 {
+        return fmt.Errorf("the access of %d requests changed", len(report.Impacts))
+    }
+    return nil
+}
+
+// describe is used to describe a rule in a single line
+func describe(rule *meta.Rule) string {
+    s, _ := jsoniter.MarshalToString(rule)
+    return s
+}
@@ -0,0 +1,141 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "strings"
+
+    "github.com/storyicon/grbac/pkg/casbin"
+    "github.com/storyicon/grbac/pkg/loader"
+    "github.com/storyicon/grbac/pkg/meta"
+    "github.com/storyicon/grbac/pkg/openapi"
+    "gopkg.in/yaml.v3"
+)
+
+// importers defines the sources supported by grbac import
+var importers = map[string]func(args []string) error{
+    "openapi": runImportOpenAPI,
+    "casbin":  runImportCasbin,
+}
+
+func runImport(args []string) error {
+    if len(args) == 0 || importers[args[0]] == nil {
+        return errors.New("usage: grbac import openapi|casbin [flags] <file>")
+    }
+    return importers[args[0]](args[1:])
+}
+
+func runImportOpenAPI(args []string) error {
+    flags := flag.NewFlagSet("import openapi", flag.ExitOnError)
+    out := flags.String("out", "", "output rule file, defaults to stdout")
+    format := flags.String("format", "", "format of the output, json, yaml or toml, detected by the extension of -out by default")
+    host := flags.String("host", "*", "host of the generated rules")
+    basePath := flags.String("base-path", "", "path prefix of the generated rules, defaults to the path of the first server")
+    extension := flags.String("extension", openapi.DefaultExtension, "vendor extension holding the roles")
+    defaultRoles := flags.String("default-roles", "", "comma separated roles authorized to the operations without authorization metadata")
+    firstID := flags.Int("first-id", 0, "id of the first generated rule")
+    failOnMissing := flags.Bool("fail-on-missing", false, "fail when some operations have no authorization metadata")
+    flags.Parse(args)
+
+    if flags.NArg() != 1 {
+        return errors.New("usage: grbac import openapi [flags] <file>")
+    }
+    opts := &openapi.Options{
+        Host:      *host,
+        BasePath:  *basePath,
+        Extension: *extension,
+        FirstID:   *firstID,
+    }
+    if *defaultRoles != "" {
+        opts.DefaultRoles = strings.Split(*defaultRoles, ",")
+    }
+    report, err := openapi.ImportFile(flags.Arg(0), opts)
+    if err != nil {
+        return err
+    }
+    for _, op := range report.Missing {
+        fmt.Fprintf(os.Stderr, "missing authorization metadata: %s\n", op)
+    }
+    for _, op := range report.Unsupported {
+        fmt.Fprintf(os.Stderr, "unsupported: %s: %s\n", op, openapi.ErrUnsupportedSecurity)
+    }
+    if *failOnMissing && len(report.Missing) > 0 {
+        return fmt.Errorf("%d operations have no authorization metadata", len(report.Missing))
+    }
+    return writeRules(*out, *format, report.Rules)
+}
+
+func runImportCasbin(args []string) error {
+    flags := flag.NewFlagSet("import casbin", flag.ExitOnError)
+    out := flags.String("out", "", "output rule file, defaults to stdout")
+    format := flags.String("format", "", "format of the output, json, yaml or toml, detected by the extension of -out by default")
+    host := flags.String("host", "*", "host of the generated rules")
+    firstID := flags.Int("first-id", 0, "id of the first generated rule")
+    rolesOut := flags.String("roles-out", "", "output yaml file of the role hierarchy built from the g lines")
+    flags.Parse(args)
+
+    if flags.NArg() != 1 {
+        return errors.New("usage: grbac import casbin [flags] <policy.csv>")
+    }
+    report, err := casbin.ImportFile(flags.Arg(0), &casbin.Options{
+        Host:    *host,
+        FirstID: *firstID,
+    })
+    if err != nil {
+        return err
+    }
+    for _, issue := range report.Issues {
+        fmt.Fprintf(os.Stderr, "unsupported: %s\n", issue)
+    }
+    if *rolesOut != "" {
+        data, err := yaml.Marshal(report.Hierarchy)
+        if err != nil {
+            return err
+        }
+        if err := ioutil.WriteFile(*rolesOut, data, 0644); err != nil {
+            return err
+        }
+    }
+    return writeRules(*out, *format, report.Rules)
+}
+
+// writeRules is used to write rules to a file or stdout,
+// the format defaults to the extension of the file, or yaml for stdout.
+func writeRules(out string, format string, rules meta.Rules) error {
+    if format == "" {
+        format = loader.FormatYAML
+        if out != "" {
+            detected, err := loader.DetectFormat(out)
+            if err != nil {
+                return err
+            }
+            format = detected
+        }
+    }
+    data, err := loader.Marshal(format, rules)
+    if err != nil {
+        return err
+    }
+    if out == "" {
+        _, err = os.Stdout.Write(data)
+        return err
+    }
+    return ioutil.WriteFile(out, data, 0644)
+}
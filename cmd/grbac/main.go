@@ -0,0 +1,89 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command grbac provides tools to work with grbac policies.
+package main
+
+import (
+    "fmt"
+    "os"
+    "sort"
+)
+
+// command defines a sub command of grbac
+type command struct {
+    usage string
+    run   func(args []string) error
+}
+
+var commands = map[string]*command{
+    "diff": {
+        usage: "report the access changed between two rule files",
+        run:   runDiff,
+    },
+    "import": {
+        usage: "import rules from an OpenAPI 3 document or a Casbin policy",
+        run:   runImport,
+    },
+    "matrix": {
+        usage: "render the access of roles to the endpoints of a rule file",
+        run:   runMatrix,
+    },
+    "replay": {
+        usage: "replay access logs against the current and a new rule file",
+        run:   runReplay,
+    },
+    "serve": {
+        usage: "serve the decisions of a rule file over a JSON API",
+        run:   runServe,
+    },
+    "sign": {
+        usage: "sign a rule file into a signed bundle",
+        run:   runSign,
+    },
+    "verify": {
+        usage: "verify a signed bundle",
+        run:   runVerify,
+    },
+}
+
+func usage() {
+    fmt.Fprintln(os.Stderr, "usage: grbac <command> [arguments]")
+    fmt.Fprintln(os.Stderr)
+    fmt.Fprintln(os.Stderr, "commands:")
+    var names []string
+    for name := range commands {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        fmt.Fprintf(os.Stderr, "    %-10s %s\n", name, commands[name].usage)
+    }
+}
+
+func main() {
+    if len(os.Args) < 2 {
+        usage()
+        os.Exit(2)
+    }
+    cmd, ok := commands[os.Args[1]]
+    if !ok {
+        usage()
+        os.Exit(2)
+    }
+    if err := cmd.run(os.Args[2:]); err != nil {
+        fmt.Fprintf(os.Stderr, "grbac %s: %s\n", os.Args[1], err)
+        os.Exit(1)
+    }
+}
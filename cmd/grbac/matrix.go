@@ -0,0 +1,73 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+    "errors"
+    "flag"
+    "io/ioutil"
+    "os"
+    "strings"
+
+    "github.com/storyicon/grbac/pkg/loader"
+    "github.com/storyicon/grbac/pkg/matrix"
+    "github.com/storyicon/grbac/pkg/meta"
+)
+
+func runMatrix(args []string) error {
+    flags := flag.NewFlagSet("matrix", flag.ExitOnError)
+    roles := flags.String("roles", "", "comma separated roles of the columns, derived from the rules by default")
+    format := flags.String("format", matrix.FormatMarkdown, "format of the matrix, csv, markdown or html")
+    out := flags.String("out", "", "output file, defaults to stdout")
+    flags.Parse(args)
+
+    if flags.NArg() != 1 {
+        return errors.New("usage: grbac matrix [flags] <rule file>")
+    }
+    rules, err := readRules(flags.Arg(0))
+    if err != nil {
+        return err
+    }
+    var columns []string
+    if *roles != "" {
+        columns = strings.Split(*roles, ",")
+    }
+    m, err := matrix.New(rules, columns)
+    if err != nil {
+        return err
+    }
+    w := os.Stdout
+    if *out != "" {
+        w, err = os.Create(*out)
+        if err != nil {
+            return err
+        }
+        defer w.Close()
+    }
+    return m.Render(w, *format)
+}
+
+// readRules is used to read a rule file whose format is detected by its extension
+func readRules(file string) (meta.Rules, error) {
+    format, err := loader.DetectFormat(file)
+    if err != nil {
+        return nil, err
+    }
+    data, err := ioutil.ReadFile(file)
+    if err != nil {
+        return nil, err
+    }
+    return loader.Decode(format, file, data, loader.Strict())
+}
@@ -0,0 +1,104 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+
+    "github.com/storyicon/grbac"
+    "github.com/storyicon/grbac/pkg/replay"
+)
+
+func runReplay(args []string) error {
+    flags := flag.NewFlagSet("replay", flag.ExitOnError)
+    oldFile := flags.String("old", "", "rule file of the current policy")
+    newFile := flags.String("new", "", "rule file of the new policy")
+    format := flags.String("format", replay.FormatAuto, "format of the access logs, common or json, detected by line by default")
+    host := flags.String("host", "", "host of the requests whose log does not record it")
+    limit := flags.Int("limit", 20, "maximum of changed requests printed, 0 means no limit")
+    failOnChange := flags.Bool("fail-on-change", false, "exit with an error when a decision changes")
+    flags.Parse(args)
+
+    if *oldFile == "" || *newFile == "" {
+        return errors.New("usage: grbac replay -old <rule file> -new <rule file> [flags] [access log...]")
+    }
+    old, err := newController(*oldFile)
+    if err != nil {
+        return err
+    }
+    new, err := newController(*newFile)
+    if err != nil {
+        return err
+    }
+    var readers []io.Reader
+    for _, name := range flags.Args() {
+        f, err := os.Open(name)
+        if err != nil {
+            return err
+        }
+        defer f.Close()
+        readers = append(readers, f)
+    }
+    if len(readers) == 0 {
+        readers = append(readers, os.Stdin)
+    }
+    report, err := replay.Replay(io.MultiReader(readers...), old, new, replay.Options{
+        Format: *format,
+        Host:   *host,
+        Limit:  *limit,
+    })
+    if err != nil {
+        return err
+    }
+    for _, issue := range report.Skipped {
+        fmt.Fprintf(os.Stderr, "line %d skipped: %s\n", issue.Line, issue.Reason)
+    }
+    fmt.Printf("requests: %d, changed: %d\n", report.Total, report.Changed)
+    for _, change := range report.Changes {
+        fmt.Printf("    line %d: %s %s%s [%s] rule %d %s -> rule %d %s\n",
+            change.Line, change.Query.Method, change.Query.Host, change.Query.Path, strings.Join(change.Roles, ","),
+            change.Old.RuleID(), change.Old.State, change.New.RuleID(), change.New.State)
+    }
+    fmt.Println("rules:")
+    for _, stat := range report.Rules() {
+        if stat.Changed > 0 {
+            fmt.Printf("    %d: %d/%d changed\n", stat.RuleID, stat.Changed, stat.Total)
+        }
+    }
+    fmt.Println("routes:")
+    for _, stat := range report.Routes() {
+        if stat.Changed > 0 {
+            fmt.Printf("    %s: %d/%d changed\n", stat.Route, stat.Changed, stat.Total)
+        }
+    }
+    if *failOnChange && report.Changed > 0 {
+        return fmt.Errorf("%d decisions changed", report.Changed)
+    }
+    return nil
+}
+
+// newController is used to create a controller of a rule file which is never reloaded
+func newController(file string) (*grbac.Controller, error) {
+    rules, err := readRules(file)
+    if err != nil {
+        return nil, err
+    }
+    return grbac.New(grbac.WithRules(rules))
+}
@@ -0,0 +1,246 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+    "context"
+    "crypto/tls"
+    "crypto/x509"
+    "errors"
+    "flag"
+    "fmt"
+    "io/ioutil"
+    "net/http"
+    "os"
+    "os/signal"
+    "strings"
+    "syscall"
+    "time"
+
+    "github.com/storyicon/grbac"
+    "github.com/storyicon/grbac/pkg/extractor"
+    "github.com/storyicon/grbac/pkg/loader"
+    "github.com/storyicon/grbac/pkg/server"
+)
+
+// mappings defines the repeatable -cert-role flag, such as uri:spiffe://domain.com/ns/*/sa/web=web,service
+type mappings []*extractor.Mapping
+
+// String is used to print the mappings
+func (m *mappings) String() string {
+    var values []string
+    for _, mapping := range *m {
+        values = append(values, fmt.Sprintf("%s:%s=%s", mapping.Source, mapping.Pattern, strings.Join(mapping.Roles, ",")))
+    }
+    return strings.Join(values, " ")
+}
+
+// Set is used to parse a mapping
+func (m *mappings) Set(value string) error {
+    colon := strings.Index(value, ":")
+    equal := strings.LastIndex(value, "=")
+    if colon <= 0 || equal < colon {
+        return errors.New("the format of a mapping is source:pattern=role1,role2")
+    }
+    *m = append(*m, &extractor.Mapping{
+        Source:  extractor.Source(strings.ToLower(value[:colon])),
+        Pattern: value[colon+1 : equal],
+        Roles:   extractor.Split(value[equal+1:], ","),
+    })
+    return nil
+}
+
+func runServe(args []string) error {
+    flags := flag.NewFlagSet("serve", flag.ExitOnError)
+    addr := flags.String("addr", ":8080", "address to listen on")
+    rules := flags.String("rules", "", "rule file, the format is detected by its extension")
+    kubernetes := flags.Bool("kubernetes", false, "load the rule file as Kubernetes RBAC objects")
+    strict := flags.Bool("strict", false, "reject unknown fields of the rule file")
+    expand := flags.Bool("expand", false, "expand the ${VARIABLES} of the json or yaml rule file")
+    interval := flags.Duration("interval", time.Minute, "reload interval of the rule file, negative to disable")
+    maxBody := flags.Int64("max-body", server.DefaultMaxBodyBytes, "maximum size of a request body in bytes")
+    maxBatch := flags.Int("max-batch", server.DefaultMaxBatch, "maximum number of queries of a batch")
+    timeout := flags.Duration("timeout", 10*time.Second, "read and write timeout of the requests")
+    authPath := flags.String("auth-path", "/auth", "path of the nginx auth_request and Traefik forwardAuth endpoint")
+    authProxy := flags.String("auth-proxy", "", "proxy calling -auth-path, nginx or traefik, whose headers of the original request are read, empty to disable")
+    extAuthzPath := flags.String("ext-authz-path", "/ext_authz", "path_prefix of the Envoy ext_authz http_service, empty to disable")
+    rolesHeader := flags.String("roles-header", "", "header of the comma separated roles set by a trusted proxy, such as X-Roles")
+    jwks := flags.String("jwks", "", "JWK Set file verifying the bearer tokens, whose claims give the roles")
+    jwtIssuer := flags.String("jwt-issuer", "", "required iss claim of the bearer tokens")
+    jwtAudience := flags.String("jwt-audience", "", "comma separated accepted aud claims of the bearer tokens")
+    jwtRequireExp := flags.Bool("jwt-require-exp", false, "reject the bearer tokens without exp claim, which are valid forever otherwise")
+    jwtClaim := flags.String("jwt-claim", extractor.DefaultJWTClaim, "path of the roles claim of the bearer tokens, such as realm_access.roles or scope")
+    tlsCert := flags.String("tls-cert", "", "certificate file of the server, which serves TLS when set")
+    tlsKey := flags.String("tls-key", "", "private key file of the server certificate")
+    clientCA := flags.String("client-ca", "", "CA file verifying the client certificates of the connections to grbac, such as the proxy's one")
+    certHeader := flags.String("cert-header", "", "header of the client certificate forwarded by the proxy, "+
+        "such as x-forwarded-client-cert of Envoy, or a header set to $ssl_client_escaped_cert by nginx")
+    certHeaderCA := flags.String("cert-header-ca", "", "CA file verifying the forwarded client certificates, "+
+        "which are trusted as verified by the proxy authenticated by -client-ca otherwise")
+    var certRoles mappings
+    flags.Var(&certRoles, "cert-role", "roles of the forwarded client certificates with an identity matching the pattern, "+
+        "such as uri:spiffe://domain.com/ns/*/sa/web=web,service, the sources are uri, dns, cn and ou, repeatable")
+    shutdownTimeout := flags.Duration("shutdown-timeout", 10*time.Second, "time to wait for the requests in flight on shutdown")
+    flags.Parse(args)
+
+    if *rules == "" {
+        return errors.New("usage: grbac serve -rules <rule file> [flags]")
+    }
+    if (*tlsCert == "") != (*tlsKey == "") || (*clientCA != "" && *tlsCert == "") {
+        return errors.New("-tls-cert and -tls-key are required together, and by -client-ca")
+    }
+    // /auth and /ext_authz are called by the proxy, whose certificate is not the client's one
+    if len(certRoles) != 0 && *certHeader == "" {
+        return errors.New("-cert-role requires -cert-header")
+    }
+    // anyone can set the header, so either the forwarded certificates or the proxy must be verified
+    if *certHeader != "" && *certHeaderCA == "" && *clientCA == "" {
+        return errors.New("-cert-header requires -cert-header-ca or -client-ca")
+    }
+    var opts []loader.Option
+    if *strict {
+        opts = append(opts, loader.Strict())
+    }
+    if *expand {
+        opts = append(opts, loader.Expand())
+    }
+    option := grbac.WithFile(*rules, *interval, opts...)
+    if *kubernetes {
+        option = grbac.WithKubernetes(*rules, *interval, opts...)
+    }
+    controller, err := grbac.New(option)
+    if err != nil {
+        return err
+    }
+
+    mux := http.NewServeMux()
+    mux.Handle("/", server.New(controller, server.Options{
+        MaxBodyBytes: *maxBody,
+        MaxBatch:     *maxBatch,
+    }))
+    var roles extractor.RoleExtractor
+    var remove []string
+    var sources int
+    for _, configured := range []bool{*jwks != "", *rolesHeader != "", len(certRoles) != 0} {
+        if configured {
+            sources++
+        }
+    }
+    switch {
+    case sources > 1:
+        return errors.New("-jwks, -roles-header and -cert-role are exclusive")
+    case len(certRoles) != 0:
+        opts := extractor.CertificateOptions{
+            Mappings: certRoles,
+            Header:   *certHeader,
+        }
+        if *certHeaderCA != "" {
+            opts.Roots, err = loadCertPool(*certHeaderCA)
+            if err != nil {
+                return err
+            }
+        }
+        roles, err = extractor.NewCertificate(opts)
+        if err != nil {
+            return err
+        }
+    case *jwks != "":
+        roles, err = extractor.NewJWT(extractor.JWTOptions{
+            JWKS:              *jwks,
+            Issuer:            *jwtIssuer,
+            Audience:          extractor.Split(*jwtAudience, ","),
+            Claim:             *jwtClaim,
+            RequireExpiration: *jwtRequireExp,
+        })
+        if err != nil {
+            return err
+        }
+    case *rolesHeader != "":
+        roles = extractor.Header(*rolesHeader)
+        remove = []string{*rolesHeader}
+    }
+    if *authProxy != "" {
+        auth, err := server.NewForwardAuth(controller, server.ForwardAuthOptions{
+            Proxy:     server.Proxy(*authProxy),
+            Extractor: roles,
+        })
+        if err != nil {
+            return err
+        }
+        mux.Handle(*authPath, auth)
+    }
+    if *extAuthzPath != "" {
+        mux.Handle(strings.TrimSuffix(*extAuthzPath, "/")+"/", server.NewExtAuthz(controller, server.ExtAuthzOptions{
+            Extractor:       roles,
+            PathPrefix:      *extAuthzPath,
+            HeadersToRemove: remove,
+        }))
+    }
+    srv := &http.Server{
+        Addr:           *addr,
+        Handler:        mux,
+        ReadTimeout:    *timeout,
+        WriteTimeout:   *timeout,
+        MaxHeaderBytes: 64 << 10,
+    }
+    if *clientCA != "" {
+        pool, err := loadCertPool(*clientCA)
+        if err != nil {
+            return err
+        }
+        srv.TLSConfig = &tls.Config{
+            ClientCAs:  pool,
+            ClientAuth: tls.VerifyClientCertIfGiven,
+        }
+        // the forwarded certificates are trusted as verified by the proxy, which must be authenticated
+        if *certHeader != "" && *certHeaderCA == "" {
+            srv.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+        }
+    }
+    errs := make(chan error, 1)
+    go func() {
+        if *tlsCert != "" {
+            errs <- srv.ListenAndServeTLS(*tlsCert, *tlsKey)
+            return
+        }
+        errs <- srv.ListenAndServe()
+    }()
+    fmt.Fprintf(os.Stderr, "grbac serve: listening on %s\n", *addr)
+
+    signals := make(chan os.Signal, 1)
+    signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+    select {
+    case err := <-errs:
+        return err
+    case sig := <-signals:
+        fmt.Fprintf(os.Stderr, "grbac serve: %s received, shutting down\n", sig)
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+    defer cancel()
+    return srv.Shutdown(ctx)
+}
+
+// loadCertPool is used to load the certificates of a PEM file into a pool
+func loadCertPool(name string) (*x509.CertPool, error) {
+    data, err := ioutil.ReadFile(name)
+    if err != nil {
+        return nil, err
+    }
+    pool := x509.NewCertPool()
+    if !pool.AppendCertsFromPEM(data) {
+        return nil, fmt.Errorf("no certificate found in %s", name)
+    }
+    return pool, nil
+}
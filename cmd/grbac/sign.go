@@ -0,0 +1,111 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "io/ioutil"
+    "strings"
+    "time"
+
+    "github.com/storyicon/grbac/pkg/bundle"
+    "github.com/storyicon/grbac/pkg/loader"
+)
+
+func runSign(args []string) error {
+    flags := flag.NewFlagSet("sign", flag.ExitOnError)
+    key := flags.String("key", "", "PEM encoded Ed25519 or ECDSA private key")
+    in := flags.String("in", "", "rule file to sign")
+    out := flags.String("out", "", "output bundle file, defaults to <in>.bundle")
+    format := flags.String("format", "", "format of the rule file, json, yaml, toml or hcl, detected by extension by default")
+    version := flags.String("version", "", "version label of the policy")
+    keyID := flags.String("key-id", "", "key id, defaults to the fingerprint of the public key")
+    ttl := flags.Duration("ttl", 30*24*time.Hour, "validity period of the bundle")
+    flags.Parse(args)
+
+    if *key == "" || *in == "" {
+        return errors.New("-key and -in are required")
+    }
+    if *format == "" {
+        detected, err := loader.DetectFormat(*in)
+        if err != nil {
+            return err
+        }
+        *format = detected
+    }
+    if *out == "" {
+        *out = *in + ".bundle"
+    }
+
+    pem, err := ioutil.ReadFile(*key)
+    if err != nil {
+        return err
+    }
+    signer, err := bundle.ParsePrivateKey(pem)
+    if err != nil {
+        return err
+    }
+    rules, err := ioutil.ReadFile(*in)
+    if err != nil {
+        return err
+    }
+    if _, err := loader.Decode(*format, *in, rules, loader.Strict()); err != nil {
+        return err
+    }
+    b, err := bundle.Sign(rules, signer, bundle.SignOptions{
+        Version: *version,
+        Format:  *format,
+        KeyID:   *keyID,
+        TTL:     *ttl,
+    })
+    if err != nil {
+        return err
+    }
+    data, err := b.Encode()
+    if err != nil {
+        return err
+    }
+    return ioutil.WriteFile(*out, data, 0644)
+}
+
+func runVerify(args []string) error {
+    flags := flag.NewFlagSet("verify", flag.ExitOnError)
+    keys := flags.String("keys", "", "comma separated list of PEM encoded public keys, id=pub.pem registers a key under the -key-id it signed with")
+    flags.Parse(args)
+
+    if *keys == "" || flags.NArg() != 1 {
+        return errors.New("usage: grbac verify -keys [<id>=]<pub.pem>[,[<id>=]<pub.pem>...] <bundle>")
+    }
+    ring, err := bundle.LoadKeyRing(strings.Split(*keys, ",")...)
+    if err != nil {
+        return err
+    }
+    data, err := ioutil.ReadFile(flags.Arg(0))
+    if err != nil {
+        return err
+    }
+    b, err := bundle.Decode(data)
+    if err != nil {
+        return err
+    }
+    if _, err := b.Verify(ring, time.Now()); err != nil {
+        return err
+    }
+    fmt.Printf("version=%q key_id=%s expires_at=%s\n",
+        b.Manifest.Version, b.Manifest.KeyID, b.Manifest.ExpiresAt.Format(time.RFC3339))
+    return nil
+}
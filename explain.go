@@ -0,0 +1,64 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grbac
+
+import (
+    "context"
+)
+
+// Decision defines the result of a query and the rules leading to it
+type Decision struct {
+    State PermissionState
+    // Rule is the rule deciding the state, nil when no rule matches the query
+    Rule *Rule
+    // Matched are all the rules matching the query
+    Matched Rules
+    // Version is the version of the rules making the decision
+    Version string
+}
+
+// RuleID is used to get the ID of the decisive rule, -1 when no rule matches the query
+func (d *Decision) RuleID() int {
+    if d.Rule == nil {
+        return -1
+    }
+    return d.Rule.ID
+}
+
+// Explain is used to query the permission like IsQueryGranted does,
+// and report the rule deciding the permission.
+func (c *Controller) Explain(q *Query, roles []string) (*Decision, error) {
+    return c.explain(context.Background(), q, roles)
+}
+
+// explain is used to explain the query, the tree lookup is traced.
+// The tree and the version are read together, so that the decision reports the version of the rules making it.
+func (c *Controller) explain(ctx context.Context, q *Query, roles []string) (*Decision, error) {
+    _, version, t := c.snapshot()
+    rules, err := c.lookup(ctx, t, q)
+    if err != nil {
+        return nil, err
+    }
+    state, err := rules.IsRolesGranted(roles)
+    if err != nil {
+        return nil, err
+    }
+    return &Decision{
+        State:   state,
+        Rule:    rules.GetDecisiveRule(),
+        Matched: rules,
+        Version: version,
+    }, nil
+}
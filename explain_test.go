@@ -0,0 +1,40 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grbac
+
+import (
+    "testing"
+
+    "github.com/storyicon/grbac/pkg/meta"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestController_Explain(t *testing.T) {
+    c, err := New(WithRules(reverseRules))
+    assert.Equal(t, nil, err)
+
+    decision, err := c.Explain(&Query{Host: "domain.com", Path: "/article/draft", Method: "PUT"}, []string{"intern", "editor"})
+    assert.Equal(t, nil, err)
+    assert.Equal(t, meta.PermissionUngranted, decision.State)
+    assert.Equal(t, 2, decision.RuleID())
+    assert.Equal(t, 3, len(decision.Matched))
+
+    c, err = New(WithRules(reverseRules[1:]))
+    assert.Equal(t, nil, err)
+    decision, err = c.Explain(&Query{Host: "domain.com", Path: "/", Method: "GET"}, []string{"editor"})
+    assert.Equal(t, nil, err)
+    assert.Equal(t, meta.PermissionNeglected, decision.State)
+    assert.Equal(t, -1, decision.RuleID())
+}
@@ -3,9 +3,11 @@ module github.com/storyicon/grbac
 go 1.12
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/bxcodec/faker/v3 v3.1.0
 	github.com/hashicorp/go-immutable-radix v1.1.0
 	github.com/hashicorp/go-multierror v1.0.0
+	github.com/hashicorp/hcl v1.0.0
 	github.com/json-iterator/go v1.1.6
 	github.com/kr/pretty v0.1.0 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
@@ -15,13 +15,17 @@
 package grbac
 
 import (
+    "context"
     "errors"
     "net/http"
     "sync"
     "time"
 
     "github.com/sirupsen/logrus"
+    "github.com/storyicon/grbac/pkg/audit"
+    "github.com/storyicon/grbac/pkg/bundle"
     "github.com/storyicon/grbac/pkg/loader"
+    "github.com/storyicon/grbac/pkg/logging"
     "github.com/storyicon/grbac/pkg/meta"
     "github.com/storyicon/grbac/pkg/tree"
 )
@@ -39,21 +43,31 @@ type Controller struct {
     loadInterval time.Duration
 
     rules     Rules
+    version   string
     rulesLock sync.RWMutex
 
     tree     *tree.Tree
     treeLock sync.RWMutex
 
-    logger *logrus.Logger
+    status     Status
+    statusLock sync.RWMutex
+
+    logger Logger
+
+    shadow   *shadow
+    auditor  *audit.Auditor
+    observer Observer
+    tracer   Tracer
 }
 
 // ControllerOption provides an interface for user to define controller.
 type ControllerOption func(*Controller) error
 
 // WithJSON is used to load configuration via json file
-func WithJSON(name string, loadInterval time.Duration) ControllerOption {
+// The file loaders can be customized by loader.Option, such as loader.Strict()
+func WithJSON(name string, loadInterval time.Duration, opts ...loader.Option) ControllerOption {
     return func(c *Controller) error {
-        fd, err := loader.NewJSONLoader(name)
+        fd, err := loader.NewJSONLoader(name, opts...)
         if err != nil {
             return err
         }
@@ -64,9 +78,77 @@ func WithJSON(name string, loadInterval time.Duration) ControllerOption {
 }
 
 // WithYAML is used to load configuration via yaml file
-func WithYAML(name string, loadInterval time.Duration) ControllerOption {
+func WithYAML(name string, loadInterval time.Duration, opts ...loader.Option) ControllerOption {
+    return func(c *Controller) error {
+        fd, err := loader.NewYAMLLoader(name, opts...)
+        if err != nil {
+            return err
+        }
+        c.loader = fd.Load
+        c.loadInterval = loadInterval
+        return nil
+    }
+}
+
+// WithTOML is used to load configuration via toml file
+func WithTOML(name string, loadInterval time.Duration, opts ...loader.Option) ControllerOption {
+    return func(c *Controller) error {
+        fd, err := loader.NewTOMLLoader(name, opts...)
+        if err != nil {
+            return err
+        }
+        c.loader = fd.Load
+        c.loadInterval = loadInterval
+        return nil
+    }
+}
+
+// WithHCL is used to load configuration via hcl file
+func WithHCL(name string, loadInterval time.Duration, opts ...loader.Option) ControllerOption {
     return func(c *Controller) error {
-        fd, err := loader.NewYAMLLoader(name)
+        fd, err := loader.NewHCLLoader(name, opts...)
+        if err != nil {
+            return err
+        }
+        c.loader = fd.Load
+        c.loadInterval = loadInterval
+        return nil
+    }
+}
+
+// WithFile is used to load configuration via file,
+// the format of the file is detected by its extension(.json, .yaml, .yml, .toml, .hcl).
+func WithFile(name string, loadInterval time.Duration, opts ...loader.Option) ControllerOption {
+    return func(c *Controller) error {
+        fd, err := loader.NewFileLoader(name, opts...)
+        if err != nil {
+            return err
+        }
+        c.loader = fd.Load
+        c.loadInterval = loadInterval
+        return nil
+    }
+}
+
+// WithKubernetes is used to load configuration via yaml file of Kubernetes RBAC objects
+// The nonResourceURLs of Roles and ClusterRoles become the rules, and the subjects of their bindings become the roles.
+func WithKubernetes(name string, loadInterval time.Duration, opts ...loader.Option) ControllerOption {
+    return func(c *Controller) error {
+        fd, err := loader.NewKubernetesLoader(name, opts...)
+        if err != nil {
+            return err
+        }
+        c.loader = fd.Load
+        c.loadInterval = loadInterval
+        return nil
+    }
+}
+
+// WithBundle is used to load configuration via signed bundle file
+// Bundles that are unsigned, expired or not signed by one of the keys are rejected.
+func WithBundle(name string, keys bundle.KeyRing, loadInterval time.Duration, opts ...loader.Option) ControllerOption {
+    return func(c *Controller) error {
+        fd, err := loader.NewBundleLoader(name, keys, opts...)
         if err != nil {
             return err
         }
@@ -114,10 +196,24 @@ func WithLoader(loader func() (Rules, error), loadInterval time.Duration) Contro
     }
 }
 
+// Logger defines the structured logger of the controller, see the adapters of the logging package
+type Logger = logging.Logger
+
+// WithLogger is used to replace the default logger created by logging.Default,
+// as an option of New, the initial loading of the rules is logged by it as well.
+func WithLogger(logger Logger) ControllerOption {
+    return func(c *Controller) error {
+        if logger != nil {
+            c.logger = logger
+        }
+        return nil
+    }
+}
+
 // New is used to initialize an RBAC instance
 func New(loaderOptions ControllerOption, options ...ControllerOption) (*Controller, error) {
     c := &Controller{
-        logger: logrus.New(),
+        logger: logging.Default(),
     }
 
     opts := append([]ControllerOption{loaderOptions}, options...)
@@ -137,19 +233,65 @@ func New(loaderOptions ControllerOption, options ...ControllerOption) (*Controll
         return nil, err
     }
 
+    // the shadow is started once the primary rules are loaded, so that no goroutine is left behind on failure
+    if c.shadow != nil {
+        if err := c.shadow.start(c.logger); err != nil {
+            return nil, err
+        }
+    }
+
     go c.runCronTab()
 
     return c, nil
 }
 
 // SetLogger is used to modify the default logger
+//
+// Deprecated: use WithLogger with logging.NewLogrus, which also logs the initial loading of the rules.
 func (c *Controller) SetLogger(logger *logrus.Logger) {
     if logger != nil {
-        c.logger = logger
+        c.logger = logging.NewLogrus(logger)
     }
 }
 
 func (c *Controller) reload() error {
+    var span Span
+    if c.tracer != nil {
+        _, span = c.tracer.Start(context.Background(), SpanReload)
+        defer span.End()
+    }
+    start := time.Now()
+    err := c.load()
+    c.setStatus(start, err)
+    rules := len(c.getRules())
+    if span != nil {
+        span.SetAttributes(
+            Attribute{Key: AttributeRules, Value: rules},
+            Attribute{Key: AttributeVersion, Value: c.Version()},
+        )
+        if err != nil {
+            span.RecordError(err)
+        }
+    }
+    if c.observer != nil {
+        c.observer.ObserveReload(rules, err)
+    }
+    if err != nil {
+        c.logger.Error("grbac failed to load the rules",
+            logging.Err(err),
+            logging.Any("duration", time.Since(start)),
+        )
+        return err
+    }
+    c.logger.Debug("grbac loaded the rules",
+        logging.Any("rules", rules),
+        logging.Any("version", c.Version()),
+        logging.Any("duration", time.Since(start)),
+    )
+    return nil
+}
+
+func (c *Controller) load() error {
     if c.loader == nil {
         return ErrUndefinedLoader
     }
@@ -164,29 +306,30 @@ func (c *Controller) reload() error {
         return err
     }
 
-    c.rulesLock.Lock()
-    c.rules = rules
-    c.rulesLock.Unlock()
-
-    err = c.buildTree()
+    version, err := rules.Version()
     if err != nil {
         return err
     }
 
+    t := buildTree(rules)
+    // the rules and the tree are swapped together, so that snapshot never sees them apart
+    c.rulesLock.Lock()
+    c.treeLock.Lock()
+    c.rules = rules
+    c.version = version
+    c.tree = t
+    c.treeLock.Unlock()
+    c.rulesLock.Unlock()
     return nil
 }
 
-func (c *Controller) buildTree() error {
+// buildTree is used to build the tree of the rules
+func buildTree(rules Rules) *tree.Tree {
     t := tree.NewTree()
-    c.rulesLock.RLock()
-    defer c.rulesLock.RUnlock()
-    for _, rule := range c.rules {
+    for _, rule := range rules {
         t.Insert(rule.GetArguments(), rule)
     }
-    c.treeLock.Lock()
-    c.tree = t
-    c.treeLock.Unlock()
-    return nil
+    return t
 }
 
 func (c *Controller) runCronTab() {
@@ -194,7 +337,9 @@ func (c *Controller) runCronTab() {
         c.loadInterval = 5 * time.Second
     }
     if c.loadInterval < 0 {
-        c.logger.Warning("grbac abandoned the periodic loader because loadInterval is less than 0")
+        c.logger.Warn("grbac abandoned the periodic loader because loadInterval is less than 0",
+            logging.Any("interval", c.loadInterval),
+        )
         return
     }
 
@@ -203,11 +348,8 @@ func (c *Controller) runCronTab() {
     for {
         select {
         case <-ticker.C:
-            c.logger.Debugln("grbac loader is scheduled")
-            err := c.reload()
-            if err != nil {
-                c.logger.Errorln("error occurred while loading the configuration in grbac: ", err)
-            }
+            c.logger.Debug("grbac loader is scheduled")
+            c.reload()
         }
     }
 }
@@ -223,10 +365,27 @@ func getQueryByRequest(r *http.Request) *Query {
     }
 }
 
-func (c *Controller) find(query *Query) (Rules, error) {
+// getTree is used to get the current tree, which is never modified once it is built
+func (c *Controller) getTree() *tree.Tree {
     c.treeLock.RLock()
     defer c.treeLock.RUnlock()
-    records, err := c.tree.Query(query.GetArguments())
+    return c.tree
+}
+
+// snapshot is used to get the current rules, their version and the tree built from them
+func (c *Controller) snapshot() (Rules, string, *tree.Tree) {
+    c.rulesLock.RLock()
+    defer c.rulesLock.RUnlock()
+    return c.rules, c.version, c.getTree()
+}
+
+func (c *Controller) find(query *Query) (Rules, error) {
+    return findRules(c.getTree(), query)
+}
+
+// findRules is used to find the rules matching the query in the tree
+func findRules(t *tree.Tree, query *Query) (Rules, error) {
+    records, err := t.Query(query.GetArguments())
     if err != nil {
         return nil, err
     }
@@ -243,20 +402,74 @@ func (c *Controller) find(query *Query) (Rules, error) {
 
 // IsRequestGranted is used to verify whether a request has permission.
 // * The parameter roles is the role of the current user.
+// * The context of the request is passed to the tracer configured by WithTracer.
 func (c *Controller) IsRequestGranted(r *http.Request, roles []string) (PermissionState, error) {
     query := getQueryByRequest(r)
     if query == nil {
         return meta.PermissionUnknown, ErrInvalidRequest
     }
-    return c.IsQueryGranted(query, roles)
+    return c.IsQueryGrantedContext(r.Context(), query, roles)
 }
 
 // IsQueryGranted allows query permissions with the given Query parameter
 // * The parameter roles is the role of the current user.
+// * When a shadow loader is configured by WithShadow, the query is evaluated against the shadow rules as well.
+// * When an auditor is configured by WithAudit, the decision is recorded.
+// * When an observer is configured by WithObserver, the decision is observed.
 func (c *Controller) IsQueryGranted(q *Query, roles []string) (PermissionState, error) {
-    rules, err := c.find(q)
+    return c.IsQueryGrantedContext(context.Background(), q, roles)
+}
+
+// IsQueryGrantedContext is like IsQueryGranted,
+// the spans of the tracer configured by WithTracer are children of the span of the context.
+func (c *Controller) IsQueryGrantedContext(ctx context.Context, q *Query, roles []string) (PermissionState, error) {
+    if c.shadow == nil && c.auditor == nil && c.observer == nil && c.tracer == nil {
+        rules, err := c.find(q)
+        if err != nil {
+            return meta.PermissionUnknown, err
+        }
+        return rules.IsRolesGranted(roles)
+    }
+    decision, err := c.Decide(ctx, q, roles)
     if err != nil {
         return meta.PermissionUnknown, err
     }
-    return rules.IsRolesGranted(roles)
+    return decision.State, nil
+}
+
+// Decide is like IsQueryGrantedContext, and reports the rule deciding the permission.
+// Unlike Explain, the decision is shadowed, audited, observed and traced.
+func (c *Controller) Decide(ctx context.Context, q *Query, roles []string) (*Decision, error) {
+    var span Span
+    if c.tracer != nil {
+        ctx, span = c.tracer.Start(ctx, SpanQuery)
+        defer span.End()
+        span.SetAttributes(queryAttributes(q)...)
+    }
+    start := time.Now()
+    decision, err := c.explain(ctx, q, roles)
+    if err != nil {
+        if span != nil {
+            span.RecordError(err)
+        }
+        return nil, err
+    }
+    if span != nil {
+        span.SetAttributes(
+            Attribute{Key: AttributeDecision, Value: audit.Outcome(decision.State)},
+            Attribute{Key: AttributeRuleID, Value: decision.RuleID()},
+            Attribute{Key: AttributeCandidates, Value: len(decision.Matched)},
+            Attribute{Key: AttributeVersion, Value: decision.Version},
+        )
+    }
+    if c.observer != nil {
+        c.observer.ObserveDecision(decision.State, decision.RuleID(), len(decision.Matched), time.Since(start))
+    }
+    if c.shadow != nil {
+        c.shadow.compare(c, q, roles, decision)
+    }
+    if c.auditor != nil {
+        c.audit(q, roles, decision)
+    }
+    return decision, nil
 }
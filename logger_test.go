@@ -0,0 +1,40 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grbac
+
+import (
+    "bytes"
+    "errors"
+    "log"
+    "testing"
+
+    "github.com/storyicon/grbac/pkg/logging"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestWithLogger(t *testing.T) {
+    buffer := &bytes.Buffer{}
+    logger := logging.NewStd(log.New(buffer, "", 0), logging.LevelDebug)
+    _, err := New(WithLoader(func() (Rules, error) {
+        return nil, errors.New("connection refused")
+    }, -1), WithLogger(logger))
+    assert.NotEqual(t, nil, err)
+    assert.Contains(t, buffer.String(), `level=error msg="grbac failed to load the rules" error="connection refused" duration=`)
+
+    buffer.Reset()
+    c, err := New(WithRules(reverseRules), WithLogger(logger))
+    assert.Equal(t, nil, err)
+    assert.Contains(t, buffer.String(), `level=debug msg="grbac loaded the rules" rules=4 version=`+c.Version())
+}
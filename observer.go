@@ -0,0 +1,39 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grbac
+
+import (
+    "time"
+)
+
+// Observer provides the instrumentation hooks of the controller, such as metrics.Collector.
+// The hooks are called synchronously, so they should return quickly.
+type Observer interface {
+    // ObserveDecision is called after a query is evaluated,
+    // ruleID is the ID of the decisive rule, -1 when no rule matches,
+    // candidates is the number of rules matching the query in the tree.
+    ObserveDecision(state PermissionState, ruleID int, candidates int, duration time.Duration)
+    // ObserveReload is called after the rules are loaded,
+    // rules is the number of rules in effect and err is the error of the loading.
+    ObserveReload(rules int, err error)
+}
+
+// WithObserver is used to instrument the controller by the observer
+func WithObserver(observer Observer) ControllerOption {
+    return func(c *Controller) error {
+        c.observer = observer
+        return nil
+    }
+}
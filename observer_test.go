@@ -0,0 +1,45 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grbac
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+
+    "github.com/storyicon/grbac/pkg/metrics"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestWithObserver(t *testing.T) {
+    collector := metrics.New(metrics.Options{})
+    c, err := New(WithRules(reverseRules), WithObserver(collector))
+    assert.Equal(t, nil, err)
+
+    _, err = c.IsQueryGranted(&Query{Host: "domain.com", Path: "/article/draft", Method: "PUT"}, []string{"editor"})
+    assert.Equal(t, nil, err)
+
+    buffer := &bytes.Buffer{}
+    _, err = collector.WriteTo(buffer)
+    assert.Equal(t, nil, err)
+    for _, line := range []string{
+        `grbac_decisions_total{state="granted",rule_id="2"} 1`,
+        `grbac_query_candidate_rules_sum 3`,
+        `grbac_reloads_total{result="success"} 1`,
+        `grbac_rules 4`,
+    } {
+        assert.True(t, strings.Contains(buffer.String(), line+"\n"), line)
+    }
+}
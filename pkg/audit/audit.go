@@ -0,0 +1,197 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit records the authorization decisions.
+//
+// Records are sampled by outcome, queued without blocking the request,
+// and delivered to a Sink by a background goroutine. When the queue is full the records are dropped and counted.
+package audit
+
+import (
+    "errors"
+    "math/rand"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/storyicon/grbac/pkg/meta"
+)
+
+// define the outcomes of the decisions
+const (
+    OutcomeGranted   = "granted"
+    OutcomeUngranted = "ungranted"
+    OutcomeNeglected = "neglected"
+    OutcomeUnknown   = "unknown"
+)
+
+// ErrClosed is returned when a record is sent to a closed auditor
+var ErrClosed = errors.New("auditor closed")
+
+// Outcome is used to get the outcome of a permission state
+func Outcome(state meta.PermissionState) string {
+    switch state {
+    case meta.PermissionGranted:
+        return OutcomeGranted
+    case meta.PermissionUngranted:
+        return OutcomeUngranted
+    case meta.PermissionNeglected:
+        return OutcomeNeglected
+    }
+    return OutcomeUnknown
+}
+
+// Record defines an authorization decision
+type Record struct {
+    Time   time.Time `json:"time"`
+    Host   string    `json:"host"`
+    Path   string    `json:"path"`
+    Method string    `json:"method"`
+    Roles  []string  `json:"roles"`
+    // Outcome is the outcome of the decision, such as OutcomeGranted
+    Outcome string `json:"outcome"`
+    // RuleID is the ID of the decisive rule, -1 when no rule matches
+    RuleID int `json:"rule_id"`
+    // Version is the version of the rules
+    Version string `json:"version"`
+}
+
+// Sink defines the destination of the records
+type Sink interface {
+    Write(record *Record) error
+    Close() error
+}
+
+// Sampling defines the rate of the records kept for each outcome, from 0 to 1.
+// The outcomes which are not defined are always kept.
+type Sampling map[string]float64
+
+// keep is used to determine whether a record of the outcome is kept
+func (s Sampling) keep(outcome string) bool {
+    rate, ok := s[outcome]
+    if !ok || rate >= 1 {
+        return true
+    }
+    return rate > 0 && rand.Float64() < rate
+}
+
+// Options defines the options of the auditor
+type Options struct {
+    // QueueSize is the capacity of the queue of the records, 1024 by default
+    QueueSize int
+    // Sampling is the rates of the records kept, all the records are kept by default
+    Sampling Sampling
+}
+
+// Stats defines the counters of the auditor
+type Stats struct {
+    // Delivered is the number of records written to the sink
+    Delivered uint64
+    // Sampled is the number of records left out by sampling
+    Sampled uint64
+    // Dropped is the number of records dropped because the queue is full
+    Dropped uint64
+    // Failed is the number of records the sink failed to write
+    Failed uint64
+}
+
+// Auditor delivers the records to a sink asynchronously
+type Auditor struct {
+    sink     Sink
+    sampling Sampling
+    queue    chan *Record
+    done     chan struct{}
+
+    closed    bool
+    closeLock sync.RWMutex
+
+    delivered uint64
+    sampled   uint64
+    dropped   uint64
+    failed    uint64
+}
+
+// New is used to create an auditor delivering the records to the sink
+func New(sink Sink, opts Options) *Auditor {
+    if opts.QueueSize <= 0 {
+        opts.QueueSize = 1024
+    }
+    a := &Auditor{
+        sink:     sink,
+        sampling: opts.Sampling,
+        queue:    make(chan *Record, opts.QueueSize),
+        done:     make(chan struct{}),
+    }
+    go a.run()
+    return a
+}
+
+// run is used to deliver the records of the queue until it is closed
+func (a *Auditor) run() {
+    defer close(a.done)
+    for record := range a.queue {
+        if err := a.sink.Write(record); err != nil {
+            atomic.AddUint64(&a.failed, 1)
+            continue
+        }
+        atomic.AddUint64(&a.delivered, 1)
+    }
+}
+
+// Record is used to queue a record without blocking,
+// the record is dropped when the queue is full.
+func (a *Auditor) Record(record *Record) error {
+    if !a.sampling.keep(record.Outcome) {
+        atomic.AddUint64(&a.sampled, 1)
+        return nil
+    }
+    if record.Time.IsZero() {
+        record.Time = time.Now()
+    }
+    a.closeLock.RLock()
+    defer a.closeLock.RUnlock()
+    if a.closed {
+        return ErrClosed
+    }
+    select {
+    case a.queue <- record:
+    default:
+        atomic.AddUint64(&a.dropped, 1)
+    }
+    return nil
+}
+
+// Stats is used to get the counters of the auditor
+func (a *Auditor) Stats() Stats {
+    return Stats{
+        Delivered: atomic.LoadUint64(&a.delivered),
+        Sampled:   atomic.LoadUint64(&a.sampled),
+        Dropped:   atomic.LoadUint64(&a.dropped),
+        Failed:    atomic.LoadUint64(&a.failed),
+    }
+}
+
+// Close is used to deliver the queued records and close the sink
+func (a *Auditor) Close() error {
+    a.closeLock.Lock()
+    if a.closed {
+        a.closeLock.Unlock()
+        return ErrClosed
+    }
+    a.closed = true
+    close(a.queue)
+    a.closeLock.Unlock()
+    <-a.done
+    return a.sink.Close()
+}
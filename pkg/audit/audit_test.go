@@ -0,0 +1,104 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+    "errors"
+    "sync"
+    "testing"
+
+    "github.com/storyicon/grbac/pkg/meta"
+    "github.com/stretchr/testify/assert"
+)
+
+type memorySink struct {
+    records []*Record
+    block   chan struct{}
+    closed  bool
+    lock    sync.Mutex
+}
+
+func (s *memorySink) Write(record *Record) error {
+    if s.block != nil {
+        <-s.block
+    }
+    if record.Path == "/fail" {
+        return errors.New("failed")
+    }
+    s.lock.Lock()
+    defer s.lock.Unlock()
+    s.records = append(s.records, record)
+    return nil
+}
+
+func (s *memorySink) Close() error {
+    s.closed = true
+    return nil
+}
+
+func TestOutcome(t *testing.T) {
+    assert.Equal(t, OutcomeGranted, Outcome(meta.PermissionGranted))
+    assert.Equal(t, OutcomeUngranted, Outcome(meta.PermissionUngranted))
+    assert.Equal(t, OutcomeNeglected, Outcome(meta.PermissionNeglected))
+    assert.Equal(t, OutcomeUnknown, Outcome(meta.PermissionUnknown))
+}
+
+func TestAuditor(t *testing.T) {
+    sink := &memorySink{}
+    auditor := New(sink, Options{Sampling: Sampling{OutcomeGranted: 0}})
+    for _, record := range []*Record{
+        {Path: "/a", Outcome: OutcomeGranted},
+        {Path: "/b", Outcome: OutcomeUngranted},
+        {Path: "/fail", Outcome: OutcomeUngranted},
+        {Path: "/c", Outcome: OutcomeNeglected},
+    } {
+        assert.Equal(t, nil, auditor.Record(record))
+    }
+    assert.Equal(t, nil, auditor.Close())
+    assert.True(t, sink.closed)
+    assert.Equal(t, 2, len(sink.records))
+    assert.Equal(t, "/b", sink.records[0].Path)
+    assert.False(t, sink.records[0].Time.IsZero())
+    assert.Equal(t, Stats{Delivered: 2, Sampled: 1, Failed: 1}, auditor.Stats())
+
+    assert.Equal(t, ErrClosed, auditor.Record(&Record{Outcome: OutcomeUngranted}))
+    assert.Equal(t, ErrClosed, auditor.Close())
+}
+
+func TestAuditor_Dropped(t *testing.T) {
+    sink := &memorySink{block: make(chan struct{})}
+    auditor := New(sink, Options{QueueSize: 1})
+    for i := 0; i < 10; i++ {
+        assert.Equal(t, nil, auditor.Record(&Record{Outcome: OutcomeUngranted}))
+    }
+    close(sink.block)
+    assert.Equal(t, nil, auditor.Close())
+    stats := auditor.Stats()
+    assert.Equal(t, uint64(10), stats.Delivered+stats.Dropped)
+    assert.True(t, stats.Dropped >= 8)
+}
+
+func TestSampling(t *testing.T) {
+    sampling := Sampling{OutcomeGranted: 0.5, OutcomeNeglected: 0}
+    kept := 0
+    for i := 0; i < 1000; i++ {
+        if sampling.keep(OutcomeGranted) {
+            kept++
+        }
+        assert.False(t, sampling.keep(OutcomeNeglected))
+        assert.True(t, sampling.keep(OutcomeUngranted))
+    }
+    assert.True(t, kept > 350 && kept < 650)
+}
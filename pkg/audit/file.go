@@ -0,0 +1,166 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+    "fmt"
+    "os"
+    "sync"
+
+    jsoniter "github.com/json-iterator/go"
+)
+
+// FileSink writes the records to a file as json lines.
+// When the file would exceed MaxSize, it is renamed to name.1, the older backups are shifted
+// to name.2, name.3... and the backups beyond MaxBackups are removed.
+// When the rotation fails, the records are still appended to the file, see RotationFailures.
+type FileSink struct {
+    name       string
+    maxSize    int64
+    maxBackups int
+
+    // file is nil when it can not be reopened after a rotation, it is reopened by the next Write
+    file   *os.File
+    size   int64
+    closed bool
+    lock   sync.Mutex
+
+    rotationFailures uint64
+    rotationErr      error
+}
+
+// NewFileSink is used to create a file sink,
+// a maxSize <= 0 disables the rotation and a maxBackups <= 0 keeps no backup.
+func NewFileSink(name string, maxSize int64, maxBackups int) (*FileSink, error) {
+    s := &FileSink{
+        name:       name,
+        maxSize:    maxSize,
+        maxBackups: maxBackups,
+    }
+    if err := s.open(); err != nil {
+        return nil, err
+    }
+    return s, nil
+}
+
+// open is used to open the file in append mode
+func (s *FileSink) open() error {
+    file, err := os.OpenFile(s.name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+    if err != nil {
+        return err
+    }
+    info, err := file.Stat()
+    if err != nil {
+        file.Close()
+        return err
+    }
+    s.file = file
+    s.size = info.Size()
+    return nil
+}
+
+// Write is used to write a record as a json line
+func (s *FileSink) Write(record *Record) error {
+    line, err := jsoniter.Marshal(record)
+    if err != nil {
+        return err
+    }
+    line = append(line, '\n')
+    s.lock.Lock()
+    defer s.lock.Unlock()
+    if s.closed {
+        return ErrClosed
+    }
+    if s.file == nil {
+        if err := s.open(); err != nil {
+            return err
+        }
+    }
+    if s.maxSize > 0 && s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+        if err := s.rotate(); err != nil {
+            s.rotationFailures++
+            s.rotationErr = err
+            // the record is appended to the reopened file, the rotation is retried by the next Write
+            if s.file == nil {
+                return err
+            }
+        }
+    }
+    n, err := s.file.Write(line)
+    s.size += int64(n)
+    return err
+}
+
+// rotate is used to move the file to the backups and open a new one,
+// when the rotation fails, the file is reopened so that the following records are still written.
+func (s *FileSink) rotate() error {
+    err := s.file.Close()
+    s.file = nil
+    if err == nil {
+        err = s.shift()
+    }
+    if err != nil {
+        // the file is appended to until a rotation succeeds
+        s.open()
+        return err
+    }
+    return s.open()
+}
+
+// shift is used to move the file to the backups
+func (s *FileSink) shift() error {
+    if s.maxBackups <= 0 {
+        if err := os.Remove(s.name); err != nil && !os.IsNotExist(err) {
+            return err
+        }
+        return nil
+    }
+    for i := s.maxBackups - 1; i > 0; i-- {
+        err := os.Rename(backup(s.name, i), backup(s.name, i+1))
+        if err != nil && !os.IsNotExist(err) {
+            return err
+        }
+    }
+    return os.Rename(s.name, backup(s.name, 1))
+}
+
+// RotationFailures is used to get the number of the failed rotations and the error of the last one,
+// the records are still appended to the file when it can be reopened
+func (s *FileSink) RotationFailures() (uint64, error) {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+    return s.rotationFailures, s.rotationErr
+}
+
+// backup is used to get the name of the i-th backup
+func backup(name string, i int) string {
+    return fmt.Sprintf("%s.%d", name, i)
+}
+
+// Close is used to close the file
+func (s *FileSink) Close() error {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+    if s.closed {
+        return ErrClosed
+    }
+    s.closed = true
+    if s.file == nil {
+        return nil
+    }
+    err := s.file.Close()
+    s.file = nil
+    return err
+}
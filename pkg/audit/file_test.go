@@ -0,0 +1,94 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestFileSink(t *testing.T) {
+    dir, err := ioutil.TempDir("", "audit")
+    assert.Equal(t, nil, err)
+    defer os.RemoveAll(dir)
+    name := filepath.Join(dir, "audit.log")
+
+    record := &Record{
+        Time:    time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+        Host:    "domain.com",
+        Path:    "/article/1",
+        Method:  "DELETE",
+        Roles:   []string{"editor"},
+        Outcome: OutcomeUngranted,
+        RuleID:  1,
+        Version: "v1",
+    }
+    line := `{"time":"2019-01-01T00:00:00Z","host":"domain.com","path":"/article/1","method":"DELETE","roles":["editor"],"outcome":"ungranted","rule_id":1,"version":"v1"}` + "\n"
+
+    sink, err := NewFileSink(name, int64(2*len(line)), 2)
+    assert.Equal(t, nil, err)
+    for i := 0; i < 7; i++ {
+        assert.Equal(t, nil, sink.Write(record))
+    }
+    assert.Equal(t, nil, sink.Close())
+    assert.Equal(t, ErrClosed, sink.Write(record))
+
+    for name, lines := range map[string]int{name: 1, name + ".1": 2, name + ".2": 2} {
+        data, err := ioutil.ReadFile(name)
+        assert.Equal(t, nil, err)
+        assert.Equal(t, strings.Repeat(line, lines), string(data))
+    }
+    _, err = os.Stat(name + ".3")
+    assert.True(t, os.IsNotExist(err))
+
+    sink, err = NewFileSink(name, int64(len(line)), 0)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, nil, sink.Write(record))
+    assert.Equal(t, nil, sink.Close())
+    data, err := ioutil.ReadFile(name)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, line, string(data))
+
+    // a failed rotation does not close the sink
+    assert.Equal(t, nil, os.Remove(name))
+    assert.Equal(t, nil, os.Remove(name+".1"))
+    assert.Equal(t, nil, os.MkdirAll(filepath.Join(name+".1", "busy"), 0755))
+    sink, err = NewFileSink(name, int64(len(line)), 1)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, nil, sink.Write(record))
+    assert.Equal(t, nil, sink.Write(record))
+    failures, err := sink.RotationFailures()
+    assert.Equal(t, uint64(1), failures)
+    assert.NotEqual(t, nil, err)
+    // the record triggering the failed rotation is on disk
+    data, err = ioutil.ReadFile(name)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, strings.Repeat(line, 2), string(data))
+
+    assert.Equal(t, nil, os.RemoveAll(name+".1"))
+    assert.Equal(t, nil, sink.Write(record))
+    assert.Equal(t, nil, sink.Close())
+    for name, lines := range map[string]int{name: 1, name + ".1": 2} {
+        data, err := ioutil.ReadFile(name)
+        assert.Equal(t, nil, err)
+        assert.Equal(t, strings.Repeat(line, lines), string(data))
+    }
+}
@@ -0,0 +1,221 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bundle
+
+import (
+    "crypto"
+    "crypto/ecdsa"
+    "crypto/ed25519"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "time"
+
+    jsoniter "github.com/json-iterator/go"
+)
+
+// define a set of errors
+var (
+    ErrUnsigned          = errors.New("bundle is not signed")
+    ErrExpired           = errors.New("bundle has expired")
+    ErrNotYetValid       = errors.New("bundle is not yet valid")
+    ErrNoExpiry          = errors.New("bundle has no expiry")
+    ErrUnknownKey        = errors.New("bundle is signed by an unknown key")
+    ErrBadSignature      = errors.New("bundle signature mismatch")
+    ErrDigestMismatch    = errors.New("bundle digest mismatch")
+    ErrAlgorithmMismatch = errors.New("bundle algorithm does not match the key")
+    ErrUnsupportedKey    = errors.New("unsupported key type")
+)
+
+// define the supported signature algorithms
+const (
+    // AlgorithmEd25519 signs the manifest with an Ed25519 key
+    AlgorithmEd25519 = "ed25519"
+    // AlgorithmECDSA signs the SHA-256 digest of the manifest with an ECDSA key
+    AlgorithmECDSA = "ecdsa-sha256"
+)
+
+// Manifest describes the rules carried by a bundle.
+// The signature of a bundle is calculated over its manifest,
+// and the manifest binds the rules through their digest.
+type Manifest struct {
+    // Version is a free-form label of the policy, such as a git revision.
+    Version string `json:"version"`
+    // Format is the format of the rules, such as "json" or "yaml".
+    Format string `json:"format"`
+    // Digest is the hex encoded SHA-256 digest of the rules.
+    Digest string `json:"digest"`
+    // KeyID identifies the public key that verifies the signature.
+    KeyID string `json:"key_id"`
+    // Algorithm is the signature algorithm.
+    Algorithm string `json:"algorithm"`
+    // IssuedAt is the time at which the bundle was signed.
+    IssuedAt time.Time `json:"issued_at"`
+    // ExpiresAt is the time after which the bundle is rejected.
+    ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Bundle is the signed envelope of a rule file.
+type Bundle struct {
+    Manifest  *Manifest `json:"manifest"`
+    Rules     []byte    `json:"rules"`
+    Signature []byte    `json:"signature"`
+}
+
+// SignOptions defines the parameters used to sign a bundle
+type SignOptions struct {
+    Version string
+    Format  string
+    // KeyID is derived from the public key when it is empty.
+    KeyID string
+    // TTL defines how long the bundle remains valid.
+    TTL time.Duration
+    // Now is used as the time of issue, time.Now is used when it is zero.
+    Now time.Time
+}
+
+// Sign is used to wrap the rules into a bundle signed by the given key.
+// Ed25519 and ECDSA private keys are supported.
+func Sign(rules []byte, key crypto.Signer, opts SignOptions) (*Bundle, error) {
+    if opts.TTL <= 0 {
+        return nil, ErrNoExpiry
+    }
+    algorithm, err := getAlgorithm(key.Public())
+    if err != nil {
+        return nil, err
+    }
+    keyID := opts.KeyID
+    if keyID == "" {
+        keyID, err = Fingerprint(key.Public())
+        if err != nil {
+            return nil, err
+        }
+    }
+    now := opts.Now
+    if now.IsZero() {
+        now = time.Now()
+    }
+    now = now.UTC().Truncate(time.Second)
+    digest := sha256.Sum256(rules)
+    manifest := &Manifest{
+        Version:   opts.Version,
+        Format:    opts.Format,
+        Digest:    hex.EncodeToString(digest[:]),
+        KeyID:     keyID,
+        Algorithm: algorithm,
+        IssuedAt:  now,
+        ExpiresAt: now.Add(opts.TTL),
+    }
+    payload, err := manifest.signingBytes()
+    if err != nil {
+        return nil, err
+    }
+    var signature []byte
+    switch algorithm {
+    case AlgorithmEd25519:
+        signature, err = key.Sign(rand.Reader, payload, crypto.Hash(0))
+    case AlgorithmECDSA:
+        sum := sha256.Sum256(payload)
+        signature, err = key.Sign(rand.Reader, sum[:], crypto.SHA256)
+    }
+    if err != nil {
+        return nil, err
+    }
+    return &Bundle{
+        Manifest:  manifest,
+        Rules:     rules,
+        Signature: signature,
+    }, nil
+}
+
+// Decode is used to parse a bundle from its serialized form
+func Decode(data []byte) (*Bundle, error) {
+    b := &Bundle{}
+    err := jsoniter.Unmarshal(data, b)
+    if err != nil {
+        return nil, err
+    }
+    return b, nil
+}
+
+// Encode is used to serialize the bundle
+func (b *Bundle) Encode() ([]byte, error) {
+    return jsoniter.MarshalIndent(b, "", "    ")
+}
+
+// Verify is used to check the signature, the digest and the validity period of the bundle.
+// The rules are only returned when all checks pass.
+func (b *Bundle) Verify(keys KeyRing, now time.Time) ([]byte, error) {
+    if b.Manifest == nil || len(b.Signature) == 0 {
+        return nil, ErrUnsigned
+    }
+    manifest := b.Manifest
+    key, ok := keys[manifest.KeyID]
+    if !ok {
+        return nil, ErrUnknownKey
+    }
+    algorithm, err := getAlgorithm(key)
+    if err != nil {
+        return nil, err
+    }
+    if algorithm != manifest.Algorithm {
+        return nil, ErrAlgorithmMismatch
+    }
+    payload, err := manifest.signingBytes()
+    if err != nil {
+        return nil, err
+    }
+    var valid bool
+    switch k := key.(type) {
+    case ed25519.PublicKey:
+        valid = ed25519.Verify(k, payload, b.Signature)
+    case *ecdsa.PublicKey:
+        sum := sha256.Sum256(payload)
+        valid = ecdsa.VerifyASN1(k, sum[:], b.Signature)
+    }
+    if !valid {
+        return nil, ErrBadSignature
+    }
+    digest := sha256.Sum256(b.Rules)
+    if hex.EncodeToString(digest[:]) != manifest.Digest {
+        return nil, ErrDigestMismatch
+    }
+    if manifest.ExpiresAt.IsZero() {
+        return nil, ErrNoExpiry
+    }
+    if !now.Before(manifest.ExpiresAt) {
+        return nil, ErrExpired
+    }
+    if now.Before(manifest.IssuedAt) {
+        return nil, ErrNotYetValid
+    }
+    return b.Rules, nil
+}
+
+// signingBytes returns the canonical form of the manifest covered by the signature
+func (manifest *Manifest) signingBytes() ([]byte, error) {
+    return jsoniter.Marshal(manifest)
+}
+
+func getAlgorithm(key crypto.PublicKey) (string, error) {
+    switch key.(type) {
+    case ed25519.PublicKey:
+        return AlgorithmEd25519, nil
+    case *ecdsa.PublicKey:
+        return AlgorithmECDSA, nil
+    }
+    return "", ErrUnsupportedKey
+}
@@ -0,0 +1,142 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bundle
+
+import (
+    "crypto"
+    "crypto/ecdsa"
+    "crypto/ed25519"
+    "crypto/elliptic"
+    "crypto/rand"
+    "crypto/x509"
+    "encoding/pem"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func generateKeys(t *testing.T) []crypto.Signer {
+    _, edKey, err := ed25519.GenerateKey(rand.Reader)
+    assert.Equal(t, nil, err)
+    ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    assert.Equal(t, nil, err)
+    return []crypto.Signer{edKey, ecKey}
+}
+
+func TestBundle_Verify(t *testing.T) {
+    rules := []byte(`[{"id":0,"host":"*","path":"**","method":"*","allow_anyone":true}]`)
+    now := time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC)
+
+    for _, key := range generateKeys(t) {
+        other := generateKeys(t)[1]
+        ring, err := NewKeyRing(key.Public())
+        assert.Equal(t, nil, err)
+
+        b, err := Sign(rules, key, SignOptions{Version: "v1", Format: "json", TTL: time.Hour, Now: now})
+        assert.Equal(t, nil, err)
+
+        data, err := b.Encode()
+        assert.Equal(t, nil, err)
+        decoded, err := Decode(data)
+        assert.Equal(t, nil, err)
+
+        payload, err := decoded.Verify(ring, now.Add(time.Minute))
+        assert.Equal(t, nil, err)
+        assert.Equal(t, rules, payload)
+
+        _, err = decoded.Verify(ring, now.Add(2*time.Hour))
+        assert.Equal(t, ErrExpired, err)
+
+        _, err = decoded.Verify(ring, now.Add(-time.Minute))
+        assert.Equal(t, ErrNotYetValid, err)
+
+        otherRing, err := NewKeyRing(other.Public())
+        assert.Equal(t, nil, err)
+        _, err = decoded.Verify(otherRing, now)
+        assert.Equal(t, ErrUnknownKey, err)
+
+        tampered := *decoded
+        tampered.Rules = []byte(`[{"id":0,"host":"*","path":"**","method":"*","authorized_roles":["*"]}]`)
+        _, err = tampered.Verify(ring, now)
+        assert.Equal(t, ErrDigestMismatch, err)
+
+        manifest := *decoded.Manifest
+        manifest.ExpiresAt = manifest.ExpiresAt.Add(24 * time.Hour)
+        tampered = *decoded
+        tampered.Manifest = &manifest
+        _, err = tampered.Verify(ring, now)
+        assert.Equal(t, ErrBadSignature, err)
+
+        unsigned := &Bundle{Rules: rules}
+        _, err = unsigned.Verify(ring, now)
+        assert.Equal(t, ErrUnsigned, err)
+    }
+}
+
+func TestSign(t *testing.T) {
+    key := generateKeys(t)[0]
+    _, err := Sign([]byte(`[]`), key, SignOptions{Format: "json"})
+    assert.Equal(t, ErrNoExpiry, err)
+
+    b, err := Sign([]byte(`[]`), key, SignOptions{Format: "json", KeyID: "ops", TTL: time.Hour})
+    assert.Equal(t, nil, err)
+    assert.Equal(t, "ops", b.Manifest.KeyID)
+    assert.Equal(t, AlgorithmEd25519, b.Manifest.Algorithm)
+
+    _, err = b.Verify(KeyRing{"ops": key.Public()}, time.Now())
+    assert.Equal(t, nil, err)
+}
+
+func TestLoadKeyRing(t *testing.T) {
+    dir, err := ioutil.TempDir("", "keyring")
+    assert.Equal(t, nil, err)
+    defer os.RemoveAll(dir)
+
+    key := generateKeys(t)[1]
+    der, err := x509.MarshalPKIXPublicKey(key.Public())
+    assert.Equal(t, nil, err)
+    name := filepath.Join(dir, "ops.pem")
+    err = ioutil.WriteFile(name, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644)
+    assert.Equal(t, nil, err)
+
+    signed, err := Sign([]byte(`[]`), key, SignOptions{Format: "json", KeyID: "ops", TTL: time.Hour})
+    assert.Equal(t, nil, err)
+    data, err := signed.Encode()
+    assert.Equal(t, nil, err)
+    b, err := Decode(data)
+    assert.Equal(t, nil, err)
+
+    ring, err := LoadKeyRing("ops=" + name)
+    assert.Equal(t, nil, err)
+    _, err = b.Verify(ring, time.Now())
+    assert.Equal(t, nil, err)
+
+    ring, err = LoadKeyRing(name)
+    assert.Equal(t, nil, err)
+    _, err = b.Verify(ring, time.Now())
+    assert.Equal(t, ErrUnknownKey, err)
+
+    fingerprint, err := Fingerprint(key.Public())
+    assert.Equal(t, nil, err)
+    b, err = Sign([]byte(`[]`), key, SignOptions{Format: "json", TTL: time.Hour})
+    assert.Equal(t, nil, err)
+    assert.Equal(t, fingerprint, b.Manifest.KeyID)
+    _, err = b.Verify(ring, time.Now())
+    assert.Equal(t, nil, err)
+}
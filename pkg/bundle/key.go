@@ -0,0 +1,142 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bundle
+
+import (
+    "crypto"
+    "crypto/sha256"
+    "crypto/x509"
+    "encoding/hex"
+    "encoding/pem"
+    "errors"
+    "io/ioutil"
+    "strings"
+)
+
+// ErrInvalidPEM is returned when no PEM block can be found
+var ErrInvalidPEM = errors.New("invalid pem data")
+
+// KeyRing maps key ids to the public keys trusted to sign bundles
+type KeyRing map[string]crypto.PublicKey
+
+// NewKeyRing is used to initialize a KeyRing,
+// every key is registered under its fingerprint.
+func NewKeyRing(keys ...crypto.PublicKey) (KeyRing, error) {
+    ring := KeyRing{}
+    for _, key := range keys {
+        if err := ring.Add(key); err != nil {
+            return nil, err
+        }
+    }
+    return ring, nil
+}
+
+// LoadKeyRing is used to initialize a KeyRing from PEM encoded public key files.
+// A file is registered under its fingerprint, or under the id of the form id=path.pem,
+// which matches the key id given to SignOptions.
+func LoadKeyRing(files ...string) (KeyRing, error) {
+    ring := KeyRing{}
+    for _, file := range files {
+        var id string
+        if i := strings.Index(file, "="); i > 0 {
+            id, file = file[:i], file[i+1:]
+        }
+        data, err := ioutil.ReadFile(file)
+        if err != nil {
+            return nil, err
+        }
+        key, err := ParsePublicKey(data)
+        if err != nil {
+            return nil, err
+        }
+        if id == "" {
+            err = ring.Add(key)
+        } else {
+            err = ring.AddWithID(id, key)
+        }
+        if err != nil {
+            return nil, err
+        }
+    }
+    return ring, nil
+}
+
+// Add is used to register a key under its fingerprint
+func (ring KeyRing) Add(key crypto.PublicKey) error {
+    id, err := Fingerprint(key)
+    if err != nil {
+        return err
+    }
+    return ring.AddWithID(id, key)
+}
+
+// AddWithID is used to register a key under a custom key id
+func (ring KeyRing) AddWithID(id string, key crypto.PublicKey) error {
+    if _, err := getAlgorithm(key); err != nil {
+        return err
+    }
+    ring[id] = key
+    return nil
+}
+
+// Fingerprint is used to calculate the default key id of a public key.
+// It is the first 8 bytes of the SHA-256 digest of the PKIX encoding of the key.
+func Fingerprint(key crypto.PublicKey) (string, error) {
+    der, err := x509.MarshalPKIXPublicKey(key)
+    if err != nil {
+        return "", err
+    }
+    sum := sha256.Sum256(der)
+    return hex.EncodeToString(sum[:8]), nil
+}
+
+// ParsePublicKey is used to parse a PEM encoded PKIX public key
+func ParsePublicKey(data []byte) (crypto.PublicKey, error) {
+    block, _ := pem.Decode(data)
+    if block == nil {
+        return nil, ErrInvalidPEM
+    }
+    key, err := x509.ParsePKIXPublicKey(block.Bytes)
+    if err != nil {
+        return nil, err
+    }
+    if _, err := getAlgorithm(key); err != nil {
+        return nil, err
+    }
+    return key, nil
+}
+
+// ParsePrivateKey is used to parse a PEM encoded PKCS #8 or SEC 1 private key
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+    block, _ := pem.Decode(data)
+    if block == nil {
+        return nil, ErrInvalidPEM
+    }
+    if block.Type == "EC PRIVATE KEY" {
+        return x509.ParseECPrivateKey(block.Bytes)
+    }
+    key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+    if err != nil {
+        return nil, err
+    }
+    signer, ok := key.(crypto.Signer)
+    if !ok {
+        return nil, ErrUnsupportedKey
+    }
+    if _, err := getAlgorithm(signer.Public()); err != nil {
+        return nil, err
+    }
+    return signer, nil
+}
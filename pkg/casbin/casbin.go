@@ -0,0 +1,321 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package casbin converts the policies of Casbin's RBAC model with key matching into grbac rules, and back.
+//
+// The policy lines are converted as follows:
+//
+//  p, editor, /articles/:id, PUT       the role editor is authorized to PUT /articles/*
+//  p, guest, /admin/*, *, deny         the role guest is forbidden to access /admin/**
+//  g, alice, editor                    alice inherits the roles of editor, see Hierarchy
+//
+// grbac has no role inheritance, the Hierarchy is used to resolve the roles of a subject
+// before asking the controller.
+// Constructs that can not be represented are collected in the Report instead of failing the conversion.
+package casbin
+
+import (
+    "encoding/csv"
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "regexp"
+    "strings"
+
+    "github.com/storyicon/grbac/pkg/meta"
+    "github.com/storyicon/grbac/pkg/probe"
+)
+
+// define the effects of a policy
+const (
+    EffectAllow = "allow"
+    EffectDeny  = "deny"
+)
+
+// define a set of errors
+var (
+    ErrUnknownPolicyType = errors.New("unknown policy type")
+    ErrMissingField      = errors.New("missing fields")
+)
+
+// Issue defines a construct that can not be represented exactly
+type Issue struct {
+    // Line is the line of the policy, zero for the issues of the exporter
+    Line   int
+    Text   string
+    Reason string
+}
+
+func (issue *Issue) String() string {
+    if issue.Line > 0 {
+        return fmt.Sprintf("line %d: %s: %s", issue.Line, issue.Text, issue.Reason)
+    }
+    return fmt.Sprintf("%s: %s", issue.Text, issue.Reason)
+}
+
+// Hierarchy defines the roles inherited by a subject,
+// it maps a subject to the roles it is directly assigned.
+type Hierarchy map[string][]string
+
+// Add is used to assign a role to a subject
+func (h Hierarchy) Add(subject string, role string) {
+    for _, r := range h[subject] {
+        if r == role {
+            return
+        }
+    }
+    h[subject] = append(h[subject], role)
+}
+
+// Roles is used to resolve all the roles of the subjects, including the inherited ones.
+// The subjects themselves are included, because a subject can be a role.
+func (h Hierarchy) Roles(subjects ...string) []string {
+    visited := map[string]bool{}
+    var roles []string
+    var visit func(subject string)
+    visit = func(subject string) {
+        if visited[subject] {
+            return
+        }
+        visited[subject] = true
+        roles = append(roles, subject)
+        for _, role := range h[subject] {
+            visit(role)
+        }
+    }
+    for _, subject := range subjects {
+        visit(subject)
+    }
+    return roles
+}
+
+// Options is used to customize the importer
+type Options struct {
+    // Host is the host of the generated rules, defaults to "*"
+    Host string
+    // FirstID is the ID of the first generated rule
+    FirstID int
+}
+
+// Report is the result of an import
+type Report struct {
+    // Rules are the generated rules, one for each distinct object and action,
+    // the rules of more specific paths have higher IDs.
+    Rules meta.Rules
+    // Hierarchy is built from the g lines
+    Hierarchy Hierarchy
+    // Issues are the constructs that are approximated or skipped
+    Issues []*Issue
+}
+
+// keyPattern matches the :name segments of keyMatch2
+var keyPattern = regexp.MustCompile(`^:[A-Za-z0-9_]+$`)
+
+// bracePattern matches the {name} segments of keyMatch3
+var bracePattern = regexp.MustCompile(`^\{[A-Za-z0-9_]+\}$`)
+
+// actionsPattern matches the (GET)|(POST) form of regexMatch actions
+var actionsPattern = regexp.MustCompile(`^\(?[A-Za-z]+\)?(\|\(?[A-Za-z]+\)?)*$`)
+
+// ImportFile is used to import a Casbin policy file
+func ImportFile(file string, opts *Options) (*Report, error) {
+    data, err := ioutil.ReadFile(file)
+    if err != nil {
+        return nil, err
+    }
+    return Import(data, opts)
+}
+
+// Import is used to convert Casbin policy lines into rules and a role hierarchy
+func Import(data []byte, opts *Options) (*Report, error) {
+    if opts == nil {
+        opts = &Options{}
+    }
+    host := opts.Host
+    if host == "" {
+        host = "*"
+    }
+    report := &Report{Hierarchy: Hierarchy{}}
+    permissions := map[meta.Resource]*meta.Permission{}
+    var resources []meta.Resource
+
+    for i, text := range strings.Split(string(data), "\n") {
+        line := i + 1
+        text = strings.TrimSpace(text)
+        if text == "" || strings.HasPrefix(text, "#") {
+            continue
+        }
+        reader := csv.NewReader(strings.NewReader(text))
+        reader.TrimLeadingSpace = true
+        record, err := reader.Read()
+        if err != nil {
+            return nil, fmt.Errorf("line %d: %s", line, err)
+        }
+        for i := range record {
+            record[i] = strings.TrimSpace(record[i])
+        }
+        issue := func(reason string) {
+            report.Issues = append(report.Issues, &Issue{Line: line, Text: text, Reason: reason})
+        }
+        switch record[0] {
+        case "p":
+            if len(record) < 4 {
+                return nil, fmt.Errorf("line %d: %s", line, ErrMissingField)
+            }
+            effect := EffectAllow
+            switch len(record) {
+            case 4:
+            case 5:
+                if record[4] != EffectAllow && record[4] != EffectDeny {
+                    issue("domains are not supported")
+                    continue
+                }
+                effect = record[4]
+            default:
+                issue("domains and custom fields are not supported")
+                continue
+            }
+            subject, object, action := record[1], record[2], record[3]
+            resourcePath, exact := convertObject(object)
+            if resourcePath == "" {
+                issue("regular expressions are not supported")
+                continue
+            }
+            if !exact {
+                issue("a wildcard inside a path segment is approximated with a segment wildcard")
+            }
+            method, ok := convertAction(action)
+            if !ok {
+                issue("the action can not be converted into a method")
+                continue
+            }
+            resource := meta.Resource{Host: host, Path: resourcePath, Method: method}
+            permission, ok := permissions[resource]
+            if !ok {
+                permission = &meta.Permission{}
+                permissions[resource] = permission
+                resources = append(resources, resource)
+            }
+            if effect == EffectDeny {
+                permission.ForbiddenRoles = appendRole(permission.ForbiddenRoles, subject)
+            } else {
+                permission.AuthorizedRoles = appendRole(permission.AuthorizedRoles, subject)
+            }
+        case "g":
+            if len(record) < 3 {
+                return nil, fmt.Errorf("line %d: %s", line, ErrMissingField)
+            }
+            if len(record) > 3 {
+                issue("domains are not supported")
+                continue
+            }
+            report.Hierarchy.Add(record[1], record[2])
+        default:
+            if strings.HasPrefix(record[0], "g") || strings.HasPrefix(record[0], "p") {
+                issue("only p and g policies are supported")
+                continue
+            }
+            return nil, fmt.Errorf("line %d: %s %q", line, ErrUnknownPolicyType, record[0])
+        }
+    }
+
+    report.Rules = meta.Rules{}
+    for i := range resources {
+        resource := resources[i]
+        report.Rules = append(report.Rules, &meta.Rule{
+            Resource:   &resource,
+            Permission: permissions[resource],
+        })
+    }
+    report.Rules.Prioritize(opts.FirstID, nil)
+    report.Issues = append(report.Issues, shadowed(report.Rules)...)
+    return report, nil
+}
+
+// convertObject is used to convert a keyMatch, keyMatch2 or keyMatch3 object into a resource path,
+// exact is false when the conversion is approximated.
+// An empty path is returned for regular expressions.
+func convertObject(object string) (resourcePath string, exact bool) {
+    if strings.ContainsAny(object, "()[]^$+?|\\") {
+        return "", false
+    }
+    exact = true
+    segments := strings.Split(object, "/")
+    for i, segment := range segments {
+        switch {
+        case segment == "*":
+            segments[i] = "**"
+        case keyPattern.MatchString(segment), bracePattern.MatchString(segment):
+            segments[i] = "*"
+        case strings.Contains(segment, "*"):
+            exact = false
+        case strings.ContainsAny(segment, "{}"):
+            return "", false
+        }
+    }
+    return strings.Join(segments, "/"), exact
+}
+
+// convertAction is used to convert an action into a method pattern
+func convertAction(action string) (string, bool) {
+    switch {
+    case action == "*", action == ".*":
+        return "*", true
+    case actionsPattern.MatchString(action):
+        replacer := strings.NewReplacer("(", "", ")", "")
+        methods := strings.Split(replacer.Replace(action), "|")
+        for i, method := range methods {
+            methods[i] = strings.ToUpper(method)
+        }
+        if len(methods) == 1 {
+            return methods[0], true
+        }
+        return "{" + strings.Join(methods, ",") + "}", true
+    }
+    return "", false
+}
+
+// appendRole is used to append a role without duplicates
+func appendRole(roles []string, role string) []string {
+    for _, r := range roles {
+        if r == role {
+            return roles
+        }
+    }
+    return append(roles, role)
+}
+
+// shadowed is used to report the rules hidden by more specific rules.
+// Casbin combines the effects of all matched policies,
+// while grbac only uses the matched rule with the highest ID.
+func shadowed(rules meta.Rules) []*Issue {
+    var issues []*Issue
+    for i, general := range rules {
+        for _, specific := range rules[i+1:] {
+            query, err := probe.Query(specific.Resource)
+            if err != nil {
+                continue
+            }
+            matched, err := general.Resource.Match(query)
+            if err != nil || !matched {
+                continue
+            }
+            issues = append(issues, &Issue{
+                Text:   fmt.Sprintf("%s %s", general.Method, general.Path),
+                Reason: fmt.Sprintf("shadowed by %s %s, grbac does not combine the matched rules", specific.Method, specific.Path),
+            })
+        }
+    }
+    return issues
+}
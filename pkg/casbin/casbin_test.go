@@ -0,0 +1,148 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package casbin
+
+import (
+    "testing"
+
+    "github.com/storyicon/grbac/pkg/meta"
+    "github.com/stretchr/testify/assert"
+)
+
+const policy = `
+# articles
+p, reader, /articles/*, GET
+p, editor, /articles/:id, (GET)|(PUT)
+p, admin, /articles/:id, (GET)|(PUT)
+p, guest, /articles/:id, (GET)|(PUT), deny
+p, editor, /drafts*, GET
+p, admin, ^/admin/.*$, GET
+p, admin, tenant1, /tenants/*, GET
+
+g, alice, editor
+g, editor, reader
+g, bob, admin, tenant1
+`
+
+func TestImport(t *testing.T) {
+    report, err := Import([]byte(policy), nil)
+    assert.Equal(t, nil, err)
+    want := meta.Rules{
+        {
+            ID:         0,
+            Resource:   &meta.Resource{Host: "*", Path: "/articles/**", Method: "GET"},
+            Permission: &meta.Permission{AuthorizedRoles: []string{"reader"}},
+        },
+        {
+            ID:         1,
+            Resource:   &meta.Resource{Host: "*", Path: "/articles/*", Method: "{GET,PUT}"},
+            Permission: &meta.Permission{AuthorizedRoles: []string{"editor", "admin"}, ForbiddenRoles: []string{"guest"}},
+        },
+        {
+            ID:         2,
+            Resource:   &meta.Resource{Host: "*", Path: "/drafts*", Method: "GET"},
+            Permission: &meta.Permission{AuthorizedRoles: []string{"editor"}},
+        },
+    }
+    assert.Equal(t, want.String(), report.Rules.String())
+    assert.Equal(t, Hierarchy{"alice": {"editor"}, "editor": {"reader"}}, report.Hierarchy)
+    assert.Equal(t, []string{"alice", "editor", "reader"}, report.Hierarchy.Roles("alice"))
+
+    var issues []string
+    for _, issue := range report.Issues {
+        issues = append(issues, issue.String())
+    }
+    assert.Equal(t, []string{
+        "line 7: p, editor, /drafts*, GET: a wildcard inside a path segment is approximated with a segment wildcard",
+        "line 8: p, admin, ^/admin/.*$, GET: regular expressions are not supported",
+        "line 9: p, admin, tenant1, /tenants/*, GET: domains are not supported",
+        "line 13: g, bob, admin, tenant1: domains are not supported",
+        "GET /articles/**: shadowed by {GET,PUT} /articles/*, grbac does not combine the matched rules",
+    }, issues)
+}
+
+func TestImportErrors(t *testing.T) {
+    tests := []struct {
+        name string
+        data string
+        want string
+    }{
+        {name: "test0", data: "p, admin, /articles", want: "line 1: missing fields"},
+        {name: "test1", data: "\ng, alice", want: "line 2: missing fields"},
+        {name: "test2", data: "x, alice, admin", want: `line 1: unknown policy type "x"`},
+    }
+    for _, tt := range tests {
+        _, err := Import([]byte(tt.data), nil)
+        if assert.NotNil(t, err, tt.name) {
+            assert.Equal(t, tt.want, err.Error(), tt.name)
+        }
+    }
+}
+
+func TestExport(t *testing.T) {
+    rules := meta.Rules{
+        {
+            ID:         0,
+            Resource:   &meta.Resource{Host: "*", Path: "/articles/**", Method: "*"},
+            Permission: &meta.Permission{AuthorizedRoles: []string{"*", "reader"}},
+        },
+        {
+            ID:         1,
+            Resource:   &meta.Resource{Host: "*", Path: "/articles/*", Method: "{GET,PUT}"},
+            Permission: &meta.Permission{AuthorizedRoles: []string{"editor"}, ForbiddenRoles: []string{"guest"}},
+        },
+        {
+            ID:         2,
+            Resource:   &meta.Resource{Host: "domain.com", Path: "/", Method: "GET"},
+            Permission: &meta.Permission{AllowAnyone: true},
+        },
+        {
+            ID:         3,
+            Resource:   &meta.Resource{Host: "*", Path: "/*.html", Method: "GET"},
+            Permission: &meta.Permission{AllowAnyone: true},
+        },
+        {
+            ID:         4,
+            Resource:   &meta.Resource{Host: "*", Path: "/articles/hot", Method: "GET"},
+            Permission: &meta.Permission{AuthorizedRoles: []string{"guest", "reader"}},
+        },
+    }
+    report := Export(rules, Hierarchy{"alice": {"editor"}})
+    assert.Equal(t, `p, reader, /articles/*, *, allow
+p, editor, /articles/:p2, GET, allow
+p, editor, /articles/:p2, PUT, allow
+p, guest, /articles/:p2, GET, deny
+p, guest, /articles/:p2, PUT, deny
+p, guest, /articles/hot, GET, allow
+p, reader, /articles/hot, GET, allow
+g, alice, editor
+`, string(report.Policy))
+    var issues []string
+    for _, issue := range report.Issues {
+        issues = append(issues, issue.String())
+    }
+    assert.Equal(t, []string{
+        "rule 0 (* /articles/**): the any role(*) is not supported",
+        "rule 2 (GET /): hosts are not supported",
+        "rule 3 (GET /*.html): the path can not be converted into keyMatch2",
+        "rule 1 ({GET,PUT} /articles/*): Casbin grants reader by rule 0 (* /articles/**), grbac only uses the matched rule with the highest ID",
+        "rule 4 (GET /articles/hot): Casbin grants editor by rule 1 ({GET,PUT} /articles/*), grbac only uses the matched rule with the highest ID",
+        "rule 4 (GET /articles/hot): Casbin denies guest by rule 1 ({GET,PUT} /articles/*), grbac only uses the matched rule with the highest ID",
+    }, issues)
+
+    imported, err := Import(report.Policy, nil)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, 4, len(imported.Rules))
+}
@@ -0,0 +1,213 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package casbin
+
+import (
+    "bytes"
+    "fmt"
+    "sort"
+    "strings"
+
+    "github.com/storyicon/grbac/pkg/meta"
+    "github.com/storyicon/grbac/pkg/probe"
+)
+
+// Model is the Casbin model matching the policies generated by Export
+const Model = `[request_definition]
+r = sub, obj, act
+
+[policy_definition]
+p = sub, obj, act, eft
+
+[role_definition]
+g = _, _
+
+[policy_effect]
+e = some(where (p.eft == allow)) && !some(where (p.eft == deny))
+
+[matchers]
+m = g(r.sub, p.sub) && keyMatch2(r.obj, p.obj) && (r.act == p.act || p.act == "*")
+`
+
+// ExportReport is the result of an export
+type ExportReport struct {
+    // Policy is the generated policy in csv
+    Policy []byte
+    // Issues are the rules or parts of rules that are skipped
+    Issues []*Issue
+}
+
+// Export is used to convert rules and a role hierarchy into Casbin policy lines for Model.
+// Hosts, the any role(*), AllowAnyone and wildcards other than path segments can not be represented,
+// they are reported in the ExportReport. Casbin combines the effects of all the matched policies,
+// so the roles decided differently than by the matched rule with the highest ID are reported as well.
+func Export(rules meta.Rules, hierarchy Hierarchy) *ExportReport {
+    report := &ExportReport{}
+    buffer := &bytes.Buffer{}
+    var exported meta.Rules
+    for _, rule := range rules {
+        if rule.Resource == nil || rule.Permission == nil {
+            continue
+        }
+        text := fmt.Sprintf("rule %d (%s %s)", rule.ID, rule.Method, rule.Path)
+        issue := func(reason string) {
+            report.Issues = append(report.Issues, &Issue{Text: text, Reason: reason})
+        }
+        if rule.Host != "*" && rule.Host != "**" {
+            issue("hosts are not supported")
+            continue
+        }
+        object, ok := exportPath(rule.Path)
+        if !ok {
+            issue("the path can not be converted into keyMatch2")
+            continue
+        }
+        actions, ok := exportMethod(rule.Method)
+        if !ok {
+            issue("the method can not be converted into actions")
+            continue
+        }
+        if rule.AllowAnyone {
+            issue("allow_anyone is not supported")
+            continue
+        }
+        write := func(roles []string, effect string) {
+            for _, role := range roles {
+                if role == "*" {
+                    issue("the any role(*) is not supported")
+                    continue
+                }
+                for _, action := range actions {
+                    fmt.Fprintf(buffer, "p, %s, %s, %s, %s\n", role, object, action, effect)
+                }
+            }
+        }
+        write(rule.AuthorizedRoles, EffectAllow)
+        write(rule.ForbiddenRoles, EffectDeny)
+        exported = append(exported, rule)
+    }
+    report.Issues = append(report.Issues, combined(exported)...)
+
+    subjects := make([]string, 0, len(hierarchy))
+    for subject := range hierarchy {
+        subjects = append(subjects, subject)
+    }
+    sort.Strings(subjects)
+    for _, subject := range subjects {
+        for _, role := range hierarchy[subject] {
+            fmt.Fprintf(buffer, "g, %s, %s\n", subject, role)
+        }
+    }
+    report.Policy = buffer.Bytes()
+    return report
+}
+
+// exportPath is used to convert a resource path into a keyMatch2 object
+func exportPath(p string) (string, bool) {
+    segments := strings.Split(p, "/")
+    for i, segment := range segments {
+        switch {
+        case segment == "**":
+            if i != len(segments)-1 {
+                return "", false
+            }
+            segments[i] = "*"
+        case segment == "*":
+            segments[i] = fmt.Sprintf(":p%d", i)
+        case strings.ContainsAny(segment, "*?[]{}\\,"):
+            return "", false
+        }
+    }
+    return strings.Join(segments, "/"), true
+}
+
+// exportMethod is used to convert a method pattern into actions
+func exportMethod(method string) ([]string, bool) {
+    if method == "*" {
+        return []string{"*"}, true
+    }
+    if strings.HasPrefix(method, "{") && strings.HasSuffix(method, "}") {
+        method = method[1 : len(method)-1]
+    }
+    actions := strings.Split(method, ",")
+    for _, action := range actions {
+        if action == "" || strings.ContainsAny(action, "*?[]{}\\") {
+            return nil, false
+        }
+    }
+    return actions, true
+}
+
+// combined is used to report the roles that Casbin decides differently on the queries matched by two rules.
+// grbac only uses the matched rule with the highest ID, while Casbin grants a role allowed by
+// any of the matched policies and denied by none of them.
+func combined(rules meta.Rules) []*Issue {
+    sorted := append(meta.Rules(nil), rules...)
+    sort.SliceStable(sorted, func(i, j int) bool {
+        return sorted[i].ID < sorted[j].ID
+    })
+    var issues []*Issue
+    for i, general := range sorted {
+        for _, specific := range sorted[i+1:] {
+            if specific.ID == general.ID {
+                continue
+            }
+            query, err := probe.Query(specific.Resource)
+            if err != nil {
+                continue
+            }
+            matched, err := general.Resource.Match(query)
+            if err != nil || !matched {
+                continue
+            }
+            var roles []string
+            for _, list := range [][]string{general.AuthorizedRoles, general.ForbiddenRoles, specific.AuthorizedRoles, specific.ForbiddenRoles} {
+                for _, role := range list {
+                    if role != "*" {
+                        roles = appendRole(roles, role)
+                    }
+                }
+            }
+            for _, role := range roles {
+                granted := contains(specific.AuthorizedRoles, role) && !contains(specific.ForbiddenRoles, role)
+                allowed := contains(general.AuthorizedRoles, role) || contains(specific.AuthorizedRoles, role)
+                denied := contains(general.ForbiddenRoles, role) || contains(specific.ForbiddenRoles, role)
+                if granted == (allowed && !denied) {
+                    continue
+                }
+                effect := "grants"
+                if granted {
+                    effect = "denies"
+                }
+                issues = append(issues, &Issue{
+                    Text: fmt.Sprintf("rule %d (%s %s)", specific.ID, specific.Method, specific.Path),
+                    Reason: fmt.Sprintf("Casbin %s %s by rule %d (%s %s), grbac only uses the matched rule with the highest ID",
+                        effect, role, general.ID, general.Method, general.Path),
+                })
+            }
+        }
+    }
+    return issues
+}
+
+// contains is used to determine whether the roles contain the role
+func contains(roles []string, role string) bool {
+    for _, r := range roles {
+        if r == role {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,339 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diff compares two rule sets by their behaviour.
+//
+// Besides the rules added, removed or changed by ID, the rule sets are evaluated against
+// a set of requests, and the (request, role) pairs whose access changes are reported.
+// When no request is given, the requests are derived from the resources of both rule sets,
+// one sample request for each alternative of their braces.
+package diff
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "net/url"
+    "reflect"
+    "sort"
+    "strings"
+
+    "github.com/storyicon/grbac/pkg/matrix"
+    "github.com/storyicon/grbac/pkg/meta"
+    "github.com/storyicon/grbac/pkg/probe"
+)
+
+// Kind defines the kind of a rule change
+type Kind string
+
+// define the kinds of rule changes
+const (
+    KindAdded   Kind = "added"
+    KindRemoved Kind = "removed"
+    KindChanged Kind = "changed"
+)
+
+// Change defines a rule added, removed or changed
+type Change struct {
+    Kind Kind
+    ID   int
+    // Old is nil when the rule is added, New is nil when the rule is removed
+    Old *meta.Rule
+    New *meta.Rule
+}
+
+// Impact defines a request of a role whose access changes
+type Impact struct {
+    Query *meta.Query
+    // Role is the role of the request, matrix.Anonymous for the requests without any role
+    Role string
+    Old  meta.PermissionState
+    New  meta.PermissionState
+}
+
+// String is used to describe the impact
+func (impact *Impact) String() string {
+    return fmt.Sprintf("%s %s%s [%s]: %s -> %s",
+        impact.Query.Method, impact.Query.Host, impact.Query.Path, impact.Role, impact.Old, impact.New)
+}
+
+// Options defines the options of Compare
+type Options struct {
+    // Roles are the roles of the requests, derived from both rule sets when empty.
+    // The requests without any role are always evaluated.
+    Roles []string
+    // Queries are the requests to evaluate, derived from the resources of both rule sets when empty
+    Queries []*meta.Query
+}
+
+// Report defines the difference of two rule sets
+type Report struct {
+    Changes []*Change
+    Impacts []*Impact
+}
+
+// Rules is used to compare the rules of two rule sets by ID.
+// Several rules may share an ID, the rules of an ID are compared as a multiset:
+// the identical rules are ignored, the remaining ones are paired by resource first,
+// then in order, and the rules left unpaired are reported as added or removed.
+func Rules(old, new meta.Rules) []*Change {
+    olds, news := index(old), index(new)
+    var changes []*Change
+    for id, rules := range olds {
+        if _, ok := news[id]; !ok {
+            for _, rule := range rules {
+                changes = append(changes, &Change{Kind: KindRemoved, ID: id, Old: rule})
+            }
+        }
+    }
+    for id, rules := range news {
+        changes = append(changes, compare(id, olds[id], rules)...)
+    }
+    sort.SliceStable(changes, func(i, j int) bool {
+        if changes[i].ID != changes[j].ID {
+            return changes[i].ID < changes[j].ID
+        }
+        return kindOrder[changes[i].Kind] < kindOrder[changes[j].Kind]
+    })
+    return changes
+}
+
+// kindOrder defines the order of the changes of the same ID
+var kindOrder = map[Kind]int{
+    KindChanged: 0,
+    KindRemoved: 1,
+    KindAdded:   2,
+}
+
+// compare is used to compare the rules sharing the same ID
+func compare(id int, olds, news meta.Rules) []*Change {
+    olds = append(meta.Rules(nil), olds...)
+    var pending meta.Rules
+    for _, rule := range news {
+        if i := find(olds, func(previous *meta.Rule) bool { return equal(previous, rule) }); i >= 0 {
+            olds = append(olds[:i], olds[i+1:]...)
+            continue
+        }
+        pending = append(pending, rule)
+    }
+    var changes []*Change
+    var added meta.Rules
+    for _, rule := range pending {
+        i := find(olds, func(previous *meta.Rule) bool { return sameResource(previous, rule) })
+        if i < 0 {
+            added = append(added, rule)
+            continue
+        }
+        changes = append(changes, &Change{Kind: KindChanged, ID: id, Old: olds[i], New: rule})
+        olds = append(olds[:i], olds[i+1:]...)
+    }
+    for _, rule := range added {
+        if len(olds) == 0 {
+            changes = append(changes, &Change{Kind: KindAdded, ID: id, New: rule})
+            continue
+        }
+        changes = append(changes, &Change{Kind: KindChanged, ID: id, Old: olds[0], New: rule})
+        olds = olds[1:]
+    }
+    for _, rule := range olds {
+        changes = append(changes, &Change{Kind: KindRemoved, ID: id, Old: rule})
+    }
+    return changes
+}
+
+// find is used to find the index of the first rule satisfying the predicate, -1 if none
+func find(rules meta.Rules, predicate func(*meta.Rule) bool) int {
+    for i, rule := range rules {
+        if predicate(rule) {
+            return i
+        }
+    }
+    return -1
+}
+
+// index is used to group the rules by ID
+func index(rules meta.Rules) map[int]meta.Rules {
+    m := make(map[int]meta.Rules, len(rules))
+    for _, rule := range rules {
+        m[rule.ID] = append(m[rule.ID], rule)
+    }
+    return m
+}
+
+// sameResource is used to determine whether two rules have the same resource
+func sameResource(a, b *meta.Rule) bool {
+    var ra, rb meta.Resource
+    if a.Resource != nil {
+        ra = *a.Resource
+    }
+    if b.Resource != nil {
+        rb = *b.Resource
+    }
+    return ra == rb
+}
+
+// equal is used to determine whether two rules have the same resource and permission,
+// an empty list of roles equals a nil one.
+func equal(a, b *meta.Rule) bool {
+    if !sameResource(a, b) {
+        return false
+    }
+    var pa, pb meta.Permission
+    if a.Permission != nil {
+        pa = *a.Permission
+    }
+    if b.Permission != nil {
+        pb = *b.Permission
+    }
+    return pa.AllowAnyone == pb.AllowAnyone &&
+        sameRoles(pa.AuthorizedRoles, pb.AuthorizedRoles) &&
+        sameRoles(pa.ForbiddenRoles, pb.ForbiddenRoles)
+}
+
+// sameRoles is used to compare two lists of roles
+func sameRoles(a, b []string) bool {
+    if len(a) == 0 && len(b) == 0 {
+        return true
+    }
+    return reflect.DeepEqual(a, b)
+}
+
+// Compare is used to compare two rule sets by their rules and their behaviour
+func Compare(old, new meta.Rules, opts Options) (*Report, error) {
+    roles := opts.Roles
+    if len(roles) == 0 {
+        roles = matrix.Roles(append(append(meta.Rules(nil), old...), new...))
+    }
+    roles = append([]string{matrix.Anonymous}, roles...)
+    queries := opts.Queries
+    if len(queries) == 0 {
+        var err error
+        queries, err = Derive(old, new)
+        if err != nil {
+            return nil, err
+        }
+    }
+    report := &Report{
+        Changes: Rules(old, new),
+    }
+    for _, query := range queries {
+        olds, err := match(old, query)
+        if err != nil {
+            return nil, err
+        }
+        news, err := match(new, query)
+        if err != nil {
+            return nil, err
+        }
+        for _, role := range roles {
+            var requestRoles []string
+            if role != matrix.Anonymous {
+                requestRoles = []string{role}
+            }
+            before, err := olds.IsRolesGranted(requestRoles)
+            if err != nil {
+                return nil, err
+            }
+            after, err := news.IsRolesGranted(requestRoles)
+            if err != nil {
+                return nil, err
+            }
+            if before != after {
+                report.Impacts = append(report.Impacts, &Impact{Query: query, Role: role, Old: before, New: after})
+            }
+        }
+    }
+    return report, nil
+}
+
+// match is used to find the rules matching the query
+func match(rules meta.Rules, query *meta.Query) (meta.Rules, error) {
+    var matched meta.Rules
+    for _, rule := range rules {
+        if rule.Resource == nil || rule.Permission == nil {
+            continue
+        }
+        ok, err := rule.Resource.Match(query)
+        if err != nil {
+            return nil, err
+        }
+        if ok {
+            matched = append(matched, rule)
+        }
+    }
+    return matched, nil
+}
+
+// Derive is used to generate a sample request for each alternative of the braces
+// of the resources of the rule sets, the duplicated requests are removed.
+func Derive(sets ...meta.Rules) ([]*meta.Query, error) {
+    var queries []*meta.Query
+    seen := map[meta.Query]bool{}
+    for _, rules := range sets {
+        for _, rule := range rules {
+            if rule.Resource == nil {
+                continue
+            }
+            for _, host := range probe.Expand(rule.Host) {
+                for _, p := range probe.Expand(rule.Path) {
+                    for _, method := range probe.Expand(rule.Method) {
+                        query, err := probe.Query(&meta.Resource{Host: host, Path: p, Method: method})
+                        if err != nil {
+                            return nil, err
+                        }
+                        if seen[*query] {
+                            continue
+                        }
+                        seen[*query] = true
+                        queries = append(queries, query)
+                    }
+                }
+            }
+        }
+    }
+    return queries, nil
+}
+
+// ParseQueries is used to read the requests of a file, one request per line:
+//  DELETE https://domain.com/article/1
+// Empty lines and the lines starting with # are ignored.
+func ParseQueries(r io.Reader) ([]*meta.Query, error) {
+    var queries []*meta.Query
+    scanner := bufio.NewScanner(r)
+    line := 0
+    for scanner.Scan() {
+        line++
+        text := strings.TrimSpace(scanner.Text())
+        if text == "" || strings.HasPrefix(text, "#") {
+            continue
+        }
+        fields := strings.Fields(text)
+        if len(fields) != 2 {
+            return nil, fmt.Errorf("line %d: expected <method> <url>, got %q", line, text)
+        }
+        u, err := url.Parse(fields[1])
+        if err != nil {
+            return nil, fmt.Errorf("line %d: %s", line, err)
+        }
+        queries = append(queries, &meta.Query{
+            Host:   u.Host,
+            Path:   u.Path,
+            Method: strings.ToUpper(fields[0]),
+        })
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, err
+    }
+    return queries, nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+    "strings"
+    "testing"
+
+    "github.com/storyicon/grbac/pkg/matrix"
+    "github.com/storyicon/grbac/pkg/meta"
+    "github.com/stretchr/testify/assert"
+)
+
+var oldRules = meta.Rules{
+    {
+        ID:         0,
+        Resource:   &meta.Resource{Host: "*", Path: "**", Method: "*"},
+        Permission: &meta.Permission{AuthorizedRoles: []string{"*"}},
+    },
+    {
+        ID:         1,
+        Resource:   &meta.Resource{Host: "*", Path: "/articles/*", Method: "{DELETE,PUT}"},
+        Permission: &meta.Permission{AuthorizedRoles: []string{"editor"}},
+    },
+    {
+        ID:         2,
+        Resource:   &meta.Resource{Host: "*", Path: "/login", Method: "POST"},
+        Permission: &meta.Permission{AllowAnyone: true},
+    },
+}
+
+var newRules = meta.Rules{
+    {
+        ID:         0,
+        Resource:   &meta.Resource{Host: "*", Path: "**", Method: "*"},
+        Permission: &meta.Permission{AuthorizedRoles: []string{"*"}, ForbiddenRoles: []string{}},
+    },
+    {
+        ID:         1,
+        Resource:   &meta.Resource{Host: "*", Path: "/articles/*", Method: "DELETE"},
+        Permission: &meta.Permission{AuthorizedRoles: []string{"editor"}},
+    },
+    {
+        ID:         3,
+        Resource:   &meta.Resource{Host: "*", Path: "/admin/**", Method: "*"},
+        Permission: &meta.Permission{AuthorizedRoles: []string{"admin"}},
+    },
+}
+
+func TestRules(t *testing.T) {
+    changes := Rules(oldRules, newRules)
+    assert.Equal(t, 3, len(changes))
+    assert.Equal(t, &Change{Kind: KindChanged, ID: 1, Old: oldRules[1], New: newRules[1]}, changes[0])
+    assert.Equal(t, &Change{Kind: KindRemoved, ID: 2, Old: oldRules[2]}, changes[1])
+    assert.Equal(t, &Change{Kind: KindAdded, ID: 3, New: newRules[2]}, changes[2])
+}
+
+func TestRulesSharedID(t *testing.T) {
+    rule := func(path string, roles ...string) *meta.Rule {
+        return &meta.Rule{
+            ID:         1,
+            Resource:   &meta.Resource{Host: "*", Path: path, Method: "*"},
+            Permission: &meta.Permission{AuthorizedRoles: roles},
+        }
+    }
+    old := meta.Rules{rule("/a", "admin"), rule("/b", "admin")}
+    tests := []struct {
+        name string
+        new  meta.Rules
+        want []*Change
+    }{
+        {
+            name: "test0",
+            new:  meta.Rules{rule("/c", "admin"), rule("/b", "admin")},
+            want: []*Change{{Kind: KindChanged, ID: 1, Old: old[0], New: rule("/c", "admin")}},
+        },
+        {
+            name: "test1",
+            new:  meta.Rules{rule("/b", "admin"), rule("/a", "editor")},
+            want: []*Change{{Kind: KindChanged, ID: 1, Old: old[0], New: rule("/a", "editor")}},
+        },
+        {
+            name: "test2",
+            new:  meta.Rules{rule("/b", "admin")},
+            want: []*Change{{Kind: KindRemoved, ID: 1, Old: old[0]}},
+        },
+        {
+            name: "test3",
+            new:  meta.Rules{rule("/b", "admin"), rule("/a", "admin"), rule("/b", "admin")},
+            want: []*Change{{Kind: KindAdded, ID: 1, New: rule("/b", "admin")}},
+        },
+        {
+            name: "test4",
+            new:  meta.Rules{rule("/b", "admin"), rule("/a", "admin")},
+        },
+    }
+    for _, tt := range tests {
+        assert.Equal(t, tt.want, Rules(old, tt.new), tt.name)
+    }
+}
+
+func TestCompare(t *testing.T) {
+    report, err := Compare(oldRules, newRules, Options{Roles: []string{"editor", "admin"}})
+    assert.Equal(t, nil, err)
+    assert.Equal(t, 3, len(report.Changes))
+
+    var impacts []string
+    for _, impact := range report.Impacts {
+        impacts = append(impacts, impact.String())
+    }
+    assert.Equal(t, []string{
+        "PUT sample/articles/sample [admin]: Permission Ungranted -> Permission Granted",
+        "POST sample/login [" + matrix.Anonymous + "]: Permission Granted -> Permission Ungranted",
+        "GET sample/admin/sample/sample [editor]: Permission Granted -> Permission Ungranted",
+    }, impacts)
+
+    queries := []*meta.Query{{Host: "domain.com", Path: "/articles/1", Method: "DELETE"}}
+    report, err = Compare(oldRules, newRules, Options{Roles: []string{"editor"}, Queries: queries})
+    assert.Equal(t, nil, err)
+    assert.Equal(t, 0, len(report.Impacts))
+}
+
+func TestParseQueries(t *testing.T) {
+    queries, err := ParseQueries(strings.NewReader(`
+# comment
+delete https://domain.com/articles/1
+GET /login
+`))
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []*meta.Query{
+        {Host: "domain.com", Path: "/articles/1", Method: "DELETE"},
+        {Host: "", Path: "/login", Method: "GET"},
+    }, queries)
+
+    _, err = ParseQueries(strings.NewReader("GET"))
+    assert.NotEqual(t, nil, err)
+}
@@ -0,0 +1,82 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extractor
+
+import (
+    "container/list"
+    "sync"
+    "time"
+)
+
+// entry defines a verified token in the cache
+type entry struct {
+    sum    [32]byte
+    roles  []string
+    expiry time.Time
+}
+
+// cache keeps the roles of the recently verified tokens, the least recently used one is evicted when it's full
+type cache struct {
+    size    int
+    entries map[[32]byte]*list.Element
+    order   *list.List
+    lock    sync.Mutex
+}
+
+// newCache is used to create a cache, which keeps nothing when the size is not positive
+func newCache(size int) *cache {
+    return &cache{
+        size:    size,
+        entries: map[[32]byte]*list.Element{},
+        order:   list.New(),
+    }
+}
+
+// get is used to get a copy of the roles of an unexpired token
+func (c *cache) get(sum [32]byte, now time.Time) ([]string, bool) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    element, ok := c.entries[sum]
+    if !ok {
+        return nil, false
+    }
+    e := element.Value.(*entry)
+    if !now.Before(e.expiry) {
+        c.order.Remove(element)
+        delete(c.entries, sum)
+        return nil, false
+    }
+    c.order.MoveToFront(element)
+    return append([]string(nil), e.roles...), true
+}
+
+// set is used to keep the roles of a token until the expiry
+func (c *cache) set(sum [32]byte, roles []string, expiry time.Time) {
+    if c.size <= 0 {
+        return
+    }
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    if element, ok := c.entries[sum]; ok {
+        c.order.Remove(element)
+        delete(c.entries, sum)
+    }
+    for c.order.Len() >= c.size {
+        oldest := c.order.Back()
+        c.order.Remove(oldest)
+        delete(c.entries, oldest.Value.(*entry).sum)
+    }
+    c.entries[sum] = c.order.PushFront(&entry{sum: sum, roles: roles, expiry: expiry})
+}
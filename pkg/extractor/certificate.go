@@ -0,0 +1,238 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extractor
+
+import (
+    "crypto/x509"
+    "encoding/pem"
+    "errors"
+    "net/http"
+    "net/url"
+    "strings"
+
+    "github.com/storyicon/grbac/pkg/path"
+)
+
+// ErrUnknownSource is returned when the source of a mapping is not supported
+var ErrUnknownSource = errors.New("unknown certificate identity source")
+
+// Source defines the identities of a client certificate a mapping is matched against
+type Source string
+
+// define the sources of the identities
+const (
+    // SourceURI matches the URI SANs, such as the SPIFFE ID spiffe://domain.com/ns/default/sa/web
+    SourceURI Source = "uri"
+    // SourceDNS matches the DNS SANs
+    SourceDNS Source = "dns"
+    // SourceCN matches the common name of the subject
+    SourceCN Source = "cn"
+    // SourceOU matches the organizational units of the subject
+    SourceOU Source = "ou"
+)
+
+// Mapping grants roles to the certificates with an identity matching the pattern
+type Mapping struct {
+    Source Source
+    // Pattern uses the wildcards of the rules, * does not match / and ** matches any identity,
+    // such as spiffe://domain.com/ns/*/sa/web
+    Pattern string
+    Roles   []string
+}
+
+// CertificateOptions defines the options of the client certificate extractor
+type CertificateOptions struct {
+    // Mappings are matched in order and the roles of all the matched mappings are returned
+    Mappings []*Mapping
+    // Header is the header of the client certificate forwarded by a trusted proxy,
+    // which terminates the mutual TLS connection of the client, such as the x-forwarded-client-cert
+    // of Envoy, whose Cert field is used, or a header set to the $ssl_client_escaped_cert of nginx.
+    // When it is set, the certificate of the connection to grbac, which is the proxy's one, is ignored.
+    Header string
+    // Roots verify the forwarded client certificates when they are not nil,
+    // otherwise they are trusted as verified by the proxy, and the header is only honored
+    // on the connections with a verified certificate, which must be the proxy's one
+    Roots *x509.CertPool
+}
+
+// Certificate extracts the roles of the verified client certificate of a mutual TLS connection
+type Certificate struct {
+    mappings []*Mapping
+    header   string
+    roots    *x509.CertPool
+}
+
+// NewCertificate is used to create a client certificate extractor.
+// Without Header, the certificates of the connections to grbac are used, which must be verified
+// by the server, such as with tls.RequireAndVerifyClientCert, so the clients must call grbac directly.
+func NewCertificate(opts CertificateOptions) (*Certificate, error) {
+    for _, mapping := range opts.Mappings {
+        switch mapping.Source {
+        case SourceURI, SourceDNS, SourceCN, SourceOU:
+        default:
+            return nil, ErrUnknownSource
+        }
+        if _, err := path.Match(mapping.Pattern, ""); err != nil {
+            return nil, err
+        }
+    }
+    return &Certificate{
+        mappings: opts.Mappings,
+        header:   opts.Header,
+        roots:    opts.Roots,
+    }, nil
+}
+
+// Extract is used to map the identities of the client certificate to roles,
+// a request without client certificate is anonymous and an unverified certificate returns ErrUnauthenticated
+func (c *Certificate) Extract(r *http.Request) ([]string, error) {
+    if c.header != "" {
+        return c.extractForwarded(r)
+    }
+    if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+        return nil, nil
+    }
+    if len(r.TLS.VerifiedChains) == 0 {
+        return nil, ErrUnauthenticated
+    }
+    return c.Roles(r.TLS.PeerCertificates[0])
+}
+
+// extractForwarded is used to map the identities of the client certificate forwarded by the proxy to roles
+func (c *Certificate) extractForwarded(r *http.Request) ([]string, error) {
+    value := r.Header.Get(c.header)
+    if value == "" {
+        return nil, nil
+    }
+    // anyone can set the header, which is trusted only if the proxy is authenticated
+    if c.roots == nil && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+        return nil, ErrUnauthenticated
+    }
+    cert, err := parseForwarded(value)
+    if err != nil {
+        return nil, ErrUnauthenticated
+    }
+    if c.roots != nil {
+        _, err := cert.Verify(x509.VerifyOptions{
+            Roots:     c.roots,
+            KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+        })
+        if err != nil {
+            return nil, ErrUnauthenticated
+        }
+    }
+    return c.Roles(cert)
+}
+
+// parseForwarded is used to parse the url encoded PEM certificate of a forwarded header,
+// either the whole value or the Cert field of the last element of a x-forwarded-client-cert:
+//  By=spiffe://domain.com/grbac;Hash=...;Cert="-----BEGIN%20CERTIFICATE-----...";URI=spiffe://domain.com/web
+// The Cert field is only set by Envoy when set_current_client_cert_details enables cert.
+func parseForwarded(value string) (*x509.Certificate, error) {
+    encoded := value
+    if !strings.HasPrefix(value, "-----BEGIN") {
+        elements := splitQuoted(value, ',')
+        encoded = ""
+        for _, pair := range splitQuoted(elements[len(elements)-1], ';') {
+            equal := strings.IndexByte(pair, '=')
+            if equal >= 0 && strings.EqualFold(strings.TrimSpace(pair[:equal]), "Cert") {
+                encoded = strings.Trim(strings.TrimSpace(pair[equal+1:]), `"`)
+            }
+        }
+    }
+    // the + of base64 is not escaped by nginx, so it must not be unescaped to a space
+    decoded, err := url.PathUnescape(encoded)
+    if err != nil {
+        return nil, err
+    }
+    block, _ := pem.Decode([]byte(decoded))
+    if block == nil || block.Type != "CERTIFICATE" {
+        return nil, errors.New("no certificate found")
+    }
+    return x509.ParseCertificate(block.Bytes)
+}
+
+// splitQuoted is used to split a value by the separator outside of the double quotes
+func splitQuoted(value string, sep byte) []string {
+    var parts []string
+    quoted, start := false, 0
+    for i := 0; i < len(value); i++ {
+        switch {
+        case value[i] == '\\' && quoted:
+            i++
+        case value[i] == '"':
+            quoted = !quoted
+        case value[i] == sep && !quoted:
+            parts = append(parts, value[start:i])
+            start = i + 1
+        }
+    }
+    return append(parts, value[start:])
+}
+
+// identities is used to get the identities of a certificate from a source
+func identities(cert *x509.Certificate, source Source) []string {
+    switch source {
+    case SourceURI:
+        uris := make([]string, 0, len(cert.URIs))
+        for _, uri := range cert.URIs {
+            uris = append(uris, uri.String())
+        }
+        return uris
+    case SourceDNS:
+        return cert.DNSNames
+    case SourceCN:
+        if cert.Subject.CommonName == "" {
+            return nil
+        }
+        return []string{cert.Subject.CommonName}
+    case SourceOU:
+        return cert.Subject.OrganizationalUnit
+    }
+    return nil
+}
+
+// Roles is used to get the roles of a certificate, without duplicates
+func (c *Certificate) Roles(cert *x509.Certificate) ([]string, error) {
+    var roles []string
+    seen := map[string]bool{}
+    for _, mapping := range c.mappings {
+        matched, err := c.match(mapping, cert)
+        if err != nil {
+            return nil, err
+        }
+        if !matched {
+            continue
+        }
+        for _, role := range mapping.Roles {
+            if !seen[role] {
+                seen[role] = true
+                roles = append(roles, role)
+            }
+        }
+    }
+    return roles, nil
+}
+
+// match is used to determine whether an identity of the certificate matches the mapping
+func (c *Certificate) match(mapping *Mapping, cert *x509.Certificate) (bool, error) {
+    for _, identity := range identities(cert, mapping.Source) {
+        matched, err := path.Match(mapping.Pattern, identity)
+        if err != nil || matched {
+            return matched, err
+        }
+    }
+    return false, nil
+}
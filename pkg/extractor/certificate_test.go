@@ -0,0 +1,196 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extractor
+
+import (
+    "crypto/ecdsa"
+    "crypto/elliptic"
+    "crypto/rand"
+    "crypto/tls"
+    "crypto/x509"
+    "crypto/x509/pkix"
+    "encoding/pem"
+    "math/big"
+    "net/http/httptest"
+    "net/url"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/assert"
+)
+
+// certificate is used to create a self-signed client certificate
+func certificate(t *testing.T, template *x509.Certificate) *x509.Certificate {
+    key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    assert.Equal(t, nil, err)
+    template.SerialNumber = big.NewInt(1)
+    template.NotBefore = time.Now().Add(-time.Hour)
+    template.NotAfter = time.Now().Add(time.Hour)
+    template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+    der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+    assert.Equal(t, nil, err)
+    cert, err := x509.ParseCertificate(der)
+    assert.Equal(t, nil, err)
+    return cert
+}
+
+func TestCertificate_Roles(t *testing.T) {
+    c, err := NewCertificate(CertificateOptions{
+        Mappings: []*Mapping{
+            {Source: SourceURI, Pattern: "spiffe://domain.com/ns/*/sa/web", Roles: []string{"web"}},
+            {Source: SourceURI, Pattern: "spiffe://domain.com/**", Roles: []string{"service"}},
+            {Source: SourceDNS, Pattern: "*.internal.domain.com", Roles: []string{"internal"}},
+            {Source: SourceCN, Pattern: "admin-{1,2}", Roles: []string{"admin", "service"}},
+            {Source: SourceOU, Pattern: "billing", Roles: []string{"billing"}},
+        },
+    })
+    assert.Equal(t, nil, err)
+    web, _ := url.Parse("spiffe://domain.com/ns/default/sa/web")
+    worker, _ := url.Parse("spiffe://domain.com/ns/default/sa/worker")
+    other, _ := url.Parse("spiffe://other.com/ns/default/sa/web")
+
+    tests := []struct {
+        name  string
+        cert  *x509.Certificate
+        roles []string
+    }{
+        {name: "test0", cert: &x509.Certificate{URIs: []*url.URL{web}}, roles: []string{"web", "service"}},
+        {name: "test1", cert: &x509.Certificate{URIs: []*url.URL{worker}}, roles: []string{"service"}},
+        {name: "test2", cert: &x509.Certificate{URIs: []*url.URL{other}}, roles: nil},
+        {name: "test3", cert: &x509.Certificate{DNSNames: []string{"api.domain.com", "api.internal.domain.com"}}, roles: []string{"internal"}},
+        {name: "test4", cert: &x509.Certificate{Subject: pkix.Name{CommonName: "admin-2"}, URIs: []*url.URL{worker}}, roles: []string{"service", "admin"}},
+        {name: "test5", cert: &x509.Certificate{Subject: pkix.Name{CommonName: "admin-3", OrganizationalUnit: []string{"sales", "billing"}}}, roles: []string{"billing"}},
+    }
+    for _, tt := range tests {
+        roles, err := c.Roles(tt.cert)
+        assert.Equal(t, nil, err, tt.name)
+        assert.Equal(t, tt.roles, roles, tt.name)
+    }
+}
+
+func TestCertificate_Extract(t *testing.T) {
+    c, err := NewCertificate(CertificateOptions{
+        Mappings: []*Mapping{
+            {Source: SourceURI, Pattern: "spiffe://domain.com/**", Roles: []string{"service"}},
+        },
+    })
+    assert.Equal(t, nil, err)
+    uri, _ := url.Parse("spiffe://domain.com/ns/default/sa/web")
+    cert := certificate(t, &x509.Certificate{URIs: []*url.URL{uri}})
+
+    r := httptest.NewRequest("GET", "/", nil)
+    r.TLS = nil
+    roles, err := c.Extract(r)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []string(nil), roles)
+
+    r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+    _, err = c.Extract(r)
+    assert.Equal(t, ErrUnauthenticated, err)
+
+    r.TLS.VerifiedChains = [][]*x509.Certificate{{cert}}
+    roles, err = c.Extract(r)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []string{"service"}, roles)
+}
+
+func TestCertificate_ExtractForwarded(t *testing.T) {
+    uri, _ := url.Parse("spiffe://domain.com/ns/default/sa/web")
+    cert := certificate(t, &x509.Certificate{URIs: []*url.URL{uri}})
+    other := certificate(t, &x509.Certificate{URIs: []*url.URL{uri}})
+    encode := func(cert *x509.Certificate) string {
+        return url.PathEscape(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})))
+    }
+    roots := x509.NewCertPool()
+    roots.AddCert(cert)
+    c, err := NewCertificate(CertificateOptions{
+        Mappings: []*Mapping{
+            {Source: SourceURI, Pattern: "spiffe://domain.com/**", Roles: []string{"service"}},
+        },
+        Header: "X-Forwarded-Client-Cert",
+        Roots:  roots,
+    })
+    assert.Equal(t, nil, err)
+
+    tests := []struct {
+        name   string
+        header string
+        roles  []string
+        err    error
+    }{
+        {name: "test0", header: "", roles: nil},
+        {name: "test1", header: encode(cert), roles: []string{"service"}},
+        {name: "test2", header: `By=spiffe://domain.com/grbac;Hash=abc;Cert="` + encode(cert) + `";Subject="CN=web,OU=a;b";URI=` + uri.String(), roles: []string{"service"}},
+        {name: "test3", header: `Cert="` + encode(other) + `",By=spiffe://domain.com/grbac;Cert="` + encode(cert) + `"`, roles: []string{"service"}},
+        {name: "test4", header: `Cert="` + encode(cert) + `",By=spiffe://domain.com/grbac;Cert="` + encode(other) + `"`, err: ErrUnauthenticated},
+        {name: "test5", header: `By=spiffe://domain.com/grbac;URI=` + uri.String(), err: ErrUnauthenticated},
+        {name: "test6", header: "-----BEGIN%20CERTIFICATE-----%0Ainvalid", err: ErrUnauthenticated},
+    }
+    for _, tt := range tests {
+        r := httptest.NewRequest("GET", "/", nil)
+        // the certificate of the connection is the proxy's one, which is ignored
+        r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{other}, VerifiedChains: [][]*x509.Certificate{{other}}}
+        if tt.header != "" {
+            r.Header.Set("X-Forwarded-Client-Cert", tt.header)
+        }
+        roles, err := c.Extract(r)
+        assert.Equal(t, tt.err, err, tt.name)
+        assert.Equal(t, tt.roles, roles, tt.name)
+    }
+
+    // without roots, the header is only trusted on the verified connections of the proxy
+    c, err = NewCertificate(CertificateOptions{
+        Mappings: []*Mapping{
+            {Source: SourceURI, Pattern: "spiffe://domain.com/**", Roles: []string{"service"}},
+        },
+        Header: "X-Forwarded-Client-Cert",
+    })
+    assert.Equal(t, nil, err)
+    r := httptest.NewRequest("GET", "/", nil)
+    r.Header.Set("X-Forwarded-Client-Cert", encode(cert))
+    _, err = c.Extract(r)
+    assert.Equal(t, ErrUnauthenticated, err)
+
+    r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{other}}
+    _, err = c.Extract(r)
+    assert.Equal(t, ErrUnauthenticated, err)
+
+    r.TLS.VerifiedChains = [][]*x509.Certificate{{other}}
+    roles, err := c.Extract(r)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []string{"service"}, roles)
+}
+
+func TestParseForwarded(t *testing.T) {
+    cert := certificate(t, &x509.Certificate{})
+    data := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+    // nginx escapes the spaces and the newlines of $ssl_client_escaped_cert, but not the + of base64
+    escaped := strings.NewReplacer(" ", "%20", "\n", "%0A").Replace(data)
+    parsed, err := parseForwarded(escaped)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, cert.Raw, parsed.Raw)
+
+    parsed, err = parseForwarded(`By=spiffe://domain.com/grbac;Cert="` + escaped + `"`)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, cert.Raw, parsed.Raw)
+}
+
+func TestNewCertificate(t *testing.T) {
+    _, err := NewCertificate(CertificateOptions{
+        Mappings: []*Mapping{{Source: "email", Pattern: "*", Roles: []string{"user"}}},
+    })
+    assert.Equal(t, ErrUnknownSource, err)
+}
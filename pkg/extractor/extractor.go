@@ -0,0 +1,72 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package extractor extracts the roles of the visitor of a request,
+// which grbac leaves to its users, so that the ready-made handlers can be configured.
+package extractor
+
+import (
+    "errors"
+    "net/http"
+    "strings"
+)
+
+// ErrUnauthenticated is returned when the credentials of a request are invalid
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// RoleExtractor extracts the roles of the visitor of a request.
+// A request without credentials is anonymous, for which no role and no error are returned,
+// so that it can still access the resources allowing anyone.
+type RoleExtractor interface {
+    Extract(r *http.Request) ([]string, error)
+}
+
+// Func adapts a function to a RoleExtractor
+type Func func(r *http.Request) ([]string, error)
+
+// Extract is used to call the function
+func (f Func) Extract(r *http.Request) ([]string, error) {
+    return f(r)
+}
+
+// header extracts the roles from a header
+type header struct {
+    name string
+}
+
+// Header is used to extract the comma separated roles of a header,
+// which is set by a trusted proxy authenticating the visitor, such as X-Roles: editor,reviewer
+func Header(name string) RoleExtractor {
+    return &header{name: name}
+}
+
+// Extract is used to split the values of the header
+func (h *header) Extract(r *http.Request) ([]string, error) {
+    var roles []string
+    for _, value := range r.Header[http.CanonicalHeaderKey(h.name)] {
+        roles = append(roles, Split(value, ",")...)
+    }
+    return roles, nil
+}
+
+// Split is used to split roles by the separator, the spaces and the empty roles are removed
+func Split(s string, sep string) []string {
+    var roles []string
+    for _, role := range strings.Split(s, sep) {
+        if role = strings.TrimSpace(role); role != "" {
+            roles = append(roles, role)
+        }
+    }
+    return roles
+}
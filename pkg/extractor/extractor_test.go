@@ -0,0 +1,44 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extractor
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestHeader(t *testing.T) {
+    r := httptest.NewRequest("GET", "/", nil)
+    roles, err := Header("x-roles").Extract(r)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []string(nil), roles)
+
+    r.Header.Add("X-Roles", "editor, reviewer,")
+    r.Header.Add("X-Roles", "admin")
+    roles, err = Header("x-roles").Extract(r)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []string{"editor", "reviewer", "admin"}, roles)
+}
+
+func TestFunc(t *testing.T) {
+    extractor := Func(func(r *http.Request) ([]string, error) {
+        return nil, ErrUnauthenticated
+    })
+    _, err := extractor.Extract(httptest.NewRequest("GET", "/", nil))
+    assert.Equal(t, ErrUnauthenticated, err)
+}
@@ -0,0 +1,134 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extractor
+
+import (
+    "crypto/ecdsa"
+    "crypto/ed25519"
+    "crypto/elliptic"
+    "crypto/rsa"
+    "encoding/json"
+    "errors"
+    "io/ioutil"
+    "math/big"
+)
+
+// ErrInvalidJWK is returned when a key of a JWK Set is invalid
+var ErrInvalidJWK = errors.New("invalid json web key")
+
+// jwk defines a json web key
+type jwk struct {
+    KeyType   string `json:"kty"`
+    KeyID     string `json:"kid"`
+    Algorithm string `json:"alg"`
+    Use       string `json:"use"`
+    Curve     string `json:"crv"`
+    N         string `json:"n"`
+    E         string `json:"e"`
+    X         string `json:"x"`
+    Y         string `json:"y"`
+    K         string `json:"k"`
+}
+
+// jwkParsers parse the keys by their kty
+var jwkParsers = map[string]func(k *jwk) (interface{}, error){
+    "oct": func(k *jwk) (interface{}, error) {
+        return decodeSegment(k.K)
+    },
+    "RSA": func(k *jwk) (interface{}, error) {
+        n, err := decodeSegment(k.N)
+        if err != nil {
+            return nil, err
+        }
+        e, err := decodeSegment(k.E)
+        if err != nil {
+            return nil, err
+        }
+        exponent := new(big.Int).SetBytes(e)
+        if len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 2 || exponent.Int64() > 1<<31-1 {
+            return nil, ErrInvalidJWK
+        }
+        return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+    },
+    "EC": func(k *jwk) (interface{}, error) {
+        if k.Curve != "P-256" {
+            return nil, nil
+        }
+        x, err := decodeSegment(k.X)
+        if err != nil {
+            return nil, err
+        }
+        y, err := decodeSegment(k.Y)
+        if err != nil {
+            return nil, err
+        }
+        key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+        if !key.Curve.IsOnCurve(key.X, key.Y) {
+            return nil, ErrInvalidJWK
+        }
+        return key, nil
+    },
+    "OKP": func(k *jwk) (interface{}, error) {
+        if k.Curve != "Ed25519" {
+            return nil, nil
+        }
+        x, err := decodeSegment(k.X)
+        if err != nil {
+            return nil, err
+        }
+        if len(x) != ed25519.PublicKeySize {
+            return nil, ErrInvalidJWK
+        }
+        return ed25519.PublicKey(x), nil
+    },
+}
+
+// ParseJWKS is used to parse a JWK Set, the keys of unsupported types or not used for signatures are ignored
+func ParseJWKS(data []byte) ([]*Key, error) {
+    var set struct {
+        Keys []*jwk `json:"keys"`
+    }
+    if err := json.Unmarshal(data, &set); err != nil {
+        return nil, err
+    }
+    var keys []*Key
+    for _, k := range set.Keys {
+        if k == nil || (k.Use != "" && k.Use != "sig") {
+            continue
+        }
+        parse, ok := jwkParsers[k.KeyType]
+        if !ok {
+            continue
+        }
+        key, err := parse(k)
+        if err != nil {
+            return nil, ErrInvalidJWK
+        }
+        if key == nil {
+            continue
+        }
+        keys = append(keys, &Key{ID: k.KeyID, Algorithm: k.Algorithm, Key: key})
+    }
+    return keys, nil
+}
+
+// LoadJWKS is used to load a JWK Set file
+func LoadJWKS(name string) ([]*Key, error) {
+    data, err := ioutil.ReadFile(name)
+    if err != nil {
+        return nil, err
+    }
+    return ParseJWKS(data)
+}
@@ -0,0 +1,398 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extractor
+
+import (
+    "bytes"
+    "crypto"
+    "crypto/ecdsa"
+    "crypto/ed25519"
+    "crypto/elliptic"
+    "crypto/hmac"
+    "crypto/rsa"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "math/big"
+    "net/http"
+    "strings"
+    "time"
+)
+
+// define a set of errors of the tokens
+var (
+    ErrMalformedToken       = errors.New("malformed token")
+    ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+    ErrUnknownKey           = errors.New("no key to verify the token")
+    ErrInvalidSignature     = errors.New("invalid token signature")
+    ErrTokenExpired         = errors.New("token is expired")
+    ErrMissingExpiration    = errors.New("token has no expiration")
+    ErrTokenNotValidYet     = errors.New("token is not valid yet")
+    ErrInvalidIssuer        = errors.New("invalid token issuer")
+    ErrInvalidAudience      = errors.New("invalid token audience")
+    ErrInvalidClaim         = errors.New("invalid roles claim")
+)
+
+// define the defaults of the jwt extractor
+const (
+    DefaultJWTHeader = "Authorization"
+    DefaultJWTClaim  = "roles"
+    DefaultCacheSize = 1024
+    DefaultCacheTTL  = time.Minute
+)
+
+// Key defines a key verifying the tokens
+type Key struct {
+    // ID is matched against the kid of the tokens, a key without ID verifies the tokens of any kid
+    ID string
+    // Algorithm restricts the key to an algorithm, such as RS256, any algorithm fitting the key is accepted when empty
+    Algorithm string
+    // Key is a []byte secret for HS256, a *rsa.PublicKey for RS256,
+    // a *ecdsa.PublicKey on P-256 for ES256 or an ed25519.PublicKey for EdDSA
+    Key interface{}
+}
+
+// JWTOptions defines the options of the jwt extractor
+type JWTOptions struct {
+    // Keys verify the signature of the tokens, the keys of JWKS are appended to them
+    Keys []*Key
+    // JWKS is the name of a local JWK Set file
+    JWKS string
+    // Issuer is compared with the iss claim when it's not empty
+    Issuer string
+    // Audience contains the accepted values of the aud claim, one of which must be present when it's not empty
+    Audience []string
+    // Claim is the path of the roles claim, nested claims are separated by dots such as realm_access.roles,
+    // DefaultJWTClaim by default. The claim is an array of strings, or a string of roles separated by spaces such as scope
+    Claim string
+    // Header carries the token, with or without the Bearer scheme, DefaultJWTHeader by default
+    Header string
+    // RequireExpiration rejects the tokens without exp, which are otherwise valid forever
+    RequireExpiration bool
+    // Leeway tolerates the clock skew when validating exp and nbf
+    Leeway time.Duration
+    // CacheSize is the maximum number of verified tokens kept, DefaultCacheSize by default and negative to disable the cache
+    CacheSize int
+    // CacheTTL is the maximum time a verified token is kept, which never exceeds its exp, DefaultCacheTTL by default
+    CacheTTL time.Duration
+    // Now returns the current time, time.Now by default
+    Now func() time.Time
+}
+
+// JWT extracts the roles from the claims of a json web token, whose signature and claims are verified locally
+type JWT struct {
+    opts  JWTOptions
+    keys  []*Key
+    path  []string
+    cache *cache
+}
+
+// NewJWT is used to create a jwt extractor, the JWKS file is loaded once
+func NewJWT(opts JWTOptions) (*JWT, error) {
+    if opts.Claim == "" {
+        opts.Claim = DefaultJWTClaim
+    }
+    if opts.Header == "" {
+        opts.Header = DefaultJWTHeader
+    }
+    if opts.CacheSize == 0 {
+        opts.CacheSize = DefaultCacheSize
+    }
+    if opts.CacheTTL <= 0 {
+        opts.CacheTTL = DefaultCacheTTL
+    }
+    if opts.Now == nil {
+        opts.Now = time.Now
+    }
+    keys := append([]*Key(nil), opts.Keys...)
+    if opts.JWKS != "" {
+        set, err := LoadJWKS(opts.JWKS)
+        if err != nil {
+            return nil, err
+        }
+        keys = append(keys, set...)
+    }
+    return &JWT{
+        opts:  opts,
+        keys:  keys,
+        path:  strings.Split(opts.Claim, "."),
+        cache: newCache(opts.CacheSize),
+    }, nil
+}
+
+// Extract is used to extract the roles of the token of the request,
+// a request without token is anonymous and an invalid token returns an error
+func (j *JWT) Extract(r *http.Request) ([]string, error) {
+    token := strings.TrimSpace(r.Header.Get(j.opts.Header))
+    if len(token) > 7 && strings.EqualFold(token[:7], "bearer ") {
+        token = strings.TrimSpace(token[7:])
+    }
+    if token == "" {
+        return nil, nil
+    }
+    return j.Roles(token)
+}
+
+// Roles is used to verify a token and get its roles
+func (j *JWT) Roles(token string) ([]string, error) {
+    now := j.opts.Now()
+    sum := sha256.Sum256([]byte(token))
+    if roles, ok := j.cache.get(sum, now); ok {
+        return roles, nil
+    }
+    claims, err := j.verify(token)
+    if err != nil {
+        return nil, err
+    }
+    if err := j.validate(claims, now); err != nil {
+        return nil, err
+    }
+    roles, err := j.roles(claims)
+    if err != nil {
+        return nil, err
+    }
+    expiry := now.Add(j.opts.CacheTTL)
+    if exp, ok := claims.time("exp"); ok && exp.Add(j.opts.Leeway).Before(expiry) {
+        expiry = exp.Add(j.opts.Leeway)
+    }
+    j.cache.set(sum, roles, expiry)
+    return append([]string(nil), roles...), nil
+}
+
+// tokenHeader defines the header of a token
+type tokenHeader struct {
+    Algorithm string `json:"alg"`
+    KeyID     string `json:"kid"`
+}
+
+// claims defines the claims of a token
+type claims map[string]interface{}
+
+// decodeSegment is used to decode a base64url segment of a token, the padding is tolerated
+func decodeSegment(segment string) ([]byte, error) {
+    return base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+}
+
+// verify is used to verify the signature of a token and decode its claims
+func (j *JWT) verify(token string) (claims, error) {
+    parts := strings.Split(token, ".")
+    if len(parts) != 3 {
+        return nil, ErrMalformedToken
+    }
+    data, err := decodeSegment(parts[0])
+    if err != nil {
+        return nil, ErrMalformedToken
+    }
+    var header tokenHeader
+    if err := json.Unmarshal(data, &header); err != nil {
+        return nil, ErrMalformedToken
+    }
+    signature, err := decodeSegment(parts[2])
+    if err != nil {
+        return nil, ErrMalformedToken
+    }
+    alg, ok := algorithms[header.Algorithm]
+    if !ok {
+        return nil, ErrUnsupportedAlgorithm
+    }
+    signed := []byte(parts[0] + "." + parts[1])
+    verified, candidates := false, 0
+    for _, key := range j.keys {
+        if header.KeyID != "" && key.ID != "" && key.ID != header.KeyID {
+            continue
+        }
+        if key.Algorithm != "" && key.Algorithm != header.Algorithm {
+            continue
+        }
+        if !alg.accepts(key.Key) {
+            continue
+        }
+        candidates++
+        if alg.verify(key.Key, signed, signature) {
+            verified = true
+            break
+        }
+    }
+    if candidates == 0 {
+        return nil, ErrUnknownKey
+    }
+    if !verified {
+        return nil, ErrInvalidSignature
+    }
+    data, err = decodeSegment(parts[1])
+    if err != nil {
+        return nil, ErrMalformedToken
+    }
+    decoder := json.NewDecoder(bytes.NewReader(data))
+    decoder.UseNumber()
+    var c claims
+    if err := decoder.Decode(&c); err != nil || c == nil {
+        return nil, ErrMalformedToken
+    }
+    return c, nil
+}
+
+// time is used to get a NumericDate claim
+func (c claims) time(name string) (time.Time, bool) {
+    number, ok := c[name].(json.Number)
+    if !ok {
+        return time.Time{}, false
+    }
+    seconds, err := number.Float64()
+    if err != nil {
+        return time.Time{}, false
+    }
+    return time.Unix(0, int64(seconds*float64(time.Second))), true
+}
+
+// validate is used to validate the exp, nbf, iss and aud claims
+func (j *JWT) validate(c claims, now time.Time) error {
+    if _, ok := c["exp"]; ok {
+        exp, ok := c.time("exp")
+        if !ok {
+            return ErrMalformedToken
+        }
+        if !now.Before(exp.Add(j.opts.Leeway)) {
+            return ErrTokenExpired
+        }
+    } else if j.opts.RequireExpiration {
+        return ErrMissingExpiration
+    }
+    if _, ok := c["nbf"]; ok {
+        nbf, ok := c.time("nbf")
+        if !ok {
+            return ErrMalformedToken
+        }
+        if now.Add(j.opts.Leeway).Before(nbf) {
+            return ErrTokenNotValidYet
+        }
+    }
+    if j.opts.Issuer != "" {
+        if iss, _ := c["iss"].(string); iss != j.opts.Issuer {
+            return ErrInvalidIssuer
+        }
+    }
+    if len(j.opts.Audience) != 0 {
+        var audience []interface{}
+        switch aud := c["aud"].(type) {
+        case string:
+            audience = []interface{}{aud}
+        case []interface{}:
+            audience = aud
+        }
+        for _, aud := range audience {
+            for _, accepted := range j.opts.Audience {
+                if aud == accepted {
+                    return nil
+                }
+            }
+        }
+        return ErrInvalidAudience
+    }
+    return nil
+}
+
+// roles is used to get the roles of the claim path, a missing claim has no role.
+// A claim whose name contains dots, such as https://domain.com/roles, is matched before the nested claims.
+func (j *JWT) roles(c claims) ([]string, error) {
+    value, ok := c[j.opts.Claim]
+    if !ok {
+        value = map[string]interface{}(c)
+        for _, name := range j.path {
+            object, ok := value.(map[string]interface{})
+            if !ok {
+                return nil, nil
+            }
+            if value, ok = object[name]; !ok {
+                return nil, nil
+            }
+        }
+    }
+    switch value := value.(type) {
+    case nil:
+        return nil, nil
+    case string:
+        return strings.Fields(value), nil
+    case []interface{}:
+        roles := make([]string, 0, len(value))
+        for _, item := range value {
+            role, ok := item.(string)
+            if !ok {
+                return nil, ErrInvalidClaim
+            }
+            roles = append(roles, role)
+        }
+        return roles, nil
+    }
+    return nil, ErrInvalidClaim
+}
+
+// algorithm defines a signing algorithm
+type algorithm struct {
+    // accepts reports whether the key fits the algorithm
+    accepts func(key interface{}) bool
+    // verify reports whether the signature of the signed data is valid
+    verify func(key interface{}, signed, signature []byte) bool
+}
+
+// algorithms are the supported signing algorithms, the none algorithm is never accepted
+var algorithms = map[string]*algorithm{
+    "HS256": {
+        accepts: func(key interface{}) bool {
+            secret, ok := key.([]byte)
+            return ok && len(secret) != 0
+        },
+        verify: func(key interface{}, signed, signature []byte) bool {
+            mac := hmac.New(sha256.New, key.([]byte))
+            mac.Write(signed)
+            return hmac.Equal(mac.Sum(nil), signature)
+        },
+    },
+    "RS256": {
+        accepts: func(key interface{}) bool {
+            _, ok := key.(*rsa.PublicKey)
+            return ok
+        },
+        verify: func(key interface{}, signed, signature []byte) bool {
+            digest := sha256.Sum256(signed)
+            return rsa.VerifyPKCS1v15(key.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) == nil
+        },
+    },
+    "ES256": {
+        accepts: func(key interface{}) bool {
+            public, ok := key.(*ecdsa.PublicKey)
+            return ok && public.Curve == elliptic.P256()
+        },
+        verify: func(key interface{}, signed, signature []byte) bool {
+            if len(signature) != 64 {
+                return false
+            }
+            digest := sha256.Sum256(signed)
+            r := new(big.Int).SetBytes(signature[:32])
+            s := new(big.Int).SetBytes(signature[32:])
+            return ecdsa.Verify(key.(*ecdsa.PublicKey), digest[:], r, s)
+        },
+    },
+    "EdDSA": {
+        accepts: func(key interface{}) bool {
+            public, ok := key.(ed25519.PublicKey)
+            return ok && len(public) == ed25519.PublicKeySize
+        },
+        verify: func(key interface{}, signed, signature []byte) bool {
+            return ed25519.Verify(key.(ed25519.PublicKey), signed, signature)
+        },
+    },
+}
@@ -0,0 +1,331 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extractor
+
+import (
+    "crypto"
+    "crypto/ecdsa"
+    "crypto/ed25519"
+    "crypto/elliptic"
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/rsa"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/assert"
+)
+
+// segment is used to encode a segment of a token
+func segment(v interface{}) string {
+    data, _ := json.Marshal(v)
+    return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// sign is used to create a token, the key is the secret or the private key of the algorithm
+func sign(alg, kid string, key interface{}, c map[string]interface{}) string {
+    header := map[string]interface{}{"alg": alg, "typ": "JWT"}
+    if kid != "" {
+        header["kid"] = kid
+    }
+    signed := segment(header) + "." + segment(c)
+    digest := sha256.Sum256([]byte(signed))
+    var signature []byte
+    switch key := key.(type) {
+    case []byte:
+        mac := hmac.New(sha256.New, key)
+        mac.Write([]byte(signed))
+        signature = mac.Sum(nil)
+    case *rsa.PrivateKey:
+        signature, _ = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+    case *ecdsa.PrivateKey:
+        r, s, _ := ecdsa.Sign(rand.Reader, key, digest[:])
+        signature = make([]byte, 64)
+        copy(signature[32-len(r.Bytes()):32], r.Bytes())
+        copy(signature[64-len(s.Bytes()):], s.Bytes())
+    case crypto.Signer:
+        signature, _ = key.Sign(rand.Reader, []byte(signed), crypto.Hash(0))
+    }
+    return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJWT_Roles(t *testing.T) {
+    now := time.Unix(1500000000, 0)
+    secret := []byte("secret")
+    rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+    assert.Equal(t, nil, err)
+    ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    assert.Equal(t, nil, err)
+    j, err := NewJWT(JWTOptions{
+        Keys: []*Key{
+            {ID: "hs", Key: secret},
+            {ID: "rs", Key: &rsaKey.PublicKey},
+            {ID: "es", Algorithm: "ES256", Key: &ecKey.PublicKey},
+        },
+        Issuer:    "https://issuer.com",
+        Audience:  []string{"grbac", "api"},
+        Leeway:    time.Second,
+        CacheSize: -1,
+        Now: func() time.Time {
+            return now
+        },
+    })
+    assert.Equal(t, nil, err)
+
+    valid := func(extra map[string]interface{}) map[string]interface{} {
+        c := map[string]interface{}{
+            "iss":   "https://issuer.com",
+            "aud":   []string{"other", "api"},
+            "exp":   now.Unix() + 60,
+            "nbf":   now.Unix() - 60,
+            "roles": []string{"editor", "reviewer"},
+        }
+        for key, value := range extra {
+            c[key] = value
+        }
+        return c
+    }
+    tests := []struct {
+        name  string
+        token string
+        roles []string
+        err   error
+    }{
+        {name: "test0", token: sign("HS256", "hs", secret, valid(nil)), roles: []string{"editor", "reviewer"}},
+        {name: "test1", token: sign("RS256", "rs", rsaKey, valid(nil)), roles: []string{"editor", "reviewer"}},
+        {name: "test2", token: sign("ES256", "es", ecKey, valid(nil)), roles: []string{"editor", "reviewer"}},
+        {name: "test3", token: sign("RS256", "", rsaKey, valid(nil)), roles: []string{"editor", "reviewer"}},
+        {name: "test4", token: sign("HS256", "hs", []byte("wrong"), valid(nil)), err: ErrInvalidSignature},
+        {name: "test5", token: sign("HS256", "rs", secret, valid(nil)), err: ErrUnknownKey},
+        {name: "test6", token: sign("none", "", nil, valid(nil)), err: ErrUnsupportedAlgorithm},
+        {name: "test7", token: sign("HS256", "hs", secret, valid(map[string]interface{}{"exp": now.Unix() - 1})), err: ErrTokenExpired},
+        {name: "test8", token: sign("HS256", "hs", secret, valid(map[string]interface{}{"exp": now.Unix()})), roles: []string{"editor", "reviewer"}},
+        {name: "test9", token: sign("HS256", "hs", secret, valid(map[string]interface{}{"nbf": now.Unix() + 2})), err: ErrTokenNotValidYet},
+        {name: "test10", token: sign("HS256", "hs", secret, valid(map[string]interface{}{"iss": "https://other.com"})), err: ErrInvalidIssuer},
+        {name: "test11", token: sign("HS256", "hs", secret, valid(map[string]interface{}{"aud": "other"})), err: ErrInvalidAudience},
+        {name: "test12", token: sign("HS256", "hs", secret, valid(map[string]interface{}{"aud": "grbac"})), roles: []string{"editor", "reviewer"}},
+        {name: "test13", token: sign("HS256", "hs", secret, valid(map[string]interface{}{"roles": nil})), roles: nil},
+        {name: "test14", token: sign("HS256", "hs", secret, valid(map[string]interface{}{"roles": []int{1}})), err: ErrInvalidClaim},
+        {name: "test15", token: "a.b", err: ErrMalformedToken},
+        {name: "test16", token: "!." + segment(valid(nil)) + ".c2ln", err: ErrMalformedToken},
+        {name: "test17", token: sign("ES256", "rs", rsaKey, valid(nil)), err: ErrUnknownKey},
+    }
+    for _, tt := range tests {
+        roles, err := j.Roles(tt.token)
+        assert.Equal(t, tt.err, err, tt.name)
+        assert.Equal(t, tt.roles, roles, tt.name)
+    }
+}
+
+func TestJWT_RequireExpiration(t *testing.T) {
+    now := time.Unix(1500000000, 0)
+    secret := []byte("secret")
+    forever := sign("HS256", "", secret, map[string]interface{}{"roles": []string{"editor"}})
+    expiring := sign("HS256", "", secret, map[string]interface{}{"roles": []string{"editor"}, "exp": now.Unix() + 60})
+    tests := []struct {
+        name    string
+        require bool
+        token   string
+        roles   []string
+        err     error
+    }{
+        {name: "test0", require: false, token: forever, roles: []string{"editor"}},
+        {name: "test1", require: true, token: forever, err: ErrMissingExpiration},
+        {name: "test2", require: true, token: expiring, roles: []string{"editor"}},
+    }
+    for _, tt := range tests {
+        j, err := NewJWT(JWTOptions{
+            Keys:              []*Key{{Key: secret}},
+            RequireExpiration: tt.require,
+            Now: func() time.Time {
+                return now
+            },
+        })
+        assert.Equal(t, nil, err)
+        roles, err := j.Roles(tt.token)
+        assert.Equal(t, tt.err, err, tt.name)
+        assert.Equal(t, tt.roles, roles, tt.name)
+    }
+}
+
+func TestJWT_EdDSA(t *testing.T) {
+    public, private, err := ed25519.GenerateKey(rand.Reader)
+    assert.Equal(t, nil, err)
+    keys, err := ParseJWKS([]byte(`{"keys": [{"kty": "OKP", "crv": "Ed25519", "kid": "ed", "x": "` +
+        base64.RawURLEncoding.EncodeToString(public) + `"}]}`))
+    assert.Equal(t, nil, err)
+    j, err := NewJWT(JWTOptions{Keys: keys})
+    assert.Equal(t, nil, err)
+
+    c := map[string]interface{}{"roles": []string{"editor"}}
+    roles, err := j.Roles(sign("EdDSA", "ed", private, c))
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []string{"editor"}, roles)
+
+    _, other, err := ed25519.GenerateKey(rand.Reader)
+    assert.Equal(t, nil, err)
+    _, err = j.Roles(sign("EdDSA", "ed", other, c))
+    assert.Equal(t, ErrInvalidSignature, err)
+}
+
+func TestJWT_Claim(t *testing.T) {
+    secret := []byte("secret")
+    c := map[string]interface{}{
+        "scope":                    "read:article  write:article",
+        "realm_access":             map[string]interface{}{"roles": []string{"editor"}},
+        "https://domain.com/roles": []string{"admin"},
+        "nested":                   "string",
+    }
+    token := sign("HS256", "", secret, c)
+    tests := []struct {
+        name  string
+        claim string
+        roles []string
+        err   error
+    }{
+        {name: "test0", claim: "scope", roles: []string{"read:article", "write:article"}},
+        {name: "test1", claim: "realm_access.roles", roles: []string{"editor"}},
+        {name: "test2", claim: "https://domain.com/roles", roles: []string{"admin"}},
+        {name: "test3", claim: "realm_access.groups", roles: nil},
+        {name: "test4", claim: "nested.roles", roles: nil},
+        {name: "test5", claim: "realm_access", err: ErrInvalidClaim},
+    }
+    for _, tt := range tests {
+        j, err := NewJWT(JWTOptions{Keys: []*Key{{Key: secret}}, Claim: tt.claim})
+        assert.Equal(t, nil, err)
+        roles, err := j.Roles(token)
+        assert.Equal(t, tt.err, err, tt.name)
+        assert.Equal(t, tt.roles, roles, tt.name)
+    }
+}
+
+func TestJWT_Extract(t *testing.T) {
+    secret := []byte("secret")
+    j, err := NewJWT(JWTOptions{Keys: []*Key{{Key: secret}}})
+    assert.Equal(t, nil, err)
+    token := sign("HS256", "", secret, map[string]interface{}{"roles": []string{"editor"}})
+
+    r := httptest.NewRequest("GET", "/", nil)
+    roles, err := j.Extract(r)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []string(nil), roles)
+
+    r.Header.Set("Authorization", "Bearer "+token)
+    roles, err = j.Extract(r)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []string{"editor"}, roles)
+
+    r.Header.Set("Authorization", "bearer invalid")
+    _, err = j.Extract(r)
+    assert.Equal(t, ErrMalformedToken, err)
+}
+
+func TestJWT_Cache(t *testing.T) {
+    now := time.Unix(1500000000, 0)
+    secret := []byte("secret")
+    j, err := NewJWT(JWTOptions{
+        Keys:      []*Key{{Key: secret}},
+        CacheSize: 1,
+        CacheTTL:  time.Hour,
+        Now: func() time.Time {
+            return now
+        },
+    })
+    assert.Equal(t, nil, err)
+    token := sign("HS256", "", secret, map[string]interface{}{"roles": []string{"editor"}, "exp": now.Unix() + 60})
+    roles, err := j.Roles(token)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []string{"editor"}, roles)
+    roles[0] = "admin"
+
+    // the cached roles are returned without verification, and are not modified by the callers
+    j.keys = nil
+    roles, err = j.Roles(token)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []string{"editor"}, roles)
+
+    // the token is evicted from the cache when it expires, even though the cache ttl is longer
+    now = now.Add(time.Minute)
+    _, err = j.Roles(token)
+    assert.Equal(t, ErrUnknownKey, err)
+
+    // the least recently used token is evicted when the cache is full
+    j.keys = []*Key{{Key: secret}}
+    first := sign("HS256", "", secret, map[string]interface{}{"roles": []string{"a"}})
+    second := sign("HS256", "", secret, map[string]interface{}{"roles": []string{"b"}})
+    j.Roles(first)
+    j.Roles(second)
+    j.keys = nil
+    _, err = j.Roles(first)
+    assert.Equal(t, ErrUnknownKey, err)
+    roles, err = j.Roles(second)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []string{"b"}, roles)
+}
+
+func TestLoadJWKS(t *testing.T) {
+    rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+    assert.Equal(t, nil, err)
+    ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    assert.Equal(t, nil, err)
+    encode := func(b []byte) string {
+        return base64.RawURLEncoding.EncodeToString(b)
+    }
+    set := fmt.Sprintf(`{"keys": [
+        {"kty": "RSA", "kid": "rs", "alg": "RS256", "use": "sig", "n": %q, "e": "AQAB"},
+        {"kty": "EC", "kid": "es", "crv": "P-256", "x": %q, "y": %q},
+        {"kty": "oct", "kid": "hs", "k": %q},
+        {"kty": "RSA", "kid": "enc", "use": "enc", "n": %q, "e": "AQAB"},
+        {"kty": "EC", "kid": "p384", "crv": "P-384", "x": "AA", "y": "AA"},
+        {"kty": "unknown"}
+    ]}`, encode(rsaKey.N.Bytes()), encode(ecKey.X.Bytes()), encode(ecKey.Y.Bytes()), encode([]byte("secret")), encode(rsaKey.N.Bytes()))
+    dir, err := ioutil.TempDir("", "jwks")
+    assert.Equal(t, nil, err)
+    defer os.RemoveAll(dir)
+    name := filepath.Join(dir, "jwks.json")
+    assert.Equal(t, nil, ioutil.WriteFile(name, []byte(set), 0644))
+
+    keys, err := LoadJWKS(name)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, 3, len(keys))
+
+    j, err := NewJWT(JWTOptions{JWKS: name})
+    assert.Equal(t, nil, err)
+    c := map[string]interface{}{"roles": []string{"editor"}}
+    for _, token := range []string{
+        sign("RS256", "rs", rsaKey, c),
+        sign("ES256", "es", ecKey, c),
+        sign("HS256", "hs", []byte("secret"), c),
+    } {
+        roles, err := j.Roles(token)
+        assert.Equal(t, nil, err)
+        assert.Equal(t, []string{"editor"}, roles)
+    }
+    _, err = j.Roles(sign("HS256", "es", []byte("secret"), c))
+    assert.Equal(t, ErrUnknownKey, err)
+
+    _, err = ParseJWKS([]byte(`{"keys": [{"kty": "EC", "crv": "P-256", "x": "AA", "y": "AA"}]}`))
+    assert.Equal(t, ErrInvalidJWK, err)
+    _, err = NewJWT(JWTOptions{JWKS: filepath.Join(dir, "missing.json")})
+    assert.NotEqual(t, nil, err)
+}
@@ -20,11 +20,14 @@ import (
 
 // AdvancedRule allows you to write RBAC rules in a more concise way
 type AdvancedRule struct {
-    Host   []string `json:"host"`
-    Path   []string `json:"path"`
-    Method []string `json:"method"`
+    // ID controls the priority of the rules expanded from the AdvancedRule,
+    // every expanded rule inherits it.
+    ID     int      `json:"id" yaml:"id" toml:"id"`
+    Host   []string `json:"host" yaml:"host" toml:"host"`
+    Path   []string `json:"path" yaml:"path" toml:"path"`
+    Method []string `json:"method" yaml:"method" toml:"method"`
 
-    *meta.Permission
+    *meta.Permission `yaml:",inline"`
 }
 
 // AdvancedRules is the list of AdvancedRules
@@ -38,6 +41,7 @@ func (adv AdvancedRules) GetRules() meta.Rules {
             for _, path := range item.Path {
                 for _, method := range item.Method {
                     rules = append(rules, &meta.Rule{
+                        ID: item.ID,
                         Resource: &meta.Resource{
                             Host:   host,
                             Path:   path,
@@ -52,6 +56,24 @@ func (adv AdvancedRules) GetRules() meta.Rules {
     return rules
 }
 
+// IsValid is used to test the validity of the AdvancedRule
+func (rule *AdvancedRule) IsValid() error {
+    if rule.Permission == nil {
+        return meta.ErrEmptyStructure
+    }
+    for _, values := range [][]string{rule.Host, rule.Path, rule.Method} {
+        if len(values) == 0 {
+            return meta.ErrFieldIncomplete
+        }
+        for _, value := range values {
+            if value == "" {
+                return meta.ErrFieldIncomplete
+            }
+        }
+    }
+    return rule.Permission.IsValid()
+}
+
 // AdvancedRulesLoader implements the Loader interface
 // it is used to load configuration from advanced data.
 type AdvancedRulesLoader struct {
@@ -0,0 +1,63 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loader
+
+import (
+    "io/ioutil"
+    "time"
+
+    "github.com/storyicon/grbac/pkg/bundle"
+    "github.com/storyicon/grbac/pkg/meta"
+)
+
+// BundleLoader implements the Loader interface
+// it is used to load configuration from a local signed bundle.
+// The rules are only decoded after the signature of the bundle has been verified.
+type BundleLoader struct {
+    path    string
+    keys    bundle.KeyRing
+    options *options
+}
+
+// NewBundleLoader is used to initialize a BundleLoader
+func NewBundleLoader(file string, keys bundle.KeyRing, opts ...Option) (*BundleLoader, error) {
+    loader := &BundleLoader{
+        path:    file,
+        keys:    keys,
+        options: newOptions(opts),
+    }
+    _, err := loader.Load()
+    if err != nil {
+        return nil, err
+    }
+    return loader, nil
+}
+
+// Load is used to return a list of rules
+func (loader *BundleLoader) Load() (meta.Rules, error) {
+    bytes, err := ioutil.ReadFile(loader.path)
+    if err != nil {
+        return nil, err
+    }
+    b, err := bundle.Decode(bytes)
+    if err != nil {
+        return nil, err
+    }
+    payload, err := b.Verify(loader.keys, time.Now())
+    if err != nil {
+        return nil, err
+    }
+    return decode(b.Manifest.Format, loader.path, payload, loader.options)
+}
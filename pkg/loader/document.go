@@ -0,0 +1,50 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loader
+
+import (
+    "github.com/storyicon/grbac/pkg/meta"
+)
+
+// Document defines a rule file containing both rules and advanced rules.
+// Besides a plain list of rules, every file loader accepts a Document:
+//
+//  rules:
+//  - id: 0
+//    host: "*"
+//    path: "**"
+//    method: "*"
+//    authorized_roles: ["*"]
+//  advanced_rules:
+//  - id: 1
+//    host: ["domain.com"]
+//    path: ["/article"]
+//    method: ["DELETE", "POST", "PUT"]
+//    authorized_roles: ["editor"]
+//
+// The vars section of a json or yaml Document defines the variables used by Expand,
+// it is ignored when the expansion is disabled.
+type Document struct {
+    Vars          Vars          `json:"vars" yaml:"vars" toml:"-"`
+    Rules         meta.Rules    `json:"rules" yaml:"rules" toml:"rules"`
+    AdvancedRules AdvancedRules `json:"advanced_rules" yaml:"advanced_rules" toml:"advanced_rules"`
+}
+
+// GetRules is used to merge the rules and the expanded advanced rules of the Document
+func (doc *Document) GetRules() meta.Rules {
+    rules := meta.Rules{}
+    rules = append(rules, doc.Rules...)
+    return append(rules, doc.AdvancedRules.GetRules()...)
+}
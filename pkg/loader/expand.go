@@ -0,0 +1,348 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loader
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "regexp"
+    "sort"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+// define a set of errors
+var (
+    ErrUndefinedVariable    = errors.New("undefined variable")
+    ErrInvalidVariable      = errors.New("invalid variable name")
+    ErrUnsupportedVariable  = errors.New("unsupported variable value")
+    ErrUnsupportedExpansion = errors.New("variables are only expanded in json and yaml rule files")
+)
+
+// Vars defines the variables of a rule file,
+// the value of a variable is either a scalar or a list of scalars.
+type Vars map[string]interface{}
+
+// variablePattern matches ${NAME}, and the escaped form $${NAME}
+var variablePattern = regexp.MustCompile(`\$?\$\{([^{}]*)\}`)
+
+// variableNamePattern matches a valid variable name
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Expand makes the file loaders substitute the ${NAME} references of a rule file before decoding it.
+// NAME is looked up in the vars section of the file first, then in the environment variables.
+// A list variable is substituted with a brace alternation like {a.com,b.com},
+// which is matched by the host and path patterns as any of its elements:
+//
+//  vars:
+//    api_hosts: [api.domain.com, api.domain.cn]
+//  rules:
+//  - id: 0
+//    host: "${api_hosts}"
+//    path: "${API_PREFIX}/**"
+//    method: "*"
+//    authorized_roles: ["*"]
+//
+// Only json and yaml rule files are expanded, the other formats return ErrUnsupportedExpansion.
+// The references are only substituted inside strings, the string scalars of yaml and the strings of json,
+// and the values are escaped for them, so that a variable can never change the structure of the file.
+// The references in comments and numbers are kept as they are. A plain yaml scalar is resolved
+// after the substitution, so allow_anyone: ${ANYONE} is a bool, while a quoted one is always a string.
+// $${NAME} is kept as the literal ${NAME}, undefined variables are reported as errors.
+func Expand() Option {
+    return func(o *options) {
+        o.expand = true
+    }
+}
+
+// lookupVars is used to create the lookup function of the variables and the environment variables
+func lookupVars(vars map[string]string) func(string) (string, bool) {
+    return func(key string) (string, bool) {
+        if value, ok := vars[key]; ok {
+            return value, true
+        }
+        return os.LookupEnv(key)
+    }
+}
+
+// substitute is used to substitute the variable references of a string, the values are converted by escape.
+// The errors are reported at the position of the offset of their references.
+func substitute(s string, lookup func(string) (string, bool), position func(offset int) Position, escape func(value string) (string, error)) (string, error) {
+    var errs error
+    output := make([]byte, 0, len(s))
+    last := 0
+    for _, loc := range variablePattern.FindAllStringSubmatchIndex(s, -1) {
+        output = append(output, s[last:loc[0]]...)
+        last = loc[1]
+        if s[loc[0]+1] == '$' {
+            output = append(output, s[loc[0]+1:loc[1]]...)
+            continue
+        }
+        key := s[loc[2]:loc[3]]
+        value, err := expandVariable(key, lookup)
+        if err == nil {
+            value, err = escape(value)
+            if err != nil {
+                err = fmt.Errorf("%s %q: %s", ErrUnsupportedVariable, key, err)
+            }
+        }
+        if err != nil {
+            errs = appendError(errs, &DecodeError{Position: position(loc[0]), Err: err})
+            continue
+        }
+        output = append(output, value...)
+    }
+    if errs != nil {
+        return "", errs
+    }
+    return string(append(output, s[last:]...)), nil
+}
+
+// expand is used to substitute the variable references inside the strings of a json rule file
+func expand(name string, data []byte) ([]byte, error) {
+    vars, err := resolveVars(name, extractVars(data))
+    if err != nil {
+        return nil, err
+    }
+    lookup := lookupVars(vars)
+    var errs error
+    output := make([]byte, 0, len(data))
+    last := 0
+    for _, span := range jsonStrings(data) {
+        output = append(output, data[last:span[0]]...)
+        last = span[1]
+        position := func(offset int) Position {
+            return offsetPosition(name, data, span[0]+offset)
+        }
+        content, err := substitute(string(data[span[0]:span[1]]), lookup, position, func(value string) (string, error) {
+            return escapeString(value), nil
+        })
+        if err != nil {
+            errs = appendError(errs, err)
+            continue
+        }
+        output = append(output, content...)
+    }
+    if errs != nil {
+        return nil, errs
+    }
+    return append(output, data[last:]...), nil
+}
+
+// expandYAML is used to substitute the variable references inside the string scalars of a yaml document,
+// the nodes are modified in place so that the positions of the errors are kept.
+func expandYAML(name string, root *yaml.Node) error {
+    vars, err := resolveVars(name, yamlVars(root))
+    if err != nil {
+        return err
+    }
+    lookup := lookupVars(vars)
+    var errs error
+    var walk func(node *yaml.Node)
+    walk = func(node *yaml.Node) {
+        for _, child := range node.Content {
+            walk(child)
+        }
+        if node.Kind != yaml.ScalarNode || node.ShortTag() != "!!str" {
+            return
+        }
+        column := node.Column
+        if node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+            column++
+        }
+        position := func(offset int) Position {
+            // the offset is exact for the single line scalars without escape sequences
+            return Position{File: name, Line: node.Line, Column: column + offset}
+        }
+        value, err := substitute(node.Value, lookup, position, func(value string) (string, error) {
+            return value, nil
+        })
+        if err != nil {
+            errs = appendError(errs, err)
+            return
+        }
+        // a plain scalar is resolved again, so that a reference can be a bool or a number,
+        // while a quoted or explicitly tagged scalar is always a string
+        if node.Style == 0 && value != node.Value {
+            node.Tag = ""
+        }
+        node.Value = value
+    }
+    walk(root)
+    return errs
+}
+
+// isControl is used to determine whether a rune must be escaped in a string, tabs are allowed
+func isControl(r rune) bool {
+    return (r < 0x20 && r != '\t') || r == 0x7f
+}
+
+// escapeString is used to escape a value for the strings of json
+func escapeString(s string) string {
+    var b strings.Builder
+    for _, r := range s {
+        switch {
+        case r == '"' || r == '\\':
+            b.WriteByte('\\')
+            b.WriteRune(r)
+        case r == '\n':
+            b.WriteString(`\n`)
+        case r == '\r':
+            b.WriteString(`\r`)
+        case r == '\t':
+            b.WriteString(`\t`)
+        case isControl(r):
+            fmt.Fprintf(&b, `\u%04x`, r)
+        default:
+            b.WriteRune(r)
+        }
+    }
+    return b.String()
+}
+
+// expandVariable is used to resolve a single variable by the lookup function
+func expandVariable(key string, lookup func(string) (string, bool)) (string, error) {
+    if !variableNamePattern.MatchString(key) {
+        return "", fmt.Errorf("%s %q", ErrInvalidVariable, key)
+    }
+    value, ok := lookup(key)
+    if !ok {
+        return "", fmt.Errorf("%s %q", ErrUndefinedVariable, key)
+    }
+    return value, nil
+}
+
+// resolveVars is used to convert the variables to their textual form,
+// environment variables referenced by the values are expanded as well.
+func resolveVars(name string, vars Vars) (map[string]string, error) {
+    keys := make([]string, 0, len(vars))
+    for key := range vars {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    resolved := map[string]string{}
+    var errs error
+    for _, key := range keys {
+        pos := Position{File: name}
+        if !variableNamePattern.MatchString(key) {
+            errs = appendError(errs, newDecodeError(pos, "vars: %s %q", ErrInvalidVariable, key))
+            continue
+        }
+        value, err := formatVariable(vars[key])
+        if err != nil {
+            errs = appendError(errs, newDecodeError(pos, "vars.%s: %s", key, err))
+            continue
+        }
+        value = variablePattern.ReplaceAllStringFunc(value, func(match string) string {
+            if match[1] == '$' {
+                return match[1:]
+            }
+            env, err := expandVariable(match[2:len(match)-1], os.LookupEnv)
+            if err != nil {
+                errs = appendError(errs, newDecodeError(pos, "vars.%s: %s", key, err))
+                return match
+            }
+            return env
+        })
+        resolved[key] = value
+    }
+    if errs != nil {
+        return nil, errs
+    }
+    return resolved, nil
+}
+
+// formatVariable is used to convert the value of a variable to text
+func formatVariable(value interface{}) (string, error) {
+    switch v := value.(type) {
+    case string:
+        return v, nil
+    case bool, int, int64, uint64, float64:
+        return fmt.Sprint(v), nil
+    case []interface{}:
+        items := make([]string, 0, len(v))
+        for _, item := range v {
+            if _, ok := item.([]interface{}); ok {
+                return "", ErrUnsupportedVariable
+            }
+            s, err := formatVariable(item)
+            if err != nil {
+                return "", err
+            }
+            items = append(items, s)
+        }
+        if len(items) == 1 {
+            return items[0], nil
+        }
+        return "{" + strings.Join(items, ",") + "}", nil
+    }
+    return "", ErrUnsupportedVariable
+}
+
+// extractVars is used to read the vars section of a json rule file before it is expanded.
+// A file that can not be parsed yet has no variables,
+// its syntax errors are reported by the decoder afterwards.
+func extractVars(data []byte) Vars {
+    doc := struct {
+        Vars Vars `json:"vars"`
+    }{}
+    if jsonKind(data) == '{' {
+        _ = json.Unmarshal(data, &doc)
+    }
+    return doc.Vars
+}
+
+// yamlVars is used to read the vars section of a yaml document
+func yamlVars(root *yaml.Node) Vars {
+    if len(root.Content) == 0 {
+        return nil
+    }
+    node := resolveYAML(root.Content[0])
+    if node.Kind != yaml.MappingNode {
+        return nil
+    }
+    var vars Vars
+    for _, pair := range yamlPairs(node) {
+        if pair[0].Value == "vars" {
+            _ = resolveYAML(pair[1]).Decode(&vars)
+        }
+    }
+    return vars
+}
+
+// jsonStrings is used to find the contents of the strings of a json rule file.
+// An unterminated string ends with the file, its syntax error is reported by the decoder afterwards.
+func jsonStrings(data []byte) [][2]int {
+    var spans [][2]int
+    for i := 0; i < len(data); i++ {
+        if data[i] != '"' {
+            continue
+        }
+        start := i + 1
+        for i = start; i < len(data) && data[i] != '"'; i++ {
+            if data[i] == '\\' {
+                i++
+            }
+        }
+        if i > len(data) {
+            i = len(data)
+        }
+        spans = append(spans, [2]int{start, i})
+    }
+    return spans
+}
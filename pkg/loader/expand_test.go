@@ -0,0 +1,218 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loader
+
+import (
+    "os"
+    "testing"
+
+    "github.com/storyicon/grbac/pkg/meta"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestExpand(t *testing.T) {
+    os.Setenv("GRBAC_TEST_PREFIX", "/api")
+    defer os.Unsetenv("GRBAC_TEST_PREFIX")
+    want := meta.Rules{
+        {
+            ID:         1,
+            Resource:   &meta.Resource{Host: "{a.com,b.com}", Path: "/api/**", Method: "*"},
+            Permission: &meta.Permission{AuthorizedRoles: []string{"admin"}},
+        },
+        {
+            ID:         2,
+            Resource:   &meta.Resource{Host: "{a.com,b.com}", Path: "/api/${literal}", Method: "GET"},
+            Permission: &meta.Permission{AllowAnyone: true},
+        },
+    }
+    tests := []struct {
+        name   string
+        format string
+        data   string
+    }{
+        {
+            name:   "yaml",
+            format: FormatYAML,
+            data: `
+vars:
+  hosts: [a.com, b.com]
+  admin: admin
+rules:
+- id: 1
+  host: "${hosts}"
+  path: "${GRBAC_TEST_PREFIX}/**"
+  method: "*"
+  authorized_roles: ["${admin}"]
+advanced_rules:
+- id: 2
+  host: ["${hosts}"]
+  path: ["${GRBAC_TEST_PREFIX}/$${literal}"]
+  method: [GET]
+  allow_anyone: true
+`,
+        },
+        {
+            name:   "json",
+            format: FormatJSON,
+            data: `{
+    "vars": {"hosts": ["a.com", "b.com"], "admin": "admin", "prefix": "${GRBAC_TEST_PREFIX}"},
+    "rules": [
+        {"id": 1, "host": "${hosts}", "path": "${prefix}/**", "method": "*", "authorized_roles": ["${admin}"]},
+        {"id": 2, "host": "${hosts}", "path": "${prefix}/$${literal}", "method": "GET", "allow_anyone": true}
+    ]
+}`,
+        },
+    }
+    for _, tt := range tests {
+        for _, strict := range []bool{false, true} {
+            opts := []Option{Expand()}
+            if strict {
+                opts = append(opts, Strict())
+            }
+            rules, err := Decode(tt.format, "rules."+tt.format, []byte(tt.data), opts...)
+            assert.Equal(t, nil, err, tt.name)
+            assert.Equal(t, want.String(), rules.String(), tt.name)
+        }
+    }
+}
+
+func TestExpandErrors(t *testing.T) {
+    tests := []struct {
+        name   string
+        format string
+        data   string
+        want   []string
+    }{
+        {
+            name:   "test0",
+            format: FormatYAML,
+            data: `
+- id: 0
+  host: "${GRBAC_TEST_UNDEFINED}"
+  path: "${bad-name}"
+  method: "*"
+`,
+            want: []string{
+                `rules.yaml:3:10: undefined variable "GRBAC_TEST_UNDEFINED"`,
+                `rules.yaml:4:10: invalid variable name "bad-name"`,
+            },
+        },
+        {
+            name:   "test1",
+            format: FormatJSON,
+            data:   `{"vars": {"hosts": [["a.com"]], "path": "${GRBAC_TEST_UNDEFINED}"}, "rules": []}`,
+            want: []string{
+                `rules.json: vars.hosts: unsupported variable value`,
+                `rules.json: vars.path: undefined variable "GRBAC_TEST_UNDEFINED"`,
+            },
+        },
+    }
+    for _, tt := range tests {
+        _, err := Decode(tt.format, "rules."+tt.format, []byte(tt.data), Expand())
+        assert.Equal(t, tt.want, getErrors(err), tt.name)
+    }
+}
+
+func TestExpandEscaping(t *testing.T) {
+    os.Setenv("GRBAC_TEST_HOST", `a.com", "allow_anyone": true, "x": "`)
+    os.Setenv("GRBAC_TEST_PATH", "/a\"b'\\c\n")
+    defer os.Unsetenv("GRBAC_TEST_HOST")
+    defer os.Unsetenv("GRBAC_TEST_PATH")
+    want := meta.Rules{
+        {
+            ID:         0,
+            Resource:   &meta.Resource{Host: `a.com", "allow_anyone": true, "x": "`, Path: "/a\"b'\\c\n", Method: "GET"},
+            Permission: &meta.Permission{AuthorizedRoles: []string{"admin"}},
+        },
+    }
+    tests := []struct {
+        name   string
+        format string
+        data   string
+    }{
+        {
+            name:   "yaml",
+            format: FormatYAML,
+            data: `
+# uses ${GRBAC_TEST_UNDEFINED}
+- id: 0
+  host: "${GRBAC_TEST_HOST}"
+  path: ${GRBAC_TEST_PATH} # ${GRBAC_TEST_UNDEFINED}
+  method: GET
+  authorized_roles: ['admin']
+`,
+        },
+        {
+            name:   "json",
+            format: FormatJSON,
+            data:   `[{"id": 0, "host": "${GRBAC_TEST_HOST}", "path": "${GRBAC_TEST_PATH}", "method": "GET", "authorized_roles": ["admin"]}]`,
+        },
+    }
+    for _, tt := range tests {
+        for _, strict := range []bool{false, true} {
+            opts := []Option{Expand()}
+            if strict {
+                opts = append(opts, Strict())
+            }
+            rules, err := Decode(tt.format, "rules."+tt.format, []byte(tt.data), opts...)
+            assert.Equal(t, nil, err, tt.name)
+            assert.Equal(t, want, rules, tt.name)
+        }
+    }
+}
+
+func TestExpandYAMLScalars(t *testing.T) {
+    os.Setenv("GRBAC_TEST_ANYONE", "true")
+    os.Setenv("GRBAC_TEST_ID", "7")
+    defer os.Unsetenv("GRBAC_TEST_ANYONE")
+    defer os.Unsetenv("GRBAC_TEST_ID")
+    data := `
+- id: ${GRBAC_TEST_ID}
+  host: "*"
+  path: "**"
+  method: "*"
+  allow_anyone: ${GRBAC_TEST_ANYONE}
+`
+    want := meta.Rules{
+        {
+            ID:         7,
+            Resource:   &meta.Resource{Host: "*", Path: "**", Method: "*"},
+            Permission: &meta.Permission{AllowAnyone: true},
+        },
+    }
+    for _, strict := range []bool{false, true} {
+        opts := []Option{Expand()}
+        if strict {
+            opts = append(opts, Strict())
+        }
+        rules, err := Decode(FormatYAML, "rules.yaml", []byte(data), opts...)
+        assert.Equal(t, nil, err)
+        assert.Equal(t, want, rules)
+    }
+
+    // a quoted or tagged scalar stays a string
+    for _, value := range []string{`"${GRBAC_TEST_ANYONE}"`, `!!str ${GRBAC_TEST_ANYONE}`} {
+        data := "- id: 0\n  host: \"*\"\n  path: \"**\"\n  method: \"*\"\n  allow_anyone: " + value + "\n"
+        _, err := Decode(FormatYAML, "rules.yaml", []byte(data), Expand())
+        assert.NotEqual(t, nil, err, value)
+    }
+}
+
+func TestExpandUnsupportedFormat(t *testing.T) {
+    for _, format := range []string{FormatTOML, FormatHCL} {
+        _, err := Decode(format, "rules."+format, []byte(""), Expand())
+        assert.Equal(t, ErrUnsupportedExpansion, err, format)
+    }
+}
@@ -0,0 +1,187 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loader
+
+import (
+    "bytes"
+    "errors"
+    "io/ioutil"
+    "path/filepath"
+    "strings"
+
+    "github.com/BurntSushi/toml"
+    jsoniter "github.com/json-iterator/go"
+    "github.com/storyicon/grbac/pkg/meta"
+    "gopkg.in/yaml.v3"
+)
+
+// define the supported formats of rule files
+const (
+    FormatJSON = "json"
+    FormatYAML = "yaml"
+    FormatTOML = "toml"
+    FormatHCL  = "hcl"
+)
+
+// ErrUnsupportedFormat is returned when the format of the rules is unknown
+var ErrUnsupportedFormat = errors.New("unsupported rules format")
+
+// DetectFormat is used to detect the format of a rule file by its extension
+func DetectFormat(file string) (string, error) {
+    switch strings.ToLower(filepath.Ext(file)) {
+    case ".json":
+        return FormatJSON, nil
+    case ".yml", ".yaml":
+        return FormatYAML, nil
+    case ".toml":
+        return FormatTOML, nil
+    case ".hcl":
+        return FormatHCL, nil
+    }
+    return "", ErrUnsupportedFormat
+}
+
+// Unmarshal is used to parse rules in the given format
+func Unmarshal(format string, bytes []byte) (meta.Rules, error) {
+    switch format {
+    case FormatJSON:
+        return unmarshalJSON(bytes)
+    case FormatYAML:
+        return unmarshalYAML(bytes)
+    case FormatTOML:
+        return unmarshalTOML(bytes)
+    case FormatHCL:
+        return unmarshalHCL(bytes)
+    }
+    return nil, ErrUnsupportedFormat
+}
+
+// Marshal is used to encode rules in the given format,
+// the output can be parsed by Unmarshal.
+// hcl is not supported because there is no hcl encoder.
+func Marshal(format string, rules meta.Rules) ([]byte, error) {
+    if rules == nil {
+        rules = meta.Rules{}
+    }
+    switch format {
+    case FormatJSON:
+        return jsoniter.MarshalIndent(rules, "", "    ")
+    case FormatYAML:
+        return yaml.Marshal(rules)
+    case FormatTOML:
+        doc := struct {
+            Rules meta.Rules `toml:"rules"`
+        }{Rules: rules}
+        buffer := &bytes.Buffer{}
+        if err := toml.NewEncoder(buffer).Encode(doc); err != nil {
+            return nil, err
+        }
+        return buffer.Bytes(), nil
+    }
+    return nil, ErrUnsupportedFormat
+}
+
+// Decode is used to parse rules in the given format with the given options,
+// name is the file name reported by the errors.
+func Decode(format string, name string, bytes []byte, opts ...Option) (meta.Rules, error) {
+    return decode(format, name, bytes, newOptions(opts))
+}
+
+// decode is used to parse rules in the given format with the options of a loader,
+// name is the file name reported by the errors.
+func decode(format string, name string, bytes []byte, o *options) (meta.Rules, error) {
+    if o.expand {
+        switch format {
+        case FormatYAML:
+            return decodeExpandedYAML(name, bytes, o.strict)
+        case FormatJSON:
+            expanded, err := expand(name, bytes)
+            if err != nil {
+                return nil, err
+            }
+            bytes = expanded
+        default:
+            return nil, ErrUnsupportedExpansion
+        }
+    }
+    if !o.strict {
+        return Unmarshal(format, bytes)
+    }
+    switch format {
+    case FormatJSON:
+        return unmarshalStrictJSON(name, bytes)
+    case FormatYAML:
+        return unmarshalStrictYAML(name, bytes)
+    case FormatTOML:
+        return unmarshalStrictTOML(name, bytes)
+    case FormatHCL:
+        return unmarshalStrictHCL(name, bytes)
+    }
+    return nil, ErrUnsupportedFormat
+}
+
+// decodeExpandedYAML is used to parse a yaml rule file whose string scalars are expanded
+func decodeExpandedYAML(name string, bytes []byte, strict bool) (meta.Rules, error) {
+    root := &yaml.Node{}
+    if err := yaml.Unmarshal(bytes, root); err != nil {
+        if strict {
+            return nil, yamlError(Position{File: name}, err)
+        }
+        return nil, err
+    }
+    if err := expandYAML(name, root); err != nil {
+        return nil, err
+    }
+    if strict {
+        return unmarshalStrictYAMLNode(name, root)
+    }
+    return unmarshalYAMLNode(root)
+}
+
+// FileLoader implements the Loader interface
+// it is used to load configuration from a local file,
+// whose format is detected by its extension.
+type FileLoader struct {
+    path    string
+    format  string
+    options *options
+}
+
+// NewFileLoader is used to initialize a FileLoader
+func NewFileLoader(file string, opts ...Option) (*FileLoader, error) {
+    format, err := DetectFormat(file)
+    if err != nil {
+        return nil, err
+    }
+    loader := &FileLoader{
+        path:    file,
+        format:  format,
+        options: newOptions(opts),
+    }
+    _, err = loader.Load()
+    if err != nil {
+        return nil, err
+    }
+    return loader, nil
+}
+
+// Load is used to return a list of rules
+func (loader *FileLoader) Load() (meta.Rules, error) {
+    bytes, err := ioutil.ReadFile(loader.path)
+    if err != nil {
+        return nil, err
+    }
+    return decode(loader.format, loader.path, bytes, loader.options)
+}
@@ -0,0 +1,201 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loader
+
+import (
+    "testing"
+
+    "github.com/storyicon/grbac/pkg/meta"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestDetectFormat(t *testing.T) {
+    tests := []struct {
+        name    string
+        file    string
+        want    string
+        wantErr bool
+    }{
+        {name: "test0", file: "rules.json", want: FormatJSON},
+        {name: "test1", file: "conf/rules.YML", want: FormatYAML},
+        {name: "test2", file: "rules.yaml", want: FormatYAML},
+        {name: "test3", file: "rules.toml", want: FormatTOML},
+        {name: "test4", file: "rules.hcl", want: FormatHCL},
+        {name: "test5", file: "rules.ini", wantErr: true},
+    }
+    for _, tt := range tests {
+        got, err := DetectFormat(tt.file)
+        if (err != nil) != tt.wantErr {
+            t.Errorf("%q. DetectFormat() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+            continue
+        }
+        if got != tt.want {
+            t.Errorf("%q. DetectFormat() = %v, want %v", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestUnmarshal(t *testing.T) {
+    want := meta.Rules{
+        {
+            ID:         1,
+            Resource:   &meta.Resource{Host: "*", Path: "**", Method: "*"},
+            Permission: &meta.Permission{AuthorizedRoles: []string{"*"}, ForbiddenRoles: []string{"black_user"}},
+        },
+        {
+            ID:         2,
+            Resource:   &meta.Resource{Host: "domain.com", Path: "/article", Method: "POST"},
+            Permission: &meta.Permission{AuthorizedRoles: []string{"editor"}},
+        },
+        {
+            ID:         2,
+            Resource:   &meta.Resource{Host: "domain.com", Path: "/article", Method: "PUT"},
+            Permission: &meta.Permission{AuthorizedRoles: []string{"editor"}},
+        },
+    }
+    tests := []struct {
+        name   string
+        format string
+        data   string
+    }{
+        {
+            name:   "json list",
+            format: FormatJSON,
+            data: `[
+                {"id": 1, "host": "*", "path": "**", "method": "*", "authorized_roles": ["*"], "forbidden_roles": ["black_user"]},
+                {"id": 2, "host": ["domain.com"], "path": ["/article"], "method": ["POST", "PUT"], "authorized_roles": ["editor"]}
+            ]`,
+        },
+        {
+            name:   "json document",
+            format: FormatJSON,
+            data: `{
+                "rules": [
+                    {"id": 1, "host": "*", "path": "**", "method": "*", "authorized_roles": ["*"], "forbidden_roles": ["black_user"]}
+                ],
+                "advanced_rules": [
+                    {"id": 2, "host": ["domain.com"], "path": ["/article"], "method": ["POST", "PUT"], "authorized_roles": ["editor"]}
+                ]
+            }`,
+        },
+        {
+            name:   "yaml list",
+            format: FormatYAML,
+            data: `
+- id: 1
+  host: "*"
+  path: "**"
+  method: "*"
+  authorized_roles: ["*"]
+  forbidden_roles: [black_user]
+- id: 2
+  host: [domain.com]
+  path: [/article]
+  method: [POST, PUT]
+  authorized_roles: [editor]
+`,
+        },
+        {
+            name:   "yaml document",
+            format: FormatYAML,
+            data: `
+rules:
+- id: 1
+  host: "*"
+  path: "**"
+  method: "*"
+  authorized_roles: ["*"]
+  forbidden_roles: [black_user]
+advanced_rules:
+- id: 2
+  host: [domain.com]
+  path: [/article]
+  method: [POST, PUT]
+  authorized_roles: [editor]
+`,
+        },
+        {
+            name:   "toml",
+            format: FormatTOML,
+            data: `
+[[rules]]
+id = 1
+host = "*"
+path = "**"
+method = "*"
+authorized_roles = ["*"]
+forbidden_roles = ["black_user"]
+
+[[advanced_rules]]
+id = 2
+host = ["domain.com"]
+path = ["/article"]
+method = ["POST", "PUT"]
+authorized_roles = ["editor"]
+`,
+        },
+        {
+            name:   "hcl",
+            format: FormatHCL,
+            data: `
+rule {
+    id               = 1
+    host             = "*"
+    path             = "**"
+    method           = "*"
+    authorized_roles = ["*"]
+    forbidden_roles  = ["black_user"]
+}
+
+advanced_rule {
+    id               = 2
+    host             = ["domain.com"]
+    path             = ["/article"]
+    method           = ["POST", "PUT"]
+    authorized_roles = ["editor"]
+}
+`,
+        },
+    }
+    for _, tt := range tests {
+        rules, err := Unmarshal(tt.format, []byte(tt.data))
+        assert.Equal(t, nil, err, tt.name)
+        assert.Equal(t, want.String(), rules.String(), tt.name)
+    }
+}
+
+func TestMarshal(t *testing.T) {
+    rules := meta.Rules{
+        {
+            ID:         1,
+            Resource:   &meta.Resource{Host: "*", Path: "**", Method: "*"},
+            Permission: &meta.Permission{AuthorizedRoles: []string{"*"}, ForbiddenRoles: []string{"black_user"}},
+        },
+        {
+            ID:         2,
+            Resource:   &meta.Resource{Host: "domain.com", Path: "/article", Method: "{POST,PUT}"},
+            Permission: &meta.Permission{AuthorizedRoles: []string{"editor"}, ForbiddenRoles: []string{}},
+        },
+    }
+    for _, format := range []string{FormatJSON, FormatYAML, FormatTOML} {
+        data, err := Marshal(format, rules)
+        assert.Equal(t, nil, err, format)
+        got, err := Decode(format, "rules."+format, data, Strict())
+        assert.Equal(t, nil, err, format)
+        assert.Equal(t, rules.String(), got.String(), format)
+    }
+    _, err := Marshal(FormatHCL, rules)
+    assert.Equal(t, ErrUnsupportedFormat, err)
+}
@@ -0,0 +1,238 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loader
+
+import (
+    "io/ioutil"
+    "reflect"
+    "strings"
+
+    "github.com/hashicorp/hcl"
+    "github.com/hashicorp/hcl/hcl/ast"
+    "github.com/hashicorp/hcl/hcl/parser"
+    "github.com/hashicorp/hcl/hcl/token"
+    "github.com/storyicon/grbac/pkg/meta"
+)
+
+// hclRule mirrors meta.Rule,
+// the hcl decoder is unable to decode embedded pointers.
+type hclRule struct {
+    ID     int    `hcl:"id"`
+    Host   string `hcl:"host"`
+    Path   string `hcl:"path"`
+    Method string `hcl:"method"`
+
+    AuthorizedRoles []string `hcl:"authorized_roles"`
+    ForbiddenRoles  []string `hcl:"forbidden_roles"`
+    AllowAnyone     bool     `hcl:"allow_anyone"`
+}
+
+// hclAdvancedRule mirrors AdvancedRule
+type hclAdvancedRule struct {
+    ID     int      `hcl:"id"`
+    Host   []string `hcl:"host"`
+    Path   []string `hcl:"path"`
+    Method []string `hcl:"method"`
+
+    AuthorizedRoles []string `hcl:"authorized_roles"`
+    ForbiddenRoles  []string `hcl:"forbidden_roles"`
+    AllowAnyone     bool     `hcl:"allow_anyone"`
+}
+
+// HCLLoader implements the Loader interface
+// it is used to load configuration from a local hcl file.
+type HCLLoader struct {
+    path    string
+    options *options
+}
+
+// NewHCLLoader is used to initialize a HCLLoader
+func NewHCLLoader(file string, opts ...Option) (*HCLLoader, error) {
+    loader := &HCLLoader{
+        path:    file,
+        options: newOptions(opts),
+    }
+    _, err := loader.Load()
+    if err != nil {
+        return nil, err
+    }
+    return loader, nil
+}
+
+// Load is used to return a list of rules
+func (loader *HCLLoader) Load() (meta.Rules, error) {
+    bytes, err := ioutil.ReadFile(loader.path)
+    if err != nil {
+        return nil, err
+    }
+    return decode(FormatHCL, loader.path, bytes, loader.options)
+}
+
+// unmarshalHCL is used to parse the rule and advanced_rule blocks of a hcl rule file
+//
+//  rule {
+//      id               = 0
+//      host             = "*"
+//      path             = "**"
+//      method           = "*"
+//      authorized_roles = ["*"]
+//  }
+//
+//  advanced_rule {
+//      id               = 1
+//      host             = ["domain.com"]
+//      path             = ["/article"]
+//      method           = ["DELETE", "POST", "PUT"]
+//      authorized_roles = ["editor"]
+//  }
+//
+// The blocks are decoded one by one,
+// because the hcl decoder flattens a single block containing lists when decoding it into a slice.
+func unmarshalHCL(bytes []byte) (meta.Rules, error) {
+    return decodeHCL("", bytes, false)
+}
+
+// unmarshalStrictHCL is the strict version of unmarshalHCL,
+// the positions of the errors are taken from the hcl syntax tree.
+func unmarshalStrictHCL(name string, bytes []byte) (meta.Rules, error) {
+    return decodeHCL(name, bytes, true)
+}
+
+func decodeHCL(name string, bytes []byte, strict bool) (meta.Rules, error) {
+    file, err := hcl.ParseBytes(bytes)
+    if err != nil {
+        return nil, hclError(name, Position{File: name}, err)
+    }
+    root, ok := file.Node.(*ast.ObjectList)
+    if !ok {
+        return nil, ErrUnsupportedFormat
+    }
+    var errs error
+    if strict {
+        known := map[string]bool{"rule": true, "advanced_rule": true}
+        for _, item := range root.Items {
+            key := hclKey(item)
+            if !known[key] {
+                errs = appendError(errs, newDecodeError(hclPosition(name, item.Pos()), "%s %q", ErrUnknownField, key))
+            }
+        }
+    }
+    doc := &Document{}
+    for _, block := range root.Filter("rule").Items {
+        item := &hclRule{}
+        err := decodeHCLBlock(name, block, item, strict)
+        if err != nil {
+            errs = appendError(errs, err)
+            continue
+        }
+        rule := &meta.Rule{
+            ID: item.ID,
+            Resource: &meta.Resource{
+                Host:   item.Host,
+                Path:   item.Path,
+                Method: item.Method,
+            },
+            Permission: &meta.Permission{
+                AuthorizedRoles: item.AuthorizedRoles,
+                ForbiddenRoles:  item.ForbiddenRoles,
+                AllowAnyone:     item.AllowAnyone,
+            },
+        }
+        if strict {
+            errs = appendError(errs, validateRule(hclPosition(name, block.Val.Pos()), rule))
+        }
+        doc.Rules = append(doc.Rules, rule)
+    }
+    for _, block := range root.Filter("advanced_rule").Items {
+        item := &hclAdvancedRule{}
+        err := decodeHCLBlock(name, block, item, strict)
+        if err != nil {
+            errs = appendError(errs, err)
+            continue
+        }
+        rule := &AdvancedRule{
+            ID:     item.ID,
+            Host:   item.Host,
+            Path:   item.Path,
+            Method: item.Method,
+            Permission: &meta.Permission{
+                AuthorizedRoles: item.AuthorizedRoles,
+                ForbiddenRoles:  item.ForbiddenRoles,
+                AllowAnyone:     item.AllowAnyone,
+            },
+        }
+        if strict {
+            errs = appendError(errs, validateAdvancedRule(hclPosition(name, block.Val.Pos()), rule))
+        }
+        doc.AdvancedRules = append(doc.AdvancedRules, rule)
+    }
+    if errs != nil {
+        return nil, errs
+    }
+    return doc.GetRules(), nil
+}
+
+// decodeHCLBlock is used to decode the body of a block into v,
+// unknown fields are rejected in strict mode.
+func decodeHCLBlock(name string, block *ast.ObjectItem, v interface{}, strict bool) error {
+    var errs error
+    if strict {
+        body, ok := block.Val.(*ast.ObjectType)
+        if !ok {
+            return newDecodeError(hclPosition(name, block.Val.Pos()), "%s", ErrExpectedObject)
+        }
+        known := knownFields(reflect.TypeOf(v), "hcl")
+        for _, item := range body.List.Items {
+            key := hclKey(item)
+            if !known[key] {
+                errs = appendError(errs, newDecodeError(hclPosition(name, item.Pos()), "%s %q", ErrUnknownField, key))
+            }
+        }
+    }
+    err := hcl.DecodeObject(v, block.Val)
+    if err != nil {
+        errs = appendError(errs, hclError(name, hclPosition(name, block.Val.Pos()), err))
+    }
+    return errs
+}
+
+// hclError is used to convert the errors of hcl into DecodeErrors
+func hclError(name string, pos Position, err error) error {
+    if posErr, ok := err.(*parser.PosError); ok {
+        return &DecodeError{
+            Position: hclPosition(name, posErr.Pos),
+            Err:      posErr.Err,
+        }
+    }
+    return &DecodeError{
+        Position: pos,
+        Err:      err,
+    }
+}
+
+func hclKey(item *ast.ObjectItem) string {
+    if len(item.Keys) == 0 {
+        return ""
+    }
+    return strings.Trim(item.Keys[0].Token.Text, `"`)
+}
+
+func hclPosition(name string, pos token.Pos) Position {
+    return Position{
+        File:   name,
+        Line:   pos.Line,
+        Column: pos.Column,
+    }
+}
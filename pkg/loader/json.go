@@ -15,7 +15,12 @@
 package loader
 
 import (
+    "bytes"
+    "encoding/json"
+    "errors"
     "io/ioutil"
+    "reflect"
+    "strings"
 
     jsoniter "github.com/json-iterator/go"
     "github.com/storyicon/grbac/pkg/meta"
@@ -24,13 +29,15 @@ import (
 // JSONLoader implements the Loader interface
 // it is used to load configuration from a local json file.
 type JSONLoader struct {
-    path string
+    path    string
+    options *options
 }
 
 // NewJSONLoader is used to initialize a JSONLoader
-func NewJSONLoader(file string) (*JSONLoader, error) {
+func NewJSONLoader(file string, opts ...Option) (*JSONLoader, error) {
     loader := &JSONLoader{
-        path: file,
+        path:    file,
+        options: newOptions(opts),
     }
     _, err := loader.Load()
     if err != nil {
@@ -45,10 +52,244 @@ func (loader *JSONLoader) Load() (meta.Rules, error) {
     if err != nil {
         return nil, err
     }
-    rules := meta.Rules{}
-    err = jsoniter.Unmarshal(bytes, &rules)
+    return decode(FormatJSON, loader.path, bytes, loader.options)
+}
+
+// unmarshalJSON is used to parse a json rule file,
+// which is either a list of rules and advanced rules or a Document.
+func unmarshalJSON(bytes []byte) (meta.Rules, error) {
+    if jsoniter.Get(bytes).ValueType() == jsoniter.ObjectValue {
+        doc := &Document{}
+        err := jsoniter.Unmarshal(bytes, doc)
+        if err != nil {
+            return nil, err
+        }
+        return doc.GetRules(), nil
+    }
+    var items []jsoniter.RawMessage
+    err := jsoniter.Unmarshal(bytes, &items)
     if err != nil {
         return nil, err
     }
-    return rules, nil
+    doc := &Document{}
+    for _, item := range items {
+        if isAdvancedJSON(item) {
+            rule := &AdvancedRule{}
+            err := jsoniter.Unmarshal(item, rule)
+            if err != nil {
+                return nil, err
+            }
+            doc.AdvancedRules = append(doc.AdvancedRules, rule)
+            continue
+        }
+        rule := &meta.Rule{}
+        err := jsoniter.Unmarshal(item, rule)
+        if err != nil {
+            return nil, err
+        }
+        doc.Rules = append(doc.Rules, rule)
+    }
+    return doc.GetRules(), nil
+}
+
+// isAdvancedJSON is used to determine whether the item is written in the AdvancedRule schema,
+// whose host, path or method are lists.
+func isAdvancedJSON(item []byte) bool {
+    for _, key := range []string{"host", "path", "method"} {
+        if jsoniter.Get(item, key).ValueType() == jsoniter.ArrayValue {
+            return true
+        }
+    }
+    return false
+}
+
+// jsonMember is a member of a json object with the offsets of its key and value
+type jsonMember struct {
+    key         string
+    keyOffset   int
+    value       json.RawMessage
+    valueOffset int
+}
+
+// unmarshalStrictJSON is the strict version of unmarshalJSON,
+// the positions of the errors are calculated from the offsets of the json tokens.
+func unmarshalStrictJSON(name string, data []byte) (meta.Rules, error) {
+    var v interface{}
+    err := json.Unmarshal(data, &v)
+    if err != nil {
+        return nil, jsonError(name, data, len(data), err)
+    }
+    doc := &Document{}
+    var errs error
+    switch jsonKind(data) {
+    case '{':
+        members, err := jsonMembers(data, 0)
+        if err != nil {
+            return nil, jsonError(name, data, 0, err)
+        }
+        known := knownFields(reflect.TypeOf(doc), "json")
+        for _, member := range members {
+            if !known[member.key] {
+                errs = appendError(errs, newDecodeError(offsetPosition(name, data, member.keyOffset), "%s %q", ErrUnknownField, member.key))
+                continue
+            }
+            if member.key == "vars" {
+                continue
+            }
+            switch jsonKind(member.value) {
+            case 'n':
+                continue
+            case '[':
+            default:
+                errs = appendError(errs, newDecodeError(offsetPosition(name, data, member.valueOffset), "%s", ErrExpectedList))
+                continue
+            }
+            items, offsets, err := jsonElements(member.value, member.valueOffset)
+            if err != nil {
+                return nil, jsonError(name, data, member.valueOffset, err)
+            }
+            for i, item := range items {
+                errs = appendError(errs, decodeStrictJSONItem(name, data, item, offsets[i], doc, member.key == "advanced_rules"))
+            }
+        }
+    case '[':
+        items, offsets, err := jsonElements(data, 0)
+        if err != nil {
+            return nil, jsonError(name, data, 0, err)
+        }
+        for i, item := range items {
+            errs = appendError(errs, decodeStrictJSONItem(name, data, item, offsets[i], doc, isAdvancedJSON(item)))
+        }
+    case 'n':
+    default:
+        errs = newDecodeError(offsetPosition(name, data, len(data)-len(bytes.TrimLeft(data, " \t\r\n"))), "%s", ErrUnexpectedSchema)
+    }
+    if errs != nil {
+        return nil, errs
+    }
+    return doc.GetRules(), nil
+}
+
+// decodeStrictJSONItem is used to decode and validate a rule or an advanced rule
+func decodeStrictJSONItem(name string, data []byte, item []byte, offset int, doc *Document, advanced bool) error {
+    pos := offsetPosition(name, data, offset)
+    if advanced {
+        rule := &AdvancedRule{}
+        err := decodeStrictJSON(name, data, item, offset, rule)
+        if err != nil {
+            return err
+        }
+        doc.AdvancedRules = append(doc.AdvancedRules, rule)
+        return validateAdvancedRule(pos, rule)
+    }
+    rule := &meta.Rule{}
+    err := decodeStrictJSON(name, data, item, offset, rule)
+    if err != nil {
+        return err
+    }
+    doc.Rules = append(doc.Rules, rule)
+    return validateRule(pos, rule)
+}
+
+// decodeStrictJSON is used to decode the json object found at offset of data into v.
+// Every unknown field and every field that fails to decode is reported with its position.
+func decodeStrictJSON(name string, data []byte, object []byte, offset int, v interface{}) error {
+    if jsonKind(object) != '{' {
+        return newDecodeError(offsetPosition(name, data, offset), "%s", ErrExpectedObject)
+    }
+    members, err := jsonMembers(object, offset)
+    if err != nil {
+        return jsonError(name, data, offset, err)
+    }
+    t := reflect.TypeOf(v).Elem()
+    known := knownFields(t, "json")
+    var errs error
+    for _, member := range members {
+        if !known[member.key] {
+            errs = appendError(errs, newDecodeError(offsetPosition(name, data, member.keyOffset), "%s %q", ErrUnknownField, member.key))
+            continue
+        }
+        key, _ := json.Marshal(member.key)
+        pair := append(append(append([]byte{'{'}, key...), ':'), member.value...)
+        err := json.Unmarshal(append(pair, '}'), reflect.New(t).Interface())
+        if err != nil {
+            errs = appendError(errs, jsonError(name, data, member.valueOffset, err))
+        }
+    }
+    if errs != nil {
+        return errs
+    }
+    return json.Unmarshal(object, v)
+}
+
+// jsonMembers is used to split a json object into its members,
+// base is the offset of the object in the whole document.
+func jsonMembers(object []byte, base int) ([]*jsonMember, error) {
+    dec := json.NewDecoder(bytes.NewReader(object))
+    _, err := dec.Token()
+    if err != nil {
+        return nil, err
+    }
+    var members []*jsonMember
+    for dec.More() {
+        token, err := dec.Token()
+        if err != nil {
+            return nil, err
+        }
+        key, _ := token.(string)
+        quoted, _ := json.Marshal(key)
+        member := &jsonMember{
+            key:       key,
+            keyOffset: base + int(dec.InputOffset()) - len(quoted),
+        }
+        err = dec.Decode(&member.value)
+        if err != nil {
+            return nil, err
+        }
+        member.valueOffset = base + int(dec.InputOffset()) - len(member.value)
+        members = append(members, member)
+    }
+    return members, nil
+}
+
+// jsonElements is used to split a json array into its elements,
+// base is the offset of the array in the whole document.
+func jsonElements(array []byte, base int) ([]json.RawMessage, []int, error) {
+    dec := json.NewDecoder(bytes.NewReader(array))
+    _, err := dec.Token()
+    if err != nil {
+        return nil, nil, err
+    }
+    var elements []json.RawMessage
+    var offsets []int
+    for dec.More() {
+        var element json.RawMessage
+        err := dec.Decode(&element)
+        if err != nil {
+            return nil, nil, err
+        }
+        elements = append(elements, element)
+        offsets = append(offsets, base+int(dec.InputOffset())-len(element))
+    }
+    return elements, offsets, nil
+}
+
+// jsonKind returns the first significant byte of a json value
+func jsonKind(value []byte) byte {
+    value = bytes.TrimLeft(value, " \t\r\n")
+    if len(value) == 0 {
+        return 0
+    }
+    return value[0]
+}
+
+// jsonError is used to convert the errors of encoding/json into DecodeErrors
+func jsonError(name string, data []byte, offset int, err error) error {
+    if syntaxErr, ok := err.(*json.SyntaxError); ok {
+        offset = int(syntaxErr.Offset) - 1
+    }
+    return &DecodeError{
+        Position: offsetPosition(name, data, offset),
+        Err:      errors.New(strings.TrimPrefix(err.Error(), "json: ")),
+    }
 }
@@ -0,0 +1,456 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loader
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "sort"
+    "strings"
+
+    "github.com/storyicon/grbac/pkg/meta"
+    "github.com/storyicon/grbac/pkg/path"
+    "gopkg.in/yaml.v3"
+)
+
+// define a set of errors
+var (
+    ErrUnknownVerb     = errors.New("unknown verb")
+    ErrUnknownKind     = errors.New("unknown kind")
+    ErrUndefinedRole   = errors.New("undefined role")
+    ErrUnknownRoleKind = errors.New("unknown role kind")
+    ErrNamespacedURLs  = errors.New("nonResourceURLs are only granted by a ClusterRole bound by a ClusterRoleBinding")
+)
+
+// kubernetesVerbs maps the verbs of non-resource urls to http methods
+var kubernetesVerbs = map[string][]string{
+    "get":     {"GET", "HEAD"},
+    "head":    {"HEAD"},
+    "post":    {"POST"},
+    "create":  {"POST"},
+    "put":     {"PUT"},
+    "update":  {"PUT"},
+    "patch":   {"PATCH"},
+    "delete":  {"DELETE"},
+    "options": {"OPTIONS"},
+}
+
+// kubernetesObject defines the fields of the rbac.authorization.k8s.io objects used by grbac
+type kubernetesObject struct {
+    APIVersion string `yaml:"apiVersion"`
+    Kind       string `yaml:"kind"`
+    Metadata   struct {
+        Name      string `yaml:"name"`
+        Namespace string `yaml:"namespace"`
+    } `yaml:"metadata"`
+    Rules []struct {
+        NonResourceURLs []string `yaml:"nonResourceURLs"`
+        Verbs           []string `yaml:"verbs"`
+    } `yaml:"rules"`
+    RoleRef struct {
+        Kind string `yaml:"kind"`
+        Name string `yaml:"name"`
+    } `yaml:"roleRef"`
+    Subjects []struct {
+        Kind      string `yaml:"kind"`
+        Name      string `yaml:"name"`
+        Namespace string `yaml:"namespace"`
+    } `yaml:"subjects"`
+    Items []*kubernetesObject `yaml:"items"`
+}
+
+func (object *kubernetesObject) String() string {
+    if object.Metadata.Namespace != "" {
+        return object.Kind + "/" + object.Metadata.Namespace + "/" + object.Metadata.Name
+    }
+    return object.Kind + "/" + object.Metadata.Name
+}
+
+// KubernetesLoader implements the Loader interface
+// it is used to load configuration from local yaml files of Kubernetes RBAC objects.
+//
+// The nonResourceURLs and verbs of Roles and ClusterRoles become the paths and methods of the rules,
+// and the subjects bound to them by RoleBindings and ClusterRoleBindings become the authorized roles:
+//
+//  kind: ClusterRole
+//  metadata:
+//    name: article-editor
+//  rules:
+//  - nonResourceURLs: ["/articles", "/articles/*"]
+//    verbs: ["get", "post", "put"]
+//  ---
+//  kind: ClusterRoleBinding
+//  metadata:
+//    name: editors
+//  roleRef:
+//    kind: ClusterRole
+//    name: article-editor
+//  subjects:
+//  - kind: Group
+//    name: editor
+//
+// A trailing * of a url matches any path below it, like it does in Kubernetes.
+// Like Kubernetes, the nonResourceURLs of a Role, or of a ClusterRole bound by a RoleBinding, are not granted,
+// they are reported in strict mode.
+// Users and groups are named as they are, service accounts are named system:serviceaccount:<namespace>:<name>.
+// Rules of resources are ignored, because they only make sense for the cluster.
+type KubernetesLoader struct {
+    path    string
+    options *options
+}
+
+// NewKubernetesLoader is used to initialize a KubernetesLoader
+func NewKubernetesLoader(file string, opts ...Option) (*KubernetesLoader, error) {
+    loader := &KubernetesLoader{
+        path:    file,
+        options: newOptions(opts),
+    }
+    _, err := loader.Load()
+    if err != nil {
+        return nil, err
+    }
+    return loader, nil
+}
+
+// Load is used to return a list of rules
+func (loader *KubernetesLoader) Load() (meta.Rules, error) {
+    bytes, err := ioutil.ReadFile(loader.path)
+    if err != nil {
+        return nil, err
+    }
+    documents, err := yamlDocuments(bytes)
+    if err != nil {
+        return nil, err
+    }
+    if loader.options.expand {
+        var errs error
+        for _, document := range documents {
+            errs = appendError(errs, expandYAML(loader.path, document))
+        }
+        if errs != nil {
+            return nil, errs
+        }
+    }
+    return kubernetesRules(documents, loader.options.strict)
+}
+
+// yamlDocuments is used to parse the documents of a yaml stream
+func yamlDocuments(data []byte) ([]*yaml.Node, error) {
+    var documents []*yaml.Node
+    decoder := yaml.NewDecoder(bytes.NewReader(data))
+    for {
+        document := &yaml.Node{}
+        err := decoder.Decode(document)
+        if err == io.EOF {
+            return documents, nil
+        }
+        if err != nil {
+            return nil, err
+        }
+        documents = append(documents, document)
+    }
+}
+
+// kubernetesRules is used to convert the parsed yaml documents of Kubernetes RBAC objects into rules
+func kubernetesRules(documents []*yaml.Node, strict bool) (meta.Rules, error) {
+    var objects []*kubernetesObject
+    for _, document := range documents {
+        object := &kubernetesObject{}
+        if err := document.Decode(object); err != nil {
+            return nil, err
+        }
+        if object.Kind == "List" {
+            objects = append(objects, object.Items...)
+            continue
+        }
+        objects = append(objects, object)
+    }
+
+    roles := map[string]*kubernetesObject{}
+    var bindings []*kubernetesObject
+    var errs error
+    for _, object := range objects {
+        if object == nil || object.Kind == "" {
+            continue
+        }
+        switch object.Kind {
+        case "ClusterRole":
+            roles[object.Kind+"/"+object.Metadata.Name] = object
+        case "Role":
+            roles[object.String()] = object
+            if strict && hasURLs(object) {
+                errs = appendError(errs, fmt.Errorf("%s: %s", object, ErrNamespacedURLs))
+            }
+        case "ClusterRoleBinding", "RoleBinding":
+            bindings = append(bindings, object)
+        default:
+            if strict {
+                errs = appendError(errs, fmt.Errorf("%s: %s %q", object, ErrUnknownKind, object.Kind))
+            }
+        }
+    }
+
+    var grants []*kubernetesGrant
+    for _, binding := range bindings {
+        role, err := bindingRole(binding, roles)
+        if err != nil {
+            errs = appendError(errs, err)
+            continue
+        }
+        if binding.Kind != "ClusterRoleBinding" {
+            // the Roles are reported by themselves
+            if strict && role.Kind == "ClusterRole" && hasURLs(role) {
+                errs = appendError(errs, fmt.Errorf("%s: %s", binding, ErrNamespacedURLs))
+            }
+            continue
+        }
+        subjects := bindingSubjects(binding)
+        for _, rule := range role.Rules {
+            if len(rule.NonResourceURLs) == 0 {
+                continue
+            }
+            methods, err := kubernetesMethods(rule.Verbs)
+            if err != nil {
+                errs = appendError(errs, fmt.Errorf("%s: %s", role, err))
+                continue
+            }
+            for _, url := range rule.NonResourceURLs {
+                for _, p := range kubernetesPaths(url) {
+                    for _, method := range methods {
+                        grants = append(grants, &kubernetesGrant{path: p, method: method, subjects: subjects})
+                    }
+                }
+            }
+        }
+    }
+    if errs != nil {
+        return nil, errs
+    }
+    return mergeKubernetesGrants(grants), nil
+}
+
+// kubernetesGrant defines the subjects granted to a path by a single method or *
+type kubernetesGrant struct {
+    path     string
+    method   string
+    subjects []string
+}
+
+// coversPath is used to determine whether the requests of path b are all matched by the path a,
+// the paths converted from the non-resource urls are either nested or disjoint.
+func coversPath(a string, b string) bool {
+    if a == b {
+        return true
+    }
+    matched, err := path.Match(a, b)
+    return err == nil && matched
+}
+
+// mergeKubernetesGrants is used to convert the grants into rules.
+// Kubernetes RBAC is additive, while only the rule with the highest ID decides a request in grbac,
+// so every rule carries the subjects of all the grants matching its requests,
+// the rules of the more specific paths get the higher IDs, and the rules of single methods get
+// higher IDs than the rule of * on the same path. When a path granted by * is inside a path
+// granted by a single method, a rule of that method is added to the inner path to carry both.
+func mergeKubernetesGrants(grants []*kubernetesGrant) meta.Rules {
+    var paths []string
+    methods := map[string][]string{}
+    for _, grant := range grants {
+        if _, ok := methods[grant.path]; !ok {
+            paths = append(paths, grant.path)
+        }
+        methods[grant.path] = appendUnique(methods[grant.path], grant.method)
+    }
+    wildcard := map[string]bool{}
+    for _, p := range paths {
+        for _, method := range methods[p] {
+            wildcard[p] = wildcard[p] || method == "*"
+        }
+    }
+    for _, grant := range grants {
+        if grant.method == "*" {
+            continue
+        }
+        for _, p := range paths {
+            if wildcard[p] && coversPath(grant.path, p) {
+                methods[p] = appendUnique(methods[p], grant.method)
+            }
+        }
+    }
+
+    // depth is the number of paths covering a path, which grows with its specificity
+    depth := map[string]int{}
+    var resources []meta.Resource
+    permissions := map[meta.Resource]*meta.Permission{}
+    for _, p := range paths {
+        for _, other := range paths {
+            if coversPath(other, p) {
+                depth[p]++
+            }
+        }
+        // the single methods of the same subjects are merged into one rule
+        var keys []string
+        groups := map[string][]string{}
+        subjects := map[string][]string{}
+        for _, method := range methods[p] {
+            var granted []string
+            for _, grant := range grants {
+                if (grant.method == "*" || grant.method == method) && coversPath(grant.path, p) {
+                    for _, subject := range grant.subjects {
+                        granted = appendUnique(granted, subject)
+                    }
+                }
+            }
+            key := strings.Join(granted, "\n")
+            if method == "*" {
+                key = "*"
+            }
+            if _, ok := groups[key]; !ok {
+                keys = append(keys, key)
+                subjects[key] = granted
+            }
+            groups[key] = append(groups[key], method)
+        }
+        for _, key := range keys {
+            resource := meta.Resource{Host: "*", Path: p, Method: methodPattern(groups[key])}
+            resources = append(resources, resource)
+            permissions[resource] = &meta.Permission{AuthorizedRoles: subjects[key]}
+        }
+    }
+
+    rules := meta.Rules{}
+    for i := range resources {
+        resource := resources[i]
+        rules = append(rules, &meta.Rule{
+            Resource:   &resource,
+            Permission: permissions[resource],
+        })
+    }
+    rules.Prioritize(0, func(rule *meta.Rule) int {
+        return depth[rule.Path]
+    })
+    return rules
+}
+
+// hasURLs is used to determine whether a role has nonResourceURLs
+func hasURLs(role *kubernetesObject) bool {
+    for _, rule := range role.Rules {
+        if len(rule.NonResourceURLs) != 0 {
+            return true
+        }
+    }
+    return false
+}
+
+// bindingRole is used to find the role referenced by a binding
+func bindingRole(binding *kubernetesObject, roles map[string]*kubernetesObject) (*kubernetesObject, error) {
+    var key string
+    switch binding.RoleRef.Kind {
+    case "ClusterRole":
+        key = "ClusterRole/" + binding.RoleRef.Name
+    case "Role":
+        if binding.Kind != "RoleBinding" {
+            return nil, fmt.Errorf("%s: %s %q", binding, ErrUnknownRoleKind, binding.RoleRef.Kind)
+        }
+        key = "Role/" + binding.Metadata.Namespace + "/" + binding.RoleRef.Name
+        if binding.Metadata.Namespace == "" {
+            key = "Role/" + binding.RoleRef.Name
+        }
+    default:
+        return nil, fmt.Errorf("%s: %s %q", binding, ErrUnknownRoleKind, binding.RoleRef.Kind)
+    }
+    role, ok := roles[key]
+    if !ok {
+        return nil, fmt.Errorf("%s: %s %q", binding, ErrUndefinedRole, key)
+    }
+    return role, nil
+}
+
+// bindingSubjects is used to convert the subjects of a binding into role names
+func bindingSubjects(binding *kubernetesObject) []string {
+    var subjects []string
+    for _, subject := range binding.Subjects {
+        if subject.Kind == "ServiceAccount" {
+            namespace := subject.Namespace
+            if namespace == "" {
+                namespace = binding.Metadata.Namespace
+            }
+            subjects = append(subjects, "system:serviceaccount:"+namespace+":"+subject.Name)
+            continue
+        }
+        subjects = append(subjects, subject.Name)
+    }
+    return subjects
+}
+
+// kubernetesPaths is used to convert a non-resource url into resource paths.
+// A trailing * is a prefix match like Kubernetes does, /healthz* matches /healthz, /healthzx and /healthz/ping,
+// which takes two paths because a wildcard does not match the separators.
+func kubernetesPaths(url string) []string {
+    switch {
+    case url == "*":
+        return []string{"**"}
+    case strings.HasSuffix(url, "/*"):
+        return []string{strings.TrimSuffix(url, "*") + "**"}
+    case strings.HasSuffix(url, "*"):
+        return []string{url, url + "/**"}
+    }
+    return []string{url}
+}
+
+// kubernetesMethods is used to convert verbs into sorted http methods, or * for any method
+func kubernetesMethods(verbs []string) ([]string, error) {
+    var methods []string
+    for _, verb := range verbs {
+        if verb == "*" {
+            return []string{"*"}, nil
+        }
+        converted, ok := kubernetesVerbs[strings.ToLower(verb)]
+        if !ok {
+            return nil, fmt.Errorf("%s %q", ErrUnknownVerb, verb)
+        }
+        for _, method := range converted {
+            methods = appendUnique(methods, method)
+        }
+    }
+    if len(methods) == 0 {
+        return nil, fmt.Errorf("%s %q", ErrUnknownVerb, "")
+    }
+    sort.Strings(methods)
+    return methods, nil
+}
+
+// methodPattern is used to convert methods into a method pattern
+func methodPattern(methods []string) string {
+    methods = append([]string(nil), methods...)
+    sort.Strings(methods)
+    if len(methods) == 1 {
+        return methods[0]
+    }
+    return "{" + strings.Join(methods, ",") + "}"
+}
+
+// appendUnique is used to append a string without duplicates
+func appendUnique(list []string, s string) []string {
+    for _, item := range list {
+        if item == s {
+            return list
+        }
+    }
+    return append(list, s)
+}
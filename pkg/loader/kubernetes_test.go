@@ -0,0 +1,283 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loader
+
+import (
+    "testing"
+
+    "github.com/storyicon/grbac/pkg/meta"
+    "github.com/stretchr/testify/assert"
+)
+
+// unmarshalKubernetes is used to convert yaml documents of Kubernetes RBAC objects into rules.
+// In strict mode, objects of other kinds are rejected instead of being ignored.
+func unmarshalKubernetes(data []byte, strict bool) (meta.Rules, error) {
+    documents, err := yamlDocuments(data)
+    if err != nil {
+        return nil, err
+    }
+    return kubernetesRules(documents, strict)
+}
+
+const kubernetesObjects = `
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: article-reader
+rules:
+- nonResourceURLs: ["/articles", "/articles/*"]
+  verbs: ["get"]
+- apiGroups: [""]
+  resources: ["pods"]
+  verbs: ["list"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: article-editor
+rules:
+- nonResourceURLs: ["/articles/*"]
+  verbs: ["create", "update", "delete"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: article-editor
+  namespace: blog
+rules:
+- nonResourceURLs: ["/**"]
+  verbs: ["*"]
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: rbac.authorization.k8s.io/v1
+  kind: ClusterRoleBinding
+  metadata:
+    name: readers
+  roleRef:
+    apiGroup: rbac.authorization.k8s.io
+    kind: ClusterRole
+    name: article-reader
+  subjects:
+  - kind: Group
+    name: reader
+  - kind: ServiceAccount
+    name: crawler
+    namespace: search
+- apiVersion: rbac.authorization.k8s.io/v1
+  kind: ClusterRoleBinding
+  metadata:
+    name: editors
+  roleRef:
+    kind: ClusterRole
+    name: article-editor
+  subjects:
+  - kind: User
+    name: alice
+  - kind: Group
+    name: editor
+- apiVersion: rbac.authorization.k8s.io/v1
+  kind: RoleBinding
+  metadata:
+    name: admins
+    namespace: blog
+  roleRef:
+    kind: Role
+    name: article-editor
+  subjects:
+  - kind: User
+    name: mallory
+- apiVersion: rbac.authorization.k8s.io/v1
+  kind: RoleBinding
+  metadata:
+    name: writers
+    namespace: blog
+  roleRef:
+    kind: ClusterRole
+    name: article-editor
+  subjects:
+  - kind: User
+    name: bob
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: crawler
+  namespace: search
+`
+
+func TestUnmarshalKubernetes(t *testing.T) {
+    rules, err := unmarshalKubernetes([]byte(kubernetesObjects), false)
+    assert.Equal(t, nil, err)
+    want := meta.Rules{
+        {
+            ID:         0,
+            Resource:   &meta.Resource{Host: "*", Path: "/articles/**", Method: "{DELETE,POST,PUT}"},
+            Permission: &meta.Permission{AuthorizedRoles: []string{"alice", "editor"}},
+        },
+        {
+            ID:         1,
+            Resource:   &meta.Resource{Host: "*", Path: "/articles/**", Method: "{GET,HEAD}"},
+            Permission: &meta.Permission{AuthorizedRoles: []string{"reader", "system:serviceaccount:search:crawler"}},
+        },
+        {
+            ID:         2,
+            Resource:   &meta.Resource{Host: "*", Path: "/articles", Method: "{GET,HEAD}"},
+            Permission: &meta.Permission{AuthorizedRoles: []string{"reader", "system:serviceaccount:search:crawler"}},
+        },
+    }
+    assert.Equal(t, want.String(), rules.String())
+
+    // the nonResourceURLs of a Role or a RoleBinding are not granted by Kubernetes
+    _, err = unmarshalKubernetes([]byte(kubernetesObjects), true)
+    assert.Equal(t, []string{
+        `Role/blog/article-editor: nonResourceURLs are only granted by a ClusterRole bound by a ClusterRoleBinding`,
+        `ServiceAccount/search/crawler: unknown kind "ServiceAccount"`,
+        `RoleBinding/blog/writers: nonResourceURLs are only granted by a ClusterRole bound by a ClusterRoleBinding`,
+    }, getErrors(err))
+}
+
+func TestUnmarshalKubernetesErrors(t *testing.T) {
+    data := `
+kind: ClusterRole
+metadata:
+  name: reader
+rules:
+- nonResourceURLs: ["/articles"]
+  verbs: ["watch"]
+---
+kind: ClusterRoleBinding
+metadata:
+  name: readers
+roleRef:
+  kind: ClusterRole
+  name: reader
+subjects:
+- kind: Group
+  name: reader
+---
+kind: RoleBinding
+metadata:
+  name: editors
+  namespace: blog
+roleRef:
+  kind: Role
+  name: editor
+`
+    _, err := unmarshalKubernetes([]byte(data), false)
+    assert.Equal(t, []string{
+        `ClusterRole/reader: unknown verb "watch"`,
+        `RoleBinding/blog/editors: undefined role "Role/blog/editor"`,
+    }, getErrors(err))
+}
+
+func TestUnmarshalKubernetesAdditive(t *testing.T) {
+    data := `
+kind: ClusterRole
+metadata:
+  name: reader
+rules:
+- nonResourceURLs: ["/articles/*"]
+  verbs: ["get"]
+- nonResourceURLs: ["/healthz*"]
+  verbs: ["get"]
+---
+kind: ClusterRole
+metadata:
+  name: admin
+rules:
+- nonResourceURLs: ["/articles/hot"]
+  verbs: ["*"]
+- nonResourceURLs: ["/articles/*"]
+  verbs: ["delete"]
+---
+kind: ClusterRole
+metadata:
+  name: writer
+rules:
+- nonResourceURLs: ["/articles/hot"]
+  verbs: ["get", "post"]
+---
+kind: ClusterRoleBinding
+metadata:
+  name: viewers
+roleRef:
+  kind: ClusterRole
+  name: reader
+subjects:
+- kind: Group
+  name: viewer
+---
+kind: ClusterRoleBinding
+metadata:
+  name: admins
+roleRef:
+  kind: ClusterRole
+  name: admin
+subjects:
+- kind: Group
+  name: admins
+---
+kind: ClusterRoleBinding
+metadata:
+  name: writers
+roleRef:
+  kind: ClusterRole
+  name: writer
+subjects:
+- kind: Group
+  name: writer
+`
+    rules, err := unmarshalKubernetes([]byte(data), true)
+    assert.Equal(t, nil, err)
+    tests := []struct {
+        name    string
+        method  string
+        path    string
+        roles   []string
+        granted bool
+    }{
+        {name: "test0", method: "GET", path: "/articles/hot", roles: []string{"viewer"}, granted: true},
+        {name: "test1", method: "GET", path: "/articles/hot", roles: []string{"admins"}, granted: true},
+        {name: "test2", method: "PUT", path: "/articles/hot", roles: []string{"admins"}, granted: true},
+        {name: "test3", method: "PUT", path: "/articles/hot", roles: []string{"viewer"}, granted: false},
+        {name: "test4", method: "DELETE", path: "/articles/hot", roles: []string{"viewer"}, granted: false},
+        {name: "test5", method: "DELETE", path: "/articles/1", roles: []string{"admins"}, granted: true},
+        {name: "test6", method: "GET", path: "/articles/1", roles: []string{"admins"}, granted: false},
+        {name: "test7", method: "HEAD", path: "/articles/hot", roles: []string{"viewer"}, granted: true},
+        {name: "test8", method: "HEAD", path: "/articles/hot", roles: []string{"writer"}, granted: true},
+        {name: "test9", method: "POST", path: "/articles/hot", roles: []string{"writer"}, granted: true},
+        {name: "test10", method: "POST", path: "/articles/hot", roles: []string{"viewer"}, granted: false},
+        {name: "test11", method: "GET", path: "/healthz", roles: []string{"viewer"}, granted: true},
+        {name: "test12", method: "GET", path: "/healthzx", roles: []string{"viewer"}, granted: true},
+        {name: "test13", method: "GET", path: "/healthz/ping", roles: []string{"viewer"}, granted: true},
+        {name: "test14", method: "GET", path: "/health", roles: []string{"viewer"}, granted: false},
+    }
+    for _, tt := range tests {
+        query := &meta.Query{Host: "domain.com", Path: tt.path, Method: tt.method}
+        var matched meta.Rules
+        for _, rule := range rules {
+            ok, err := rule.Match(query)
+            assert.Equal(t, nil, err, tt.name)
+            if ok {
+                matched = append(matched, rule)
+            }
+        }
+        state, err := matched.IsRolesGranted(tt.roles)
+        assert.Equal(t, nil, err, tt.name)
+        assert.Equal(t, tt.granted, state.IsGranted(), tt.name)
+    }
+}
@@ -0,0 +1,170 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loader
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "reflect"
+    "strings"
+
+    "github.com/hashicorp/go-multierror"
+    "github.com/storyicon/grbac/pkg/meta"
+)
+
+// define a set of errors
+var (
+    ErrUnknownField     = errors.New("unknown field")
+    ErrUnexpectedSchema = errors.New("expected a list of rules or a document")
+    ErrExpectedObject   = errors.New("expected an object")
+    ErrExpectedList     = errors.New("expected a list")
+)
+
+// Option is used to customize the file loaders
+type Option func(*options)
+
+type options struct {
+    strict bool
+    expand bool
+}
+
+func newOptions(opts []Option) *options {
+    o := &options{}
+    for _, opt := range opts {
+        opt(o)
+    }
+    return o
+}
+
+// Strict makes the file loaders reject unknown fields,
+// and validate every rule while decoding.
+// Decoding and validation errors are reported with the position(file:line:column)
+// of the offending field or rule, all errors of a file are reported at once.
+func Strict() Option {
+    return func(o *options) {
+        o.strict = true
+    }
+}
+
+// Position defines a location in a rule file.
+// Line and Column start from 1, zero means unknown.
+type Position struct {
+    File   string
+    Line   int
+    Column int
+}
+
+func (pos Position) String() string {
+    s := pos.File
+    if s == "" {
+        s = "<input>"
+    }
+    if pos.Line > 0 {
+        s += fmt.Sprintf(":%d", pos.Line)
+        if pos.Column > 0 {
+            s += fmt.Sprintf(":%d", pos.Column)
+        }
+    }
+    return s
+}
+
+// DecodeError is an error located in a rule file
+type DecodeError struct {
+    Position
+    Err error
+}
+
+func (e *DecodeError) Error() string {
+    return e.Position.String() + ": " + e.Err.Error()
+}
+
+func newDecodeError(pos Position, format string, args ...interface{}) *DecodeError {
+    return &DecodeError{
+        Position: pos,
+        Err:      fmt.Errorf(format, args...),
+    }
+}
+
+// offsetPosition is used to convert an offset of data into a Position
+func offsetPosition(name string, data []byte, offset int) Position {
+    if offset > len(data) {
+        offset = len(data)
+    }
+    if offset < 0 {
+        offset = 0
+    }
+    return Position{
+        File:   name,
+        Line:   1 + bytes.Count(data[:offset], []byte{'\n'}),
+        Column: offset - bytes.LastIndexByte(data[:offset], '\n'),
+    }
+}
+
+// validateRule is used to validate a decoded rule at the given position
+func validateRule(pos Position, rule *meta.Rule) error {
+    err := rule.IsValid()
+    if err != nil {
+        return newDecodeError(pos, "invalid rule: %s", err)
+    }
+    return nil
+}
+
+// validateAdvancedRule is used to validate a decoded advanced rule at the given position
+func validateAdvancedRule(pos Position, rule *AdvancedRule) error {
+    err := rule.IsValid()
+    if err != nil {
+        return newDecodeError(pos, "invalid advanced rule: %s", err)
+    }
+    return nil
+}
+
+// knownFields is used to collect the keys accepted by a struct in the given tag,
+// fields of embedded structs are included.
+func knownFields(t reflect.Type, tag string) map[string]bool {
+    fields := map[string]bool{}
+    for t.Kind() == reflect.Ptr {
+        t = t.Elem()
+    }
+    for i := 0; i < t.NumField(); i++ {
+        field := t.Field(i)
+        name := strings.Split(field.Tag.Get(tag), ",")[0]
+        if name == "-" {
+            continue
+        }
+        if field.Anonymous && name == "" {
+            for key := range knownFields(field.Type, tag) {
+                fields[key] = true
+            }
+            continue
+        }
+        if name == "" {
+            name = field.Name
+        }
+        fields[name] = true
+    }
+    return fields
+}
+
+// appendError is used to collect errors the way meta.Rules.IsValid does
+func appendError(errs error, err error) error {
+    if err == nil {
+        return errs
+    }
+    if merr, ok := err.(*multierror.Error); ok {
+        return multierror.Append(errs, merr.Errors...)
+    }
+    return multierror.Append(errs, err)
+}
@@ -0,0 +1,232 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loader
+
+import (
+    "testing"
+
+    "github.com/hashicorp/go-multierror"
+    "github.com/stretchr/testify/assert"
+)
+
+func getErrors(err error) []string {
+    var messages []string
+    if merr, ok := err.(*multierror.Error); ok {
+        for _, err := range merr.Errors {
+            messages = append(messages, err.Error())
+        }
+        return messages
+    }
+    if err != nil {
+        messages = append(messages, err.Error())
+    }
+    return messages
+}
+
+func TestStrictDecode(t *testing.T) {
+    tests := []struct {
+        name   string
+        format string
+        data   string
+        want   []string
+    }{
+        {
+            name:   "yaml valid",
+            format: FormatYAML,
+            data: `
+- id: 0
+  host: "*"
+  path: "**"
+  method: "*"
+  authorized_roles: ["*"]
+`,
+        },
+        {
+            name:   "yaml unknown field",
+            format: FormatYAML,
+            data: `
+- id: 0
+  host: "*"
+  path: "**"
+  method: "*"
+  authorised_roles: ["*"]
+- id: abc
+  host: "*"
+  path: "**"
+  method: "*"
+  allow-anyone: true
+`,
+            want: []string{
+                `rules.yaml:6:3: unknown field "authorised_roles"`,
+                `rules.yaml:7:7: cannot unmarshal !!str ` + "`abc`" + ` into int`,
+                `rules.yaml:11:3: unknown field "allow-anyone"`,
+            },
+        },
+        {
+            name:   "yaml invalid rule",
+            format: FormatYAML,
+            data: `
+rules:
+- id: 0
+  host: "*"
+  path: "**"
+  method: "*"
+advanced_rules:
+- id: 1
+  host: [domain.com]
+  path: []
+  method: [GET]
+  allow_anyone: true
+advanced_rule: []
+`,
+            want: []string{
+                `rules.yaml:3:3: invalid rule: permission: empty structure`,
+                `rules.yaml:8:3: invalid advanced rule: incomplete fields`,
+                `rules.yaml:13:1: unknown field "advanced_rule"`,
+            },
+        },
+        {
+            name:   "yaml merge key",
+            format: FormatYAML,
+            data: `
+defaults: &defaults
+  host: "*"
+  method: "*"
+  authorised_roles: ["*"]
+rules:
+- <<: *defaults
+  id: 0
+  path: "**"
+`,
+            want: []string{
+                `rules.yaml:2:1: unknown field "defaults"`,
+                `rules.yaml:5:3: unknown field "authorised_roles"`,
+            },
+        },
+        {
+            name:   "yaml syntax error",
+            format: FormatYAML,
+            data:   "- id: 0\n  host: [\n",
+            want: []string{
+                `rules.yaml:2: did not find expected node content`,
+            },
+        },
+        {
+            name:   "json unknown field",
+            format: FormatJSON,
+            data: `[
+    {"id": 0, "host": "*", "path": "**", "method": "*", "authorised_roles": ["*"]},
+    {"id": "1", "host": "*", "path": "**", "method": "*", "allow_anyone": true}
+]`,
+            want: []string{
+                `rules.json:2:57: unknown field "authorised_roles"`,
+                `rules.json:3:12: cannot unmarshal string into Go struct field Rule.id of type int`,
+            },
+        },
+        {
+            name:   "json invalid rule",
+            format: FormatJSON,
+            data: `{
+    "rules": [
+        {"id": 0, "host": "*", "path": "**", "method": "*"}
+    ],
+    "variables": {}
+}`,
+            want: []string{
+                `rules.json:3:9: invalid rule: permission: empty structure`,
+                `rules.json:5:5: unknown field "variables"`,
+            },
+        },
+        {
+            name:   "json syntax error",
+            format: FormatJSON,
+            data:   "[\n    {\"id\": 0,}\n]",
+            want: []string{
+                `rules.json:2:14: invalid character '}' looking for beginning of object key string`,
+            },
+        },
+        {
+            name:   "hcl unknown field",
+            format: FormatHCL,
+            data: `
+rule {
+    id               = 0
+    host             = "*"
+    path             = "**"
+    method           = "*"
+}
+
+rule {
+    id               = 1
+    host             = "*"
+    path             = "**"
+    method           = "*"
+    authorised_roles = ["*"]
+}
+`,
+            want: []string{
+                `rules.hcl:2:6: invalid rule: permission:  empty structure`,
+                `rules.hcl:14:5: unknown field "authorised_roles"`,
+            },
+        },
+        {
+            name:   "toml unknown field",
+            format: FormatTOML,
+            data: `
+[[rules]]
+id = 0
+host = "*"
+path = "**"
+method = "*"
+authorised_roles = ["*"]
+`,
+            want: []string{
+                `rules.toml: unknown field "rules.authorised_roles"`,
+                `rules.toml: rules[0]: invalid rule: permission: empty structure`,
+            },
+        },
+    }
+    for _, tt := range tests {
+        _, err := decode(tt.format, "rules."+tt.format, []byte(tt.data), newOptions([]Option{Strict()}))
+        assert.Equal(t, tt.want, getErrors(err), tt.name)
+    }
+}
+
+func TestStrictDecodeYAMLMerge(t *testing.T) {
+    data := `
+- &editor
+  id: 0
+  host: domain.com
+  path: /article/*
+  method: "*"
+  authorized_roles: [editor]
+- <<: *editor
+  id: 1
+  path: /draft/*
+- <<: [{method: GET, allow_anyone: true}, *editor]
+  id: 2
+  path: /public/*
+  authorized_roles: []
+`
+    rules, err := decode(FormatYAML, "rules.yaml", []byte(data), newOptions([]Option{Strict()}))
+    assert.Equal(t, nil, err)
+    assert.Equal(t, 3, len(rules))
+    assert.Equal(t, "domain.com", rules[1].Host)
+    assert.Equal(t, "/draft/*", rules[1].Path)
+    assert.Equal(t, []string{"editor"}, rules[1].AuthorizedRoles)
+    assert.Equal(t, "GET", rules[2].Method)
+    assert.Equal(t, true, rules[2].AllowAnyone)
+    assert.Equal(t, []string{}, rules[2].AuthorizedRoles)
+}
@@ -0,0 +1,114 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loader
+
+import (
+    "io/ioutil"
+    "regexp"
+    "strconv"
+
+    "github.com/BurntSushi/toml"
+    "github.com/storyicon/grbac/pkg/meta"
+)
+
+// TOMLLoader implements the Loader interface
+// it is used to load configuration from a local toml file.
+type TOMLLoader struct {
+    path    string
+    options *options
+}
+
+// NewTOMLLoader is used to initialize a TOMLLoader
+func NewTOMLLoader(file string, opts ...Option) (*TOMLLoader, error) {
+    loader := &TOMLLoader{
+        path:    file,
+        options: newOptions(opts),
+    }
+    _, err := loader.Load()
+    if err != nil {
+        return nil, err
+    }
+    return loader, nil
+}
+
+// Load is used to return a list of rules
+func (loader *TOMLLoader) Load() (meta.Rules, error) {
+    bytes, err := ioutil.ReadFile(loader.path)
+    if err != nil {
+        return nil, err
+    }
+    return decode(FormatTOML, loader.path, bytes, loader.options)
+}
+
+// unmarshalTOML is used to parse a toml rule file,
+// which is always a Document because a toml document can not be an array.
+//
+//  [[rules]]
+//  id = 0
+//  host = "*"
+//  path = "**"
+//  method = "*"
+//  authorized_roles = ["*"]
+//
+//  [[advanced_rules]]
+//  id = 1
+//  host = ["domain.com"]
+//  path = ["/article"]
+//  method = ["DELETE", "POST", "PUT"]
+//  authorized_roles = ["editor"]
+func unmarshalTOML(bytes []byte) (meta.Rules, error) {
+    doc := &Document{}
+    _, err := toml.Decode(string(bytes), doc)
+    if err != nil {
+        return nil, err
+    }
+    return doc.GetRules(), nil
+}
+
+// tomlLinePattern matches the line reported by the toml parser
+var tomlLinePattern = regexp.MustCompile(`^Near line (\d+) `)
+
+// unmarshalStrictTOML is the strict version of unmarshalTOML.
+// The toml decoder does not expose the positions of the keys,
+// so only the errors of the parser carry a line.
+func unmarshalStrictTOML(name string, bytes []byte) (meta.Rules, error) {
+    doc := &Document{}
+    md, err := toml.Decode(string(bytes), doc)
+    if err != nil {
+        pos := Position{File: name}
+        if match := tomlLinePattern.FindStringSubmatch(err.Error()); match != nil {
+            pos.Line, _ = strconv.Atoi(match[1])
+        }
+        return nil, &DecodeError{Position: pos, Err: err}
+    }
+    var errs error
+    for _, key := range md.Undecoded() {
+        errs = appendError(errs, newDecodeError(Position{File: name}, "%s %q", ErrUnknownField, key.String()))
+    }
+    for i, rule := range doc.Rules {
+        if err := rule.IsValid(); err != nil {
+            errs = appendError(errs, newDecodeError(Position{File: name}, "rules[%d]: invalid rule: %s", i, err))
+        }
+    }
+    for i, rule := range doc.AdvancedRules {
+        if err := rule.IsValid(); err != nil {
+            errs = appendError(errs, newDecodeError(Position{File: name}, "advanced_rules[%d]: invalid advanced rule: %s", i, err))
+        }
+    }
+    if errs != nil {
+        return nil, errs
+    }
+    return doc.GetRules(), nil
+}
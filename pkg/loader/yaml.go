@@ -15,7 +15,12 @@
 package loader
 
 import (
+    "errors"
     "io/ioutil"
+    "reflect"
+    "regexp"
+    "strconv"
+    "strings"
 
     "github.com/storyicon/grbac/pkg/meta"
     "gopkg.in/yaml.v3"
@@ -24,13 +29,15 @@ import (
 // YAMLLoader implements the Loader interface
 // it is used to load configuration from a local yaml file.
 type YAMLLoader struct {
-    path string
+    path    string
+    options *options
 }
 
 // NewYAMLLoader is used to initialize a YAMLLoader
-func NewYAMLLoader(file string) (*YAMLLoader, error) {
+func NewYAMLLoader(file string, opts ...Option) (*YAMLLoader, error) {
     loader := &YAMLLoader{
-        path: file,
+        path:    file,
+        options: newOptions(opts),
     }
     _, err := loader.Load()
     if err != nil {
@@ -45,10 +52,268 @@ func (loader *YAMLLoader) Load() (meta.Rules, error) {
     if err != nil {
         return nil, err
     }
-    rules := meta.Rules{}
-    err = yaml.Unmarshal(bytes, &rules)
+    return decode(FormatYAML, loader.path, bytes, loader.options)
+}
+
+// unmarshalYAML is used to parse a yaml rule file,
+// which is either a list of rules and advanced rules or a Document.
+func unmarshalYAML(bytes []byte) (meta.Rules, error) {
+    root := &yaml.Node{}
+    err := yaml.Unmarshal(bytes, root)
     if err != nil {
         return nil, err
     }
-    return rules, nil
+    return unmarshalYAMLNode(root)
+}
+
+// unmarshalYAMLNode is used to convert a parsed yaml rule file into rules
+func unmarshalYAMLNode(root *yaml.Node) (meta.Rules, error) {
+    if len(root.Content) == 0 {
+        return meta.Rules{}, nil
+    }
+    node := root.Content[0]
+    if node.Kind == yaml.MappingNode {
+        doc := &Document{}
+        err := node.Decode(doc)
+        if err != nil {
+            return nil, err
+        }
+        return doc.GetRules(), nil
+    }
+    if node.Kind != yaml.SequenceNode {
+        rules := meta.Rules{}
+        err := node.Decode(&rules)
+        return rules, err
+    }
+    doc := &Document{}
+    for _, item := range node.Content {
+        if isAdvancedYAML(item) {
+            rule := &AdvancedRule{}
+            err := item.Decode(rule)
+            if err != nil {
+                return nil, err
+            }
+            doc.AdvancedRules = append(doc.AdvancedRules, rule)
+            continue
+        }
+        rule := &meta.Rule{}
+        err := item.Decode(rule)
+        if err != nil {
+            return nil, err
+        }
+        doc.Rules = append(doc.Rules, rule)
+    }
+    return doc.GetRules(), nil
+}
+
+// isAdvancedYAML is used to determine whether the item is written in the AdvancedRule schema,
+// whose host, path or method are sequences.
+func isAdvancedYAML(item *yaml.Node) bool {
+    if item.Kind != yaml.MappingNode {
+        return false
+    }
+    for _, pair := range yamlPairs(item) {
+        switch pair[0].Value {
+        case "host", "path", "method":
+            if resolveYAML(pair[1]).Kind == yaml.SequenceNode {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// isMergeKey is used to determine whether the key is the merge key <<
+func isMergeKey(key *yaml.Node) bool {
+    return key.Kind == yaml.ScalarNode && key.Value == "<<" && (key.Tag == "!!merge" || key.Tag == "")
+}
+
+// yamlPairs is used to get the key value pairs of a mapping node.
+// The pairs of the mappings merged by << are inlined, the explicit keys take precedence over them
+// and the earlier merged mappings take precedence over the later ones, like yaml does when decoding.
+func yamlPairs(node *yaml.Node) [][2]*yaml.Node {
+    var explicit, merged [][2]*yaml.Node
+    seen := map[string]bool{}
+    for i := 0; i+1 < len(node.Content); i += 2 {
+        key, value := node.Content[i], node.Content[i+1]
+        if !isMergeKey(key) {
+            explicit = append(explicit, [2]*yaml.Node{key, value})
+            seen[key.Value] = true
+        }
+    }
+    for i := 0; i+1 < len(node.Content); i += 2 {
+        if !isMergeKey(node.Content[i]) {
+            continue
+        }
+        sources := []*yaml.Node{resolveYAML(node.Content[i+1])}
+        if sources[0].Kind == yaml.SequenceNode {
+            sources = sources[0].Content
+        }
+        for _, source := range sources {
+            source = resolveYAML(source)
+            if source.Kind != yaml.MappingNode {
+                continue
+            }
+            for _, pair := range yamlPairs(source) {
+                if !seen[pair[0].Value] {
+                    seen[pair[0].Value] = true
+                    merged = append(merged, pair)
+                }
+            }
+        }
+    }
+    return append(merged, explicit...)
+}
+
+// yamlLinePattern matches the line prefix of the errors reported by yaml
+var yamlLinePattern = regexp.MustCompile(`^(?:yaml: )?line (\d+): `)
+
+// unmarshalStrictYAML is the strict version of unmarshalYAML,
+// the positions of the errors are taken from the yaml nodes.
+func unmarshalStrictYAML(name string, bytes []byte) (meta.Rules, error) {
+    root := &yaml.Node{}
+    err := yaml.Unmarshal(bytes, root)
+    if err != nil {
+        return nil, yamlError(Position{File: name}, err)
+    }
+    return unmarshalStrictYAMLNode(name, root)
+}
+
+// unmarshalStrictYAMLNode is the strict version of unmarshalYAMLNode
+func unmarshalStrictYAMLNode(name string, root *yaml.Node) (meta.Rules, error) {
+    if len(root.Content) == 0 {
+        return meta.Rules{}, nil
+    }
+    node := resolveYAML(root.Content[0])
+    doc := &Document{}
+    var errs error
+    switch node.Kind {
+    case yaml.MappingNode:
+        known := knownFields(reflect.TypeOf(doc), "yaml")
+        for _, pair := range yamlPairs(node) {
+            key, value := pair[0], resolveYAML(pair[1])
+            if !known[key.Value] {
+                errs = appendError(errs, newDecodeError(yamlPosition(name, key), "%s %q", ErrUnknownField, key.Value))
+                continue
+            }
+            if key.Value == "vars" {
+                continue
+            }
+            if value.Kind != yaml.SequenceNode {
+                if value.Tag != "!!null" {
+                    errs = appendError(errs, newDecodeError(yamlPosition(name, value), "%s", ErrExpectedList))
+                }
+                continue
+            }
+            for _, item := range value.Content {
+                errs = appendError(errs, decodeStrictYAMLItem(name, resolveYAML(item), doc, key.Value == "advanced_rules"))
+            }
+        }
+    case yaml.SequenceNode:
+        for _, item := range node.Content {
+            item = resolveYAML(item)
+            errs = appendError(errs, decodeStrictYAMLItem(name, item, doc, isAdvancedYAML(item)))
+        }
+    default:
+        if node.Tag != "!!null" {
+            errs = newDecodeError(yamlPosition(name, node), "%s", ErrUnexpectedSchema)
+        }
+    }
+    if errs != nil {
+        return nil, errs
+    }
+    return doc.GetRules(), nil
+}
+
+// decodeStrictYAMLItem is used to decode and validate a rule or an advanced rule
+func decodeStrictYAMLItem(name string, item *yaml.Node, doc *Document, advanced bool) error {
+    pos := yamlPosition(name, item)
+    if advanced {
+        rule := &AdvancedRule{}
+        err := decodeStrictYAML(name, item, rule)
+        if err != nil {
+            return err
+        }
+        doc.AdvancedRules = append(doc.AdvancedRules, rule)
+        return validateAdvancedRule(pos, rule)
+    }
+    rule := &meta.Rule{}
+    err := decodeStrictYAML(name, item, rule)
+    if err != nil {
+        return err
+    }
+    doc.Rules = append(doc.Rules, rule)
+    return validateRule(pos, rule)
+}
+
+// decodeStrictYAML is used to decode a mapping node into v.
+// Every unknown field and every field that fails to decode is reported with its position.
+func decodeStrictYAML(name string, node *yaml.Node, v interface{}) error {
+    if node.Kind != yaml.MappingNode {
+        return newDecodeError(yamlPosition(name, node), "%s", ErrExpectedObject)
+    }
+    t := reflect.TypeOf(v).Elem()
+    known := knownFields(t, "yaml")
+    var errs error
+    for _, pair := range yamlPairs(node) {
+        key, value := pair[0], pair[1]
+        if !known[key.Value] {
+            errs = appendError(errs, newDecodeError(yamlPosition(name, key), "%s %q", ErrUnknownField, key.Value))
+            continue
+        }
+        pair := &yaml.Node{
+            Kind:    yaml.MappingNode,
+            Tag:     "!!map",
+            Content: []*yaml.Node{key, value},
+        }
+        err := pair.Decode(reflect.New(t).Interface())
+        if err != nil {
+            errs = appendError(errs, yamlError(yamlPosition(name, value), err))
+        }
+    }
+    if errs != nil {
+        return errs
+    }
+    return node.Decode(v)
+}
+
+// yamlError is used to convert the errors of yaml into DecodeErrors,
+// the line reported by yaml takes precedence over the given position.
+func yamlError(pos Position, err error) error {
+    messages := []string{err.Error()}
+    if typeErr, ok := err.(*yaml.TypeError); ok {
+        messages = typeErr.Errors
+    }
+    var errs error
+    for _, message := range messages {
+        p := pos
+        if match := yamlLinePattern.FindStringSubmatch(message); match != nil {
+            message = message[len(match[0]):]
+            line, _ := strconv.Atoi(match[1])
+            if line != p.Line {
+                p.Line, p.Column = line, 0
+            }
+        }
+        errs = appendError(errs, &DecodeError{
+            Position: p,
+            Err:      errors.New(strings.TrimPrefix(message, "yaml: ")),
+        })
+    }
+    return errs
+}
+
+func yamlPosition(name string, node *yaml.Node) Position {
+    return Position{
+        File:   name,
+        Line:   node.Line,
+        Column: node.Column,
+    }
+}
+
+// resolveYAML is used to follow aliases to the anchored node
+func resolveYAML(node *yaml.Node) *yaml.Node {
+    for node.Kind == yaml.AliasNode && node.Alias != nil {
+        node = node.Alias
+    }
+    return node
 }
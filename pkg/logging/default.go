@@ -0,0 +1,29 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !go1.21
+// +build !go1.21
+
+package logging
+
+import (
+    "log"
+    "os"
+)
+
+// Default is used to create the default logger of grbac,
+// which writes the entries above LevelInfo to stderr, see NewSlog for Go 1.21+.
+func Default() Logger {
+    return NewStd(log.New(os.Stderr, "", log.LstdFlags), LevelInfo)
+}
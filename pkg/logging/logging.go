@@ -0,0 +1,120 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package logging defines the minimal structured logger used by grbac,
+// and the adapters of log, log/slog and logrus.
+package logging
+
+import (
+    "fmt"
+    "log"
+    "strings"
+)
+
+// Field defines a structured field of a log entry
+type Field struct {
+    Key   string
+    Value interface{}
+}
+
+// Any is used to create a field
+func Any(key string, value interface{}) Field {
+    return Field{Key: key, Value: value}
+}
+
+// Err is used to create the error field
+func Err(err error) Field {
+    return Field{Key: "error", Value: err}
+}
+
+// Logger defines the minimal structured logger
+type Logger interface {
+    Debug(msg string, fields ...Field)
+    Info(msg string, fields ...Field)
+    Warn(msg string, fields ...Field)
+    Error(msg string, fields ...Field)
+}
+
+// Level defines the level of a log entry
+type Level int
+
+// define the levels of the log entries
+const (
+    LevelDebug Level = iota
+    LevelInfo
+    LevelWarn
+    LevelError
+)
+
+// String is used to get the name of the level
+func (l Level) String() string {
+    switch l {
+    case LevelDebug:
+        return "debug"
+    case LevelInfo:
+        return "info"
+    case LevelWarn:
+        return "warn"
+    }
+    return "error"
+}
+
+// nop discards the log entries
+type nop struct{}
+
+func (nop) Debug(string, ...Field) {}
+func (nop) Info(string, ...Field)  {}
+func (nop) Warn(string, ...Field)  {}
+func (nop) Error(string, ...Field) {}
+
+// Nop is the logger discarding all the log entries
+var Nop Logger = nop{}
+
+// std writes the log entries to a standard logger in the logfmt style
+type std struct {
+    logger *log.Logger
+    level  Level
+}
+
+// NewStd is used to create a logger writing the entries above the level to a standard logger:
+//  level=error msg="grbac failed to reload the rules" error="open rules.yaml: no such file or directory"
+func NewStd(logger *log.Logger, level Level) Logger {
+    return &std{logger: logger, level: level}
+}
+
+// write is used to write a log entry
+func (s *std) write(level Level, msg string, fields []Field) {
+    if level < s.level {
+        return
+    }
+    var b strings.Builder
+    fmt.Fprintf(&b, "level=%s msg=%s", level, quote(msg))
+    for _, field := range fields {
+        fmt.Fprintf(&b, " %s=%s", field.Key, quote(fmt.Sprint(field.Value)))
+    }
+    s.logger.Output(3, b.String())
+}
+
+// quote is used to quote a value containing spaces, quotes or equal signs
+func quote(s string) string {
+    if s == "" || strings.ContainsAny(s, " \"=\t\n") {
+        return fmt.Sprintf("%q", s)
+    }
+    return s
+}
+
+func (s *std) Debug(msg string, fields ...Field) { s.write(LevelDebug, msg, fields) }
+func (s *std) Info(msg string, fields ...Field)  { s.write(LevelInfo, msg, fields) }
+func (s *std) Warn(msg string, fields ...Field)  { s.write(LevelWarn, msg, fields) }
+func (s *std) Error(msg string, fields ...Field) { s.write(LevelError, msg, fields) }
@@ -0,0 +1,52 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+    "bytes"
+    "errors"
+    "log"
+    "testing"
+
+    "github.com/sirupsen/logrus"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestNewStd(t *testing.T) {
+    buffer := &bytes.Buffer{}
+    logger := NewStd(log.New(buffer, "", 0), LevelInfo)
+    logger.Debug("hidden")
+    logger.Info("grbac loaded the rules", Any("rules", 2), Any("version", ""))
+    logger.Error("grbac failed to load the rules", Err(errors.New("open rules.yaml: no such file")))
+    assert.Equal(t, `level=info msg="grbac loaded the rules" rules=2 version=""
+level=error msg="grbac failed to load the rules" error="open rules.yaml: no such file"
+`, buffer.String())
+
+    Nop.Error("discarded", Any("key", "value"))
+}
+
+func TestNewLogrus(t *testing.T) {
+    buffer := &bytes.Buffer{}
+    l := logrus.New()
+    l.Out = buffer
+    l.Formatter = &logrus.TextFormatter{DisableTimestamp: true, DisableColors: true}
+    logger := NewLogrus(l)
+    logger.Debug("hidden")
+    logger.Warn("grbac abandoned the periodic loader", Any("interval", -1))
+    logger.Info("plain")
+    assert.Equal(t, `level=warning msg="grbac abandoned the periodic loader" interval=-1
+level=info msg=plain
+`, buffer.String())
+}
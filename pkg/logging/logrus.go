@@ -0,0 +1,46 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+    "github.com/sirupsen/logrus"
+)
+
+// logrusLogger adapts a logrus logger
+type logrusLogger struct {
+    logger logrus.FieldLogger
+}
+
+// NewLogrus is used to adapt a logrus logger or entry
+func NewLogrus(logger logrus.FieldLogger) Logger {
+    return &logrusLogger{logger: logger}
+}
+
+// entry is used to attach the fields to an entry
+func (l *logrusLogger) entry(fields []Field) logrus.FieldLogger {
+    if len(fields) == 0 {
+        return l.logger
+    }
+    data := make(logrus.Fields, len(fields))
+    for _, field := range fields {
+        data[field.Key] = field.Value
+    }
+    return l.logger.WithFields(data)
+}
+
+func (l *logrusLogger) Debug(msg string, fields ...Field) { l.entry(fields).Debug(msg) }
+func (l *logrusLogger) Info(msg string, fields ...Field)  { l.entry(fields).Info(msg) }
+func (l *logrusLogger) Warn(msg string, fields ...Field)  { l.entry(fields).Warn(msg) }
+func (l *logrusLogger) Error(msg string, fields ...Field) { l.entry(fields).Error(msg) }
@@ -0,0 +1,60 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.21
+// +build go1.21
+
+package logging
+
+import (
+    "context"
+    "log/slog"
+)
+
+// slogLogger adapts a slog logger
+type slogLogger struct {
+    logger *slog.Logger
+}
+
+// Default is used to create the default logger of grbac, which adapts slog.Default(),
+// see NewStd before Go 1.21.
+func Default() Logger {
+    return NewSlog(nil)
+}
+
+// NewSlog is used to adapt a slog logger, slog.Default() is used when the logger is nil
+func NewSlog(logger *slog.Logger) Logger {
+    if logger == nil {
+        logger = slog.Default()
+    }
+    return &slogLogger{logger: logger}
+}
+
+// log is used to write a log entry with the fields as attributes
+func (l *slogLogger) log(level slog.Level, msg string, fields []Field) {
+    ctx := context.Background()
+    if !l.logger.Enabled(ctx, level) {
+        return
+    }
+    attrs := make([]slog.Attr, 0, len(fields))
+    for _, field := range fields {
+        attrs = append(attrs, slog.Any(field.Key, field.Value))
+    }
+    l.logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+func (l *slogLogger) Debug(msg string, fields ...Field) { l.log(slog.LevelDebug, msg, fields) }
+func (l *slogLogger) Info(msg string, fields ...Field)  { l.log(slog.LevelInfo, msg, fields) }
+func (l *slogLogger) Warn(msg string, fields ...Field)  { l.log(slog.LevelWarn, msg, fields) }
+func (l *slogLogger) Error(msg string, fields ...Field) { l.log(slog.LevelError, msg, fields) }
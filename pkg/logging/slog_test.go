@@ -0,0 +1,50 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.21
+// +build go1.21
+
+package logging
+
+import (
+    "bytes"
+    "errors"
+    "log/slog"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestNewSlog(t *testing.T) {
+    buffer := &bytes.Buffer{}
+    handler := slog.NewTextHandler(buffer, &slog.HandlerOptions{
+        ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+            if attr.Key == slog.TimeKey {
+                return slog.Attr{}
+            }
+            return attr
+        },
+    })
+    logger := NewSlog(slog.New(handler))
+    logger.Debug("hidden")
+    logger.Error("grbac failed to load the rules", Err(errors.New("invalid rule")), Any("rule_id", 3))
+    assert.Equal(t, `level=ERROR msg="grbac failed to load the rules" error="invalid rule" rule_id=3
+`, buffer.String())
+}
+
+func TestDefault(t *testing.T) {
+    logger, ok := Default().(*slogLogger)
+    assert.True(t, ok)
+    assert.Equal(t, slog.Default(), logger.logger)
+}
@@ -0,0 +1,178 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package matrix renders the effective access of roles to endpoints as a table.
+//
+// Every rule contributes its resource as endpoints, one for each alternative of its braces.
+// The access of an endpoint is computed like the controller does:
+// the rule with the highest ID among the rules matching a sample request of the endpoint decides,
+// so a wildcard rule overridden by a more specific one is reported as it is enforced.
+package matrix
+
+import (
+    "sort"
+    "strconv"
+
+    "github.com/storyicon/grbac/pkg/meta"
+    "github.com/storyicon/grbac/pkg/probe"
+)
+
+// Anonymous is the column of the requests without any role
+const Anonymous = "(anonymous)"
+
+// Endpoint defines a row of the matrix
+type Endpoint struct {
+    Host   string
+    Path   string
+    Method string
+    // RuleID is the ID of the rule deciding the access, -1 when no rule matches
+    RuleID int
+    // Access are the access of the roles, in the order of Matrix.Roles
+    Access []meta.PermissionState
+}
+
+// Matrix defines the access of roles to endpoints
+type Matrix struct {
+    // Roles are the columns of the matrix, Anonymous comes first
+    Roles     []string
+    Endpoints []*Endpoint
+}
+
+// Roles is used to derive the roles named by the rules, the any role(*) is excluded
+func Roles(rules meta.Rules) []string {
+    set := map[string]bool{}
+    for _, rule := range rules {
+        if rule.Permission == nil {
+            continue
+        }
+        for _, role := range append(append([]string(nil), rule.AuthorizedRoles...), rule.ForbiddenRoles...) {
+            if role != "*" {
+                set[role] = true
+            }
+        }
+    }
+    roles := make([]string, 0, len(set))
+    for role := range set {
+        roles = append(roles, role)
+    }
+    sort.Strings(roles)
+    return roles
+}
+
+// New is used to compute the matrix of the rules,
+// the roles are derived from the rules when they are empty.
+func New(rules meta.Rules, roles []string) (*Matrix, error) {
+    if len(roles) == 0 {
+        roles = Roles(rules)
+    }
+    m := &Matrix{
+        Roles: append([]string{Anonymous}, roles...),
+    }
+    seen := map[meta.Resource]bool{}
+    for _, rule := range rules {
+        if rule.Resource == nil {
+            continue
+        }
+        for _, host := range probe.Expand(rule.Host) {
+            for _, path := range probe.Expand(rule.Path) {
+                for _, method := range probe.Expand(rule.Method) {
+                    resource := meta.Resource{Host: host, Path: path, Method: method}
+                    if seen[resource] {
+                        continue
+                    }
+                    seen[resource] = true
+                    endpoint, err := m.evaluate(rules, &resource)
+                    if err != nil {
+                        return nil, err
+                    }
+                    m.Endpoints = append(m.Endpoints, endpoint)
+                }
+            }
+        }
+    }
+    sort.SliceStable(m.Endpoints, func(i, j int) bool {
+        a, b := m.Endpoints[i], m.Endpoints[j]
+        if a.Host != b.Host {
+            return a.Host < b.Host
+        }
+        if a.Path != b.Path {
+            return a.Path < b.Path
+        }
+        return a.Method < b.Method
+    })
+    return m, nil
+}
+
+// evaluate is used to compute the access of the roles to a resource
+func (m *Matrix) evaluate(rules meta.Rules, resource *meta.Resource) (*Endpoint, error) {
+    endpoint := &Endpoint{
+        Host:   resource.Host,
+        Path:   resource.Path,
+        Method: resource.Method,
+        RuleID: -1,
+    }
+    query, err := probe.Query(resource)
+    if err != nil {
+        return nil, err
+    }
+    var matched meta.Rules
+    for _, rule := range rules {
+        if rule.Resource == nil || rule.Permission == nil {
+            continue
+        }
+        ok, err := rule.Resource.Match(query)
+        if err != nil {
+            return nil, err
+        }
+        if ok {
+            matched = append(matched, rule)
+        }
+    }
+    for _, rule := range matched {
+        if endpoint.RuleID <= rule.ID {
+            endpoint.RuleID = rule.ID
+        }
+    }
+    for _, role := range m.Roles {
+        var roles []string
+        if role != Anonymous {
+            roles = []string{role}
+        }
+        state, err := matched.IsRolesGranted(roles)
+        if err != nil {
+            return nil, err
+        }
+        endpoint.Access = append(endpoint.Access, state)
+    }
+    return endpoint, nil
+}
+
+// cell is used to render the access of a role
+func cell(state meta.PermissionState) string {
+    switch state {
+    case meta.PermissionGranted:
+        return "allow"
+    case meta.PermissionUngranted:
+        return "deny"
+    }
+    return "none"
+}
+
+// ruleID is used to render the rule deciding the access
+func ruleID(endpoint *Endpoint) string {
+    if endpoint.RuleID < 0 {
+        return "-"
+    }
+    return strconv.Itoa(endpoint.RuleID)
+}
@@ -0,0 +1,95 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package matrix
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+
+    "github.com/storyicon/grbac/pkg/meta"
+    "github.com/stretchr/testify/assert"
+)
+
+var rules = meta.Rules{
+    {
+        ID:         0,
+        Resource:   &meta.Resource{Host: "*", Path: "**", Method: "*"},
+        Permission: &meta.Permission{AuthorizedRoles: []string{"*"}, ForbiddenRoles: []string{"black_user"}},
+    },
+    {
+        ID:         1,
+        Resource:   &meta.Resource{Host: "*", Path: "/articles/*", Method: "{DELETE,PUT}"},
+        Permission: &meta.Permission{AuthorizedRoles: []string{"editor"}},
+    },
+    {
+        ID:         2,
+        Resource:   &meta.Resource{Host: "*", Path: "/login", Method: "POST"},
+        Permission: &meta.Permission{AllowAnyone: true},
+    },
+}
+
+func TestNew(t *testing.T) {
+    assert.Equal(t, []string{"black_user", "editor"}, Roles(rules))
+
+    m, err := New(rules, []string{"editor", "reader"})
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []string{Anonymous, "editor", "reader"}, m.Roles)
+
+    buffer := &bytes.Buffer{}
+    assert.Equal(t, nil, m.CSV(buffer))
+    assert.Equal(t, `host,path,method,rule,(anonymous),editor,reader
+*,**,*,0,deny,allow,allow
+*,/articles/*,DELETE,1,deny,allow,deny
+*,/articles/*,PUT,1,deny,allow,deny
+*,/login,POST,2,allow,allow,allow
+`, buffer.String())
+
+    buffer.Reset()
+    assert.Equal(t, nil, m.Markdown(buffer))
+    assert.Equal(t, "| host | path | method | rule | (anonymous) | editor | reader |\n"+
+        "| --- | --- | --- | --- | --- | --- | --- |\n"+
+        "| `*` | `**` | `*` | 0 | deny | allow | allow |\n"+
+        "| `*` | `/articles/*` | `DELETE` | 1 | deny | allow | deny |\n"+
+        "| `*` | `/articles/*` | `PUT` | 1 | deny | allow | deny |\n"+
+        "| `*` | `/login` | `POST` | 2 | allow | allow | allow |\n", buffer.String())
+
+    buffer.Reset()
+    assert.Equal(t, nil, m.Render(buffer, FormatHTML))
+    assert.True(t, strings.Contains(buffer.String(), `<td class="pattern">/articles/*</td><td class="pattern">PUT</td><td>1</td><td class="deny">deny</td>`))
+    assert.Equal(t, ErrUnsupportedFormat, m.Render(buffer, "pdf"))
+}
+
+func TestNewShadowed(t *testing.T) {
+    m, err := New(meta.Rules{
+        {
+            ID:         0,
+            Resource:   &meta.Resource{Host: "domain.com", Path: "/articles", Method: "GET"},
+            Permission: &meta.Permission{AuthorizedRoles: []string{"reader"}},
+        },
+        {
+            ID:         1,
+            Resource:   &meta.Resource{Host: "*", Path: "/articles", Method: "GET"},
+            Permission: &meta.Permission{AuthorizedRoles: []string{"editor"}},
+        },
+    }, nil)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, 2, len(m.Endpoints))
+    assert.Equal(t, "*", m.Endpoints[0].Host)
+    assert.Equal(t, []meta.PermissionState{meta.PermissionUngranted, meta.PermissionGranted, meta.PermissionUngranted}, m.Endpoints[0].Access)
+    // the rule of domain.com is shadowed by the rule with the higher ID
+    assert.Equal(t, 1, m.Endpoints[1].RuleID)
+    assert.Equal(t, []meta.PermissionState{meta.PermissionUngranted, meta.PermissionGranted, meta.PermissionUngranted}, m.Endpoints[1].Access)
+}
@@ -0,0 +1,139 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package matrix
+
+import (
+    "encoding/csv"
+    "errors"
+    "fmt"
+    "html/template"
+    "io"
+    "strings"
+)
+
+// define the supported formats of the matrix
+const (
+    FormatCSV      = "csv"
+    FormatMarkdown = "markdown"
+    FormatHTML     = "html"
+)
+
+// ErrUnsupportedFormat is returned when the format of the matrix is unknown
+var ErrUnsupportedFormat = errors.New("unsupported matrix format")
+
+// Render is used to write the matrix in the given format
+func (m *Matrix) Render(w io.Writer, format string) error {
+    switch format {
+    case FormatCSV:
+        return m.CSV(w)
+    case FormatMarkdown:
+        return m.Markdown(w)
+    case FormatHTML:
+        return m.HTML(w)
+    }
+    return ErrUnsupportedFormat
+}
+
+// header is used to return the titles of the columns
+func (m *Matrix) header() []string {
+    return append([]string{"host", "path", "method", "rule"}, m.Roles...)
+}
+
+// row is used to return the cells of an endpoint
+func row(endpoint *Endpoint) []string {
+    cells := []string{endpoint.Host, endpoint.Path, endpoint.Method, ruleID(endpoint)}
+    for _, state := range endpoint.Access {
+        cells = append(cells, cell(state))
+    }
+    return cells
+}
+
+// CSV is used to write the matrix as csv
+func (m *Matrix) CSV(w io.Writer) error {
+    writer := csv.NewWriter(w)
+    if err := writer.Write(m.header()); err != nil {
+        return err
+    }
+    for _, endpoint := range m.Endpoints {
+        if err := writer.Write(row(endpoint)); err != nil {
+            return err
+        }
+    }
+    writer.Flush()
+    return writer.Error()
+}
+
+// Markdown is used to write the matrix as a markdown table
+func (m *Matrix) Markdown(w io.Writer) error {
+    header := m.header()
+    separators := make([]string, len(header))
+    for i := range separators {
+        separators[i] = "---"
+    }
+    lines := []string{
+        "| " + strings.Join(header, " | ") + " |",
+        "| " + strings.Join(separators, " | ") + " |",
+    }
+    for _, endpoint := range m.Endpoints {
+        cells := row(endpoint)
+        for i := 0; i < 3; i++ {
+            cells[i] = "`" + cells[i] + "`"
+        }
+        for i := range cells {
+            cells[i] = strings.Replace(cells[i], "|", `\|`, -1)
+        }
+        lines = append(lines, "| "+strings.Join(cells, " | ")+" |")
+    }
+    _, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+    return err
+}
+
+// htmlTemplate renders a self-contained page without external resources
+var htmlTemplate = template.Must(template.New("matrix").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>grbac access matrix</title>
+<style>
+body { font-family: sans-serif; margin: 24px; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+th { background: #f4f4f4; }
+td.pattern { font-family: monospace; }
+td.allow { background: #d9f2d9; }
+td.deny { background: #f8d7d7; }
+td.none { background: #eeeeee; color: #888; }
+</style>
+</head>
+<body>
+<table>
+<tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range $i, $cell := .}}{{if lt $i 3}}<td class="pattern">{{$cell}}</td>{{else if eq $i 3}}<td>{{$cell}}</td>{{else}}<td class="{{$cell}}">{{$cell}}</td>{{end}}{{end}}</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// HTML is used to write the matrix as a self-contained html page
+func (m *Matrix) HTML(w io.Writer) error {
+    rows := make([][]string, 0, len(m.Endpoints))
+    for _, endpoint := range m.Endpoints {
+        rows = append(rows, row(endpoint))
+    }
+    return htmlTemplate.Execute(w, map[string]interface{}{
+        "Header": m.header(),
+        "Rows":   rows,
+    })
+}
@@ -15,17 +15,19 @@
 package meta
 
 import (
+    "strings"
+
     "github.com/storyicon/grbac/pkg/path"
 )
 
 // Resource defines resources
 type Resource struct {
     // Host defines the host of the resource, allowing wildcards to be used.
-    Host string `json:"host" yaml:"host"`
+    Host string `json:"host" yaml:"host" toml:"host"`
     // Path defines the path of the resource, allowing wildcards to be used.
-    Path string `json:"path" yaml:"path"`
+    Path string `json:"path" yaml:"path" toml:"path"`
     // Method defines the method of the resource, allowing wildcards to be used.
-    Method string `json:"method" yaml:"method"`
+    Method string `json:"method" yaml:"method" toml:"method"`
 }
 
 // Match is used to calculate whether the query matches the resource
@@ -59,3 +61,16 @@ func (r *Resource) IsValid() error {
     }
     return nil
 }
+
+// Wildcards is used to measure how general the resource is,
+// by the wildcards of its path and whether its host and method match anything
+func (r *Resource) Wildcards() int {
+    n := strings.Count(r.Path, "*")
+    if r.Host == "*" {
+        n++
+    }
+    if r.Method == "*" {
+        n++
+    }
+    return n
+}
@@ -175,3 +175,20 @@ func TestResource_IsValid(t *testing.T) {
         }
     }
 }
+
+func TestResource_Wildcards(t *testing.T) {
+    tests := []struct {
+        name     string
+        resource *Resource
+        want     int
+    }{
+        {name: "test0", resource: &Resource{Host: "domain.com", Path: "/article", Method: "GET"}, want: 0},
+        {name: "test1", resource: &Resource{Host: "*", Path: "/article/*", Method: "GET"}, want: 2},
+        {name: "test2", resource: &Resource{Host: "*", Path: "**", Method: "*"}, want: 4},
+    }
+    for _, tt := range tests {
+        if got := tt.resource.Wildcards(); got != tt.want {
+            t.Errorf("%q. Resource.Wildcards() = %v, want %v", tt.name, got, tt.want)
+        }
+    }
+}
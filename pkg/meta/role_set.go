@@ -0,0 +1,81 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meta
+
+// RoleSet is the preprocessed roles of a visitor,
+// which is used to evaluate many permissions without scanning the roles for each of them.
+type RoleSet struct {
+    size int
+    // index is the position of the first occurrence of a role
+    index map[string]int
+}
+
+// NewRoleSet is used to preprocess the roles of a visitor
+func NewRoleSet(roles []string) *RoleSet {
+    set := &RoleSet{
+        size:  len(roles),
+        index: make(map[string]int, len(roles)),
+    }
+    for i, role := range roles {
+        if _, ok := set.index[role]; !ok {
+            set.index[role] = i
+        }
+    }
+    return set
+}
+
+// first is used to find the position of the first role matching the roles of a permission, -1 when none
+func (set *RoleSet) first(roles []string) int {
+    first := -1
+    for _, role := range roles {
+        if role == "*" {
+            return 0
+        }
+        if i, ok := set.index[role]; ok && (first < 0 || i < first) {
+            first = i
+        }
+    }
+    return first
+}
+
+// IsGranted is used to determine whether the roles can pass the authentication of the permission,
+// the result is the same as Permission.IsGranted, in which the roles are checked in order
+// and the forbidden roles are checked before the authorized ones.
+func (set *RoleSet) IsGranted(p *Permission) PermissionState {
+    if p.AllowAnyone {
+        return PermissionGranted
+    }
+    if set.size == 0 {
+        return PermissionUngranted
+    }
+    authorized := set.first(p.AuthorizedRoles)
+    if authorized < 0 {
+        return PermissionUngranted
+    }
+    forbidden := set.first(p.ForbiddenRoles)
+    if forbidden >= 0 && forbidden <= authorized {
+        return PermissionUngranted
+    }
+    return PermissionGranted
+}
+
+// IsRoleSetGranted is like IsRolesGranted, with the preprocessed roles
+func (rules Rules) IsRoleSetGranted(set *RoleSet) PermissionState {
+    tail := rules.GetDecisiveRule()
+    if tail == nil {
+        return PermissionNeglected
+    }
+    return set.IsGranted(tail.Permission)
+}
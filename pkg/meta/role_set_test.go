@@ -0,0 +1,67 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meta
+
+import (
+    "math/rand"
+    "testing"
+)
+
+func TestRoleSet_IsGranted(t *testing.T) {
+    candidates := []string{"*", "editor", "intern", "admin"}
+    pick := func(r *rand.Rand, n int) []string {
+        var roles []string
+        for i := r.Intn(n + 1); i > 0; i-- {
+            roles = append(roles, candidates[r.Intn(len(candidates))])
+        }
+        return roles
+    }
+    r := rand.New(rand.NewSource(1))
+    for i := 0; i < 10000; i++ {
+        p := &Permission{
+            AuthorizedRoles: pick(r, 3),
+            ForbiddenRoles:  pick(r, 2),
+            AllowAnyone:     r.Intn(10) == 0,
+        }
+        roles := pick(r, 4)
+        want, _ := p.IsGranted(roles)
+        if got := NewRoleSet(roles).IsGranted(p); got != want {
+            t.Errorf("RoleSet.IsGranted(%v) of %+v = %v, want %v", roles, p, got, want)
+        }
+    }
+}
+
+func TestRules_IsRoleSetGranted(t *testing.T) {
+    rules := Rules{
+        {ID: 0, Permission: &Permission{AuthorizedRoles: []string{"*"}}},
+        {ID: 1, Permission: &Permission{AuthorizedRoles: []string{"editor"}, ForbiddenRoles: []string{"intern"}}},
+    }
+    tests := []struct {
+        name  string
+        rules Rules
+        roles []string
+        want  PermissionState
+    }{
+        {name: "test0", rules: Rules{}, roles: []string{"editor"}, want: PermissionNeglected},
+        {name: "test1", rules: rules, roles: []string{"editor", "intern"}, want: PermissionGranted},
+        {name: "test2", rules: rules, roles: []string{"intern", "editor"}, want: PermissionUngranted},
+        {name: "test3", rules: rules[:1], roles: []string{"intern"}, want: PermissionGranted},
+    }
+    for _, tt := range tests {
+        if got := tt.rules.IsRoleSetGranted(NewRoleSet(tt.roles)); got != tt.want {
+            t.Errorf("%q. Rules.IsRoleSetGranted() = %v, want %v", tt.name, got, tt.want)
+        }
+    }
+}
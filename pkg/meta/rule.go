@@ -15,6 +15,10 @@
 package meta
 
 import (
+    "crypto/sha256"
+    "encoding/hex"
+    "sort"
+
     "github.com/hashicorp/go-multierror"
     jsoniter "github.com/json-iterator/go"
 )
@@ -29,15 +33,18 @@ type Rule struct {
     // When a request is matched to more than one rule,
     // then authentication will only use the permission configuration for the rule with the highest ID value.
     // If there are multiple rules that are the largest ID, then one of them will be used randomly.
-    ID int `json:"id" yaml:"id"`
+    ID int `json:"id" yaml:"id" toml:"id"`
     *Resource `yaml:",inline"`
     *Permission `yaml:",inline"`
 }
 
 // IsValid is used to test the validity of the Rule
 func (rule *Rule) IsValid() error {
-    if rule.Resource == nil || rule.Permission == nil {
-        return ErrEmptyStructure
+    if rule.Resource == nil {
+        return multierror.Prefix(ErrEmptyStructure, "resource:")
+    }
+    if rule.Permission == nil {
+        return multierror.Prefix(ErrEmptyStructure, "permission:")
     }
     err := rule.Resource.IsValid()
     if err != nil {
@@ -61,10 +68,11 @@ func (rules Rules) IsValid() error {
     return nil
 }
 
-// IsRolesGranted is used to determine whether the current role is admitted by the current rule.
-func (rules Rules) IsRolesGranted(roles []string) (PermissionState, error) {
+// GetDecisiveRule is used to get the rule that decides the permission of the rules,
+// which is the rule with the highest ID, nil is returned when the rules are empty.
+func (rules Rules) GetDecisiveRule() *Rule {
     if len(rules) == 0 {
-        return PermissionNeglected, nil
+        return nil
     }
     tail := rules[0]
     for i := 0; i < len(rules); i++ {
@@ -72,9 +80,57 @@ func (rules Rules) IsRolesGranted(roles []string) (PermissionState, error) {
             tail = rules[i]
         }
     }
+    return tail
+}
+
+// IsRolesGranted is used to determine whether the current role is admitted by the current rule.
+func (rules Rules) IsRolesGranted(roles []string) (PermissionState, error) {
+    tail := rules.GetDecisiveRule()
+    if tail == nil {
+        return PermissionNeglected, nil
+    }
     return tail.IsGranted(roles)
 }
 
+// Version is used to get the content hash of the rules,
+// the rules with the same content in the same order have the same version.
+func (rules Rules) Version() (string, error) {
+    data, err := jsoniter.Marshal(rules)
+    if err != nil {
+        return "", err
+    }
+    sum := sha256.Sum256(data)
+    return hex.EncodeToString(sum[:]), nil
+}
+
+// Prioritize is used to sort the rules from the most general to the most specific, and assign them
+// increasing IDs from first, so that the most specific rule matching a query decides it.
+// The rules are ordered by rank first when it is not nil, a lower rank being more general,
+// then by their wildcards, more wildcards being more general, and the ties by path, method and host.
+func (rules Rules) Prioritize(first int, rank func(rule *Rule) int) {
+    sort.SliceStable(rules, func(i, j int) bool {
+        a, b := rules[i], rules[j]
+        if rank != nil {
+            if ra, rb := rank(a), rank(b); ra != rb {
+                return ra < rb
+            }
+        }
+        if wa, wb := a.Wildcards(), b.Wildcards(); wa != wb {
+            return wa > wb
+        }
+        if a.Path != b.Path {
+            return a.Path < b.Path
+        }
+        if a.Method != b.Method {
+            return a.Method < b.Method
+        }
+        return a.Host < b.Host
+    })
+    for i, rule := range rules {
+        rule.ID = first + i
+    }
+}
+
 func (rules Rules) String() string {
     s, _ := jsoniter.MarshalToString(rules)
     return s
@@ -14,7 +14,11 @@
 
 package meta
 
-import "testing"
+import (
+    "fmt"
+    "reflect"
+    "testing"
+)
 
 func TestRule_IsValid(t *testing.T) {
     type fields struct {
@@ -197,6 +201,27 @@ func TestRules_IsRolesGranted(t *testing.T) {
     }
 }
 
+func TestRules_GetDecisiveRule(t *testing.T) {
+    first := &Rule{ID: 1}
+    second := &Rule{ID: 2}
+    third := &Rule{ID: 2}
+    tests := []struct {
+        name  string
+        rules Rules
+        want  *Rule
+    }{
+        {name: "test0", rules: Rules{}, want: nil},
+        {name: "test1", rules: Rules{first}, want: first},
+        {name: "test2", rules: Rules{second, first}, want: second},
+        {name: "test3", rules: Rules{second, first, third}, want: third},
+    }
+    for _, tt := range tests {
+        if got := tt.rules.GetDecisiveRule(); got != tt.want {
+            t.Errorf("%q. Rules.GetDecisiveRule() = %v, want %v", tt.name, got, tt.want)
+        }
+    }
+}
+
 func TestRules_String(t *testing.T) {
     tests := []struct {
         name  string
@@ -215,3 +240,76 @@ func TestRules_String(t *testing.T) {
         }
     }
 }
+
+func TestRules_Version(t *testing.T) {
+    root := &Rule{ID: 0, Resource: &Resource{Host: "*", Path: "**", Method: "*"}, Permission: &Permission{AuthorizedRoles: []string{"*"}}}
+    article := &Rule{ID: 1, Resource: &Resource{Host: "*", Path: "/article", Method: "PUT"}, Permission: &Permission{AuthorizedRoles: []string{"editor"}}}
+    tests := []struct {
+        name  string
+        a     Rules
+        b     Rules
+        equal bool
+    }{
+        {name: "test0", a: Rules{root, article}, b: Rules{root, article}, equal: true},
+        {name: "test1", a: Rules{root, article}, b: Rules{root}, equal: false},
+        {name: "test2", a: Rules{root, article}, b: Rules{article, root}, equal: false},
+    }
+    for _, tt := range tests {
+        a, err := tt.a.Version()
+        if err != nil {
+            t.Errorf("%q. Rules.Version() error = %v", tt.name, err)
+            continue
+        }
+        b, err := tt.b.Version()
+        if err != nil {
+            t.Errorf("%q. Rules.Version() error = %v", tt.name, err)
+            continue
+        }
+        if (a == b) != tt.equal {
+            t.Errorf("%q. Rules.Version() equal = %v, want %v", tt.name, a == b, tt.equal)
+        }
+    }
+}
+
+func TestRules_Prioritize(t *testing.T) {
+    newRule := func(host, path, method string) *Rule {
+        return &Rule{Resource: &Resource{Host: host, Path: path, Method: method}, Permission: &Permission{AllowAnyone: true}}
+    }
+    tests := []struct {
+        name  string
+        rules Rules
+        first int
+        rank  func(rule *Rule) int
+        want  []string
+    }{
+        {
+            name:  "test0",
+            rules: Rules{newRule("*", "/article/1", "GET"), newRule("*", "/article/*", "GET"), newRule("*", "**", "*")},
+            first: 10,
+            want:  []string{"10 * ** *", "11 * /article/* GET", "12 * /article/1 GET"},
+        },
+        {
+            name:  "test1",
+            rules: Rules{newRule("*", "/b", "GET"), newRule("a.com", "/b", "GET"), newRule("*", "/a", "PUT"), newRule("*", "/a", "GET")},
+            want:  []string{"0 * /a GET", "1 * /a PUT", "2 * /b GET", "3 a.com /b GET"},
+        },
+        {
+            name:  "test2",
+            rules: Rules{newRule("*", "/a", "GET"), newRule("*", "/a/**", "*")},
+            rank: func(rule *Rule) int {
+                return len(rule.Path)
+            },
+            want: []string{"0 * /a GET", "1 * /a/** *"},
+        },
+    }
+    for _, tt := range tests {
+        tt.rules.Prioritize(tt.first, tt.rank)
+        var got []string
+        for _, rule := range tt.rules {
+            got = append(got, fmt.Sprintf("%d %s %s %s", rule.ID, rule.Host, rule.Path, rule.Method))
+        }
+        if !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("%q. Rules.Prioritize() = %v, want %v", tt.name, got, tt.want)
+        }
+    }
+}
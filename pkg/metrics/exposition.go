@@ -0,0 +1,109 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+    "bytes"
+    "fmt"
+    "io"
+    "math"
+    "net/http"
+    "sort"
+    "strconv"
+)
+
+// ContentType is the content type of the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// formatFloat is used to format a sample value
+func formatFloat(v float64) string {
+    if math.IsInf(v, 1) {
+        return "+Inf"
+    }
+    return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// header is used to write the help and type of a metric
+func header(b *bytes.Buffer, name, kind, help string) {
+    fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+    fmt.Fprintf(b, "# TYPE %s %s\n", name, kind)
+}
+
+// writeHistogram is used to write a histogram with cumulative buckets
+func writeHistogram(b *bytes.Buffer, name, help string, h *histogram) {
+    header(b, name, "histogram", help)
+    var cumulative uint64
+    for i, bound := range h.bounds {
+        cumulative += h.counts[i]
+        fmt.Fprintf(b, "%s_bucket{le=\"%s\"} %d\n", name, formatFloat(bound), cumulative)
+    }
+    fmt.Fprintf(b, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+    fmt.Fprintf(b, "%s_sum %s\n", name, formatFloat(h.sum))
+    fmt.Fprintf(b, "%s_count %d\n", name, h.count)
+}
+
+// WriteTo is used to write the metrics in the Prometheus text exposition format
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+    b := &bytes.Buffer{}
+    c.lock.Lock()
+
+    name := c.namespace + "_decisions_total"
+    header(b, name, "counter", "Number of authorization decisions by state and decisive rule ID.")
+    keys := make([]decisionKey, 0, len(c.decisions))
+    for key := range c.decisions {
+        keys = append(keys, key)
+    }
+    sort.Slice(keys, func(i, j int) bool {
+        if keys[i].state != keys[j].state {
+            return keys[i].state < keys[j].state
+        }
+        return keys[i].ruleID < keys[j].ruleID
+    })
+    for _, key := range keys {
+        fmt.Fprintf(b, "%s{state=\"%s\",rule_id=\"%d\"} %d\n", name, key.state, key.ruleID, c.decisions[key])
+    }
+
+    writeHistogram(b, c.namespace+"_evaluation_duration_seconds",
+        "Latency of the evaluation of a query.", c.latency)
+    writeHistogram(b, c.namespace+"_query_candidate_rules",
+        "Number of rules matching a query in the tree.", c.candidates)
+
+    name = c.namespace + "_reloads_total"
+    header(b, name, "counter", "Number of reloads of the rules by result.")
+    for _, result := range []string{"failure", "success"} {
+        fmt.Fprintf(b, "%s{result=\"%s\"} %d\n", name, result, c.reloads[result])
+    }
+
+    name = c.namespace + "_rules"
+    header(b, name, "gauge", "Number of rules in effect.")
+    fmt.Fprintf(b, "%s %d\n", name, c.rules)
+
+    name = c.namespace + "_last_reload_success_timestamp_seconds"
+    header(b, name, "gauge", "Unix time of the last successful reload of the rules.")
+    var timestamp float64
+    if !c.lastReload.IsZero() {
+        timestamp = float64(c.lastReload.UnixNano()) / 1e9
+    }
+    fmt.Fprintf(b, "%s %s\n", name, formatFloat(timestamp))
+
+    c.lock.Unlock()
+    return b.WriteTo(w)
+}
+
+// ServeHTTP is used to expose the metrics to Prometheus
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", ContentType)
+    c.WriteTo(w)
+}
@@ -0,0 +1,138 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics collects the metrics of a controller
+// and exposes them in the Prometheus text exposition format, without any client library:
+//
+//  collector := metrics.New(metrics.Options{})
+//  rbac, err := grbac.New(grbac.WithYAML("rules.yaml", time.Minute), grbac.WithObserver(collector))
+//  http.Handle("/metrics", collector)
+package metrics
+
+import (
+    "sort"
+    "sync"
+    "time"
+
+    "github.com/storyicon/grbac/pkg/audit"
+    "github.com/storyicon/grbac/pkg/meta"
+)
+
+// define the default buckets of the histograms
+var (
+    DefaultLatencyBuckets   = []float64{0.000001, 0.0000025, 0.000005, 0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005, 0.001, 0.01}
+    DefaultCandidateBuckets = []float64{0, 1, 2, 4, 8, 16, 32, 64}
+)
+
+// Options defines the options of the collector
+type Options struct {
+    // Namespace is the prefix of the metric names, "grbac" by default
+    Namespace string
+    // LatencyBuckets are the upper bounds of the evaluation latency histogram in seconds
+    LatencyBuckets []float64
+    // CandidateBuckets are the upper bounds of the candidate rules histogram
+    CandidateBuckets []float64
+}
+
+// decisionKey defines the labels of the decision counter
+type decisionKey struct {
+    state  string
+    ruleID int
+}
+
+// histogram defines a histogram with fixed buckets
+type histogram struct {
+    bounds []float64
+    counts []uint64
+    sum    float64
+    count  uint64
+}
+
+// newHistogram is used to create a histogram, the bounds are sorted
+func newHistogram(bounds []float64) *histogram {
+    bounds = append([]float64(nil), bounds...)
+    sort.Float64s(bounds)
+    return &histogram{
+        bounds: bounds,
+        counts: make([]uint64, len(bounds)),
+    }
+}
+
+// observe is used to add a value to the histogram
+func (h *histogram) observe(v float64) {
+    for i, bound := range h.bounds {
+        if v <= bound {
+            h.counts[i]++
+            break
+        }
+    }
+    h.sum += v
+    h.count++
+}
+
+// Collector collects the metrics of a controller, it implements grbac.Observer and http.Handler
+type Collector struct {
+    namespace string
+
+    decisions  map[decisionKey]uint64
+    latency    *histogram
+    candidates *histogram
+    reloads    map[string]uint64
+    rules      int
+    lastReload time.Time
+
+    lock sync.Mutex
+}
+
+// New is used to create a collector
+func New(opts Options) *Collector {
+    if opts.Namespace == "" {
+        opts.Namespace = "grbac"
+    }
+    if len(opts.LatencyBuckets) == 0 {
+        opts.LatencyBuckets = DefaultLatencyBuckets
+    }
+    if len(opts.CandidateBuckets) == 0 {
+        opts.CandidateBuckets = DefaultCandidateBuckets
+    }
+    return &Collector{
+        namespace:  opts.Namespace,
+        decisions:  map[decisionKey]uint64{},
+        latency:    newHistogram(opts.LatencyBuckets),
+        candidates: newHistogram(opts.CandidateBuckets),
+        reloads:    map[string]uint64{},
+    }
+}
+
+// ObserveDecision is used to count a decision and observe its latency and candidate rules
+func (c *Collector) ObserveDecision(s meta.PermissionState, ruleID int, candidates int, duration time.Duration) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    c.decisions[decisionKey{state: audit.Outcome(s), ruleID: ruleID}]++
+    c.latency.observe(duration.Seconds())
+    c.candidates.observe(float64(candidates))
+}
+
+// ObserveReload is used to count a reload of the rules
+func (c *Collector) ObserveReload(rules int, err error) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    c.rules = rules
+    if err != nil {
+        c.reloads["failure"]++
+        return
+    }
+    c.reloads["success"]++
+    c.lastReload = time.Now()
+}
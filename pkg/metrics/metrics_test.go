@@ -0,0 +1,67 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+    "errors"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/storyicon/grbac/pkg/meta"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestCollector(t *testing.T) {
+    c := New(Options{
+        Namespace:        "rbac",
+        LatencyBuckets:   []float64{0.001, 0.0001},
+        CandidateBuckets: []float64{1, 2},
+    })
+    c.ObserveReload(2, nil)
+    c.ObserveReload(2, errors.New("invalid rule"))
+    c.lastReload = time.Unix(1546300800, 0)
+    c.ObserveDecision(meta.PermissionGranted, 1, 2, 50*time.Microsecond)
+    c.ObserveDecision(meta.PermissionGranted, 1, 2, 500*time.Microsecond)
+    c.ObserveDecision(meta.PermissionUngranted, 0, 1, 2*time.Millisecond)
+    c.ObserveDecision(meta.PermissionNeglected, -1, 0, 0)
+
+    recorder := httptest.NewRecorder()
+    c.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+    assert.Equal(t, ContentType, recorder.Header().Get("Content-Type"))
+    body := recorder.Body.String()
+    for _, line := range []string{
+        "# TYPE rbac_decisions_total counter",
+        `rbac_decisions_total{state="granted",rule_id="1"} 2`,
+        `rbac_decisions_total{state="neglected",rule_id="-1"} 1`,
+        `rbac_decisions_total{state="ungranted",rule_id="0"} 1`,
+        "# TYPE rbac_evaluation_duration_seconds histogram",
+        `rbac_evaluation_duration_seconds_bucket{le="0.0001"} 2`,
+        `rbac_evaluation_duration_seconds_bucket{le="0.001"} 3`,
+        `rbac_evaluation_duration_seconds_bucket{le="+Inf"} 4`,
+        "rbac_evaluation_duration_seconds_sum 0.00255",
+        "rbac_evaluation_duration_seconds_count 4",
+        `rbac_query_candidate_rules_bucket{le="1"} 2`,
+        `rbac_query_candidate_rules_bucket{le="2"} 4`,
+        "rbac_query_candidate_rules_sum 5",
+        `rbac_reloads_total{result="failure"} 1`,
+        `rbac_reloads_total{result="success"} 1`,
+        "rbac_rules 2",
+        "rbac_last_reload_success_timestamp_seconds 1.5463008e+09",
+    } {
+        assert.True(t, strings.Contains(body, line+"\n"), line)
+    }
+}
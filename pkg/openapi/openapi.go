@@ -0,0 +1,402 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package openapi converts OpenAPI 3 documents into grbac rules.
+//
+// Every operation of the document becomes a rule, its path templates are converted into wildcards,
+// and its roles are read from a vendor extension or from the scopes of its security requirements:
+//
+//  paths:
+//    /articles/{id}:
+//      delete:
+//        x-grbac-roles: [editor]
+//      get:
+//        security: []
+//
+// The roles of an operation are resolved in the following order:
+//  1. the vendor extension of the operation
+//  2. the security requirements of the operation
+//  3. the vendor extension of the path item
+//  4. the vendor extension of the document
+//  5. the security requirements of the document
+//
+// The vendor extension is either a list of authorized roles or a permission:
+//
+//  x-grbac-roles:
+//    authorized_roles: [editor]
+//    forbidden_roles: [black_user]
+//
+// An empty list of security requirements makes the operation public,
+// a security requirement without scopes accepts any role.
+// The scopes of a security requirement are all required, which the any-of roles of grbac can not express,
+// so the operations with a requirement of several scopes are skipped and reported as unsupported.
+package openapi
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "regexp"
+    "sort"
+    "strings"
+
+    "github.com/storyicon/grbac/pkg/meta"
+    "gopkg.in/yaml.v3"
+)
+
+// DefaultExtension is the default vendor extension holding the roles of an operation
+const DefaultExtension = "x-grbac-roles"
+
+// define a set of errors
+var (
+    ErrNotOpenAPI3         = errors.New("not an OpenAPI 3 document")
+    ErrInvalidExtension    = errors.New("invalid roles extension")
+    ErrInvalidSecurity     = errors.New("invalid security requirements")
+    ErrUnsupportedSecurity = errors.New("security requirement with several scopes")
+)
+
+// methods defines the operations of a path item
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// templatePattern matches the path templates like {id}
+var templatePattern = regexp.MustCompile(`\{[^{}/]*\}`)
+
+// Options is used to customize the importer
+type Options struct {
+    // Host is the host of the generated rules, defaults to "*"
+    Host string
+    // BasePath is prepended to the paths of the generated rules,
+    // defaults to the path of the first server of the document.
+    BasePath string
+    // Extension is the vendor extension holding the roles, defaults to DefaultExtension
+    Extension string
+    // DefaultRoles are authorized to the operations without authorization metadata,
+    // which are skipped when it is empty. Those operations are reported in Missing either way,
+    // so that the default grants can be reviewed.
+    DefaultRoles []string
+    // FirstID is the ID of the first generated rule
+    FirstID int
+}
+
+// Operation defines an operation of the document
+type Operation struct {
+    Method      string
+    Path        string
+    OperationID string
+}
+
+func (op *Operation) String() string {
+    if op.OperationID != "" {
+        return fmt.Sprintf("%s %s (%s)", op.Method, op.Path, op.OperationID)
+    }
+    return op.Method + " " + op.Path
+}
+
+// Report is the result of an import
+type Report struct {
+    // Rules are the generated rules,
+    // the rules of more specific paths have higher IDs.
+    Rules meta.Rules
+    // Missing are the operations without authorization metadata
+    Missing []*Operation
+    // Unsupported are the operations skipped because a security requirement has several scopes,
+    // which are all required by OpenAPI
+    Unsupported []*Operation
+}
+
+// ImportFile is used to import an OpenAPI 3 document from a json or yaml file
+func ImportFile(file string, opts *Options) (*Report, error) {
+    data, err := ioutil.ReadFile(file)
+    if err != nil {
+        return nil, err
+    }
+    return Import(data, opts)
+}
+
+// Import is used to convert an OpenAPI 3 document in json or yaml into rules
+func Import(data []byte, opts *Options) (*Report, error) {
+    if opts == nil {
+        opts = &Options{}
+    }
+    doc, err := parse(data)
+    if err != nil {
+        return nil, err
+    }
+    version, _ := doc["openapi"].(string)
+    if !strings.HasPrefix(version, "3.") {
+        return nil, ErrNotOpenAPI3
+    }
+    extension := opts.Extension
+    if extension == "" {
+        extension = DefaultExtension
+    }
+    host := opts.Host
+    if host == "" {
+        host = "*"
+    }
+    basePath := opts.BasePath
+    if basePath == "" {
+        basePath = serverPath(doc)
+    }
+
+    docPermission, err := extensionPermission(doc, extension)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %s", extension, err)
+    }
+    docSecurity, err := securityPermission(doc)
+    docUnsupported := err == ErrUnsupportedSecurity
+    if err != nil && !docUnsupported {
+        return nil, fmt.Errorf("security: %s", err)
+    }
+
+    report := &Report{Rules: meta.Rules{}}
+    paths, _ := doc["paths"].(map[string]interface{})
+    for path, value := range paths {
+        item, _ := value.(map[string]interface{})
+        itemPermission, err := extensionPermission(item, extension)
+        if err != nil {
+            return nil, fmt.Errorf("%s: %s: %s", path, extension, err)
+        }
+        resource := strings.TrimSuffix(basePath, "/") + templatePattern.ReplaceAllString(path, "*")
+        for _, method := range methods {
+            op, ok := item[method].(map[string]interface{})
+            if !ok {
+                continue
+            }
+            operation := &Operation{Method: strings.ToUpper(method), Path: path}
+            operation.OperationID, _ = op["operationId"].(string)
+            permission, err := extensionPermission(op, extension)
+            if err != nil {
+                return nil, fmt.Errorf("%s: %s: %s", operation, extension, err)
+            }
+            unsupported := false
+            if permission == nil {
+                permission, err = securityPermission(op)
+                if unsupported = err == ErrUnsupportedSecurity; unsupported {
+                    err = nil
+                }
+                if err != nil {
+                    return nil, fmt.Errorf("%s: security: %s", operation, err)
+                }
+            }
+            if !unsupported {
+                for _, candidate := range []*meta.Permission{itemPermission, docPermission, docSecurity} {
+                    if permission == nil {
+                        permission = candidate
+                    }
+                }
+                unsupported = permission == nil && docUnsupported
+            }
+            if unsupported {
+                report.Unsupported = append(report.Unsupported, operation)
+                continue
+            }
+            if permission == nil {
+                report.Missing = append(report.Missing, operation)
+                if len(opts.DefaultRoles) == 0 {
+                    continue
+                }
+                permission = &meta.Permission{AuthorizedRoles: opts.DefaultRoles}
+            }
+            report.Rules = append(report.Rules, &meta.Rule{
+                Resource: &meta.Resource{
+                    Host:   host,
+                    Path:   resource,
+                    Method: operation.Method,
+                },
+                Permission: copyPermission(permission),
+            })
+        }
+    }
+
+    report.Rules.Prioritize(opts.FirstID, nil)
+    sortOperations(report.Missing)
+    sortOperations(report.Unsupported)
+    return report, nil
+}
+
+// sortOperations is used to sort the operations by path and method
+func sortOperations(operations []*Operation) {
+    sort.Slice(operations, func(i, j int) bool {
+        if operations[i].Path != operations[j].Path {
+            return operations[i].Path < operations[j].Path
+        }
+        return operations[i].Method < operations[j].Method
+    })
+}
+
+// parse is used to decode a json or yaml document into generic values
+func parse(data []byte) (map[string]interface{}, error) {
+    var doc map[string]interface{}
+    trimmed := bytes.TrimSpace(data)
+    if len(trimmed) > 0 && trimmed[0] == '{' {
+        err := json.Unmarshal(trimmed, &doc)
+        return doc, err
+    }
+    var value interface{}
+    if err := yaml.Unmarshal(data, &value); err != nil {
+        return nil, err
+    }
+    doc, _ = normalize(value).(map[string]interface{})
+    if doc == nil {
+        return nil, ErrNotOpenAPI3
+    }
+    return doc, nil
+}
+
+// normalize is used to convert the maps decoded by yaml into the maps decoded by json,
+// whose keys are always strings.
+func normalize(value interface{}) interface{} {
+    switch v := value.(type) {
+    case map[interface{}]interface{}:
+        m := make(map[string]interface{}, len(v))
+        for key, item := range v {
+            m[fmt.Sprint(key)] = normalize(item)
+        }
+        return m
+    case map[string]interface{}:
+        for key, item := range v {
+            v[key] = normalize(item)
+        }
+        return v
+    case []interface{}:
+        for i, item := range v {
+            v[i] = normalize(item)
+        }
+        return v
+    }
+    return value
+}
+
+// serverPath is used to get the path of the first server of the document
+func serverPath(doc map[string]interface{}) string {
+    servers, _ := doc["servers"].([]interface{})
+    if len(servers) == 0 {
+        return ""
+    }
+    server, _ := servers[0].(map[string]interface{})
+    url, _ := server["url"].(string)
+    if index := strings.Index(url, "://"); index >= 0 {
+        url = url[index+3:]
+        index = strings.Index(url, "/")
+        if index < 0 {
+            return ""
+        }
+        url = url[index:]
+    }
+    return strings.TrimSuffix(templatePattern.ReplaceAllString(url, "*"), "/")
+}
+
+// extensionPermission is used to read the permission from the vendor extension of an object,
+// nil is returned when the extension is absent.
+func extensionPermission(object map[string]interface{}, extension string) (*meta.Permission, error) {
+    value, ok := object[extension]
+    if !ok || value == nil {
+        return nil, nil
+    }
+    data, err := json.Marshal(value)
+    if err != nil {
+        return nil, err
+    }
+    permission := &meta.Permission{}
+    switch value.(type) {
+    case []interface{}:
+        err = json.Unmarshal(data, &permission.AuthorizedRoles)
+    case map[string]interface{}:
+        err = json.Unmarshal(data, permission)
+    default:
+        return nil, ErrInvalidExtension
+    }
+    if err != nil {
+        return nil, ErrInvalidExtension
+    }
+    if err := permission.IsValid(); err != nil {
+        return nil, err
+    }
+    return permission, nil
+}
+
+// securityPermission is used to convert the security requirements of an object into a permission,
+// the scope of each requirement is an authorized role, and ErrUnsupportedSecurity is returned
+// when a requirement has several scopes, since all of them are required.
+// nil is returned when the security requirements are absent.
+func securityPermission(object map[string]interface{}) (*meta.Permission, error) {
+    value, ok := object["security"]
+    if !ok || value == nil {
+        return nil, nil
+    }
+    requirements, ok := value.([]interface{})
+    if !ok {
+        return nil, ErrInvalidSecurity
+    }
+    if len(requirements) == 0 {
+        return &meta.Permission{AllowAnyone: true}, nil
+    }
+    roles := map[string]bool{}
+    for _, requirement := range requirements {
+        schemes, ok := requirement.(map[string]interface{})
+        if !ok {
+            return nil, ErrInvalidSecurity
+        }
+        if len(schemes) == 0 {
+            // an empty requirement makes the security optional
+            return &meta.Permission{AllowAnyone: true}, nil
+        }
+        // the schemes of a requirement are all required, a scheme without scopes adds no role
+        scopes := map[string]bool{}
+        for _, value := range schemes {
+            list, ok := value.([]interface{})
+            if !ok {
+                return nil, ErrInvalidSecurity
+            }
+            for _, scope := range list {
+                role, ok := scope.(string)
+                if !ok || role == "" {
+                    return nil, ErrInvalidSecurity
+                }
+                scopes[role] = true
+            }
+        }
+        switch len(scopes) {
+        case 0:
+            roles["*"] = true
+        case 1:
+            for role := range scopes {
+                roles[role] = true
+            }
+        default:
+            return nil, ErrUnsupportedSecurity
+        }
+    }
+    if roles["*"] {
+        return &meta.Permission{AuthorizedRoles: []string{"*"}}, nil
+    }
+    permission := &meta.Permission{}
+    for role := range roles {
+        permission.AuthorizedRoles = append(permission.AuthorizedRoles, role)
+    }
+    sort.Strings(permission.AuthorizedRoles)
+    return permission, nil
+}
+
+// copyPermission is used to copy a permission shared by several operations
+func copyPermission(permission *meta.Permission) *meta.Permission {
+    return &meta.Permission{
+        AuthorizedRoles: append([]string(nil), permission.AuthorizedRoles...),
+        ForbiddenRoles:  append([]string(nil), permission.ForbiddenRoles...),
+        AllowAnyone:     permission.AllowAnyone,
+    }
+}
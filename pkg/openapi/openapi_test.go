@@ -0,0 +1,167 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openapi
+
+import (
+    "testing"
+
+    "github.com/storyicon/grbac/pkg/meta"
+    "github.com/stretchr/testify/assert"
+)
+
+const spec = `
+openapi: 3.0.1
+servers:
+- url: https://{env}.domain.com/api/v1
+security:
+- oauth: [reader]
+paths:
+  /articles:
+    get:
+      operationId: listArticles
+      security: []
+    post:
+      x-grbac-roles: [editor]
+  /articles/{id}:
+    x-grbac-roles:
+      authorized_roles: ["*"]
+      forbidden_roles: [black_user]
+    get: {}
+    delete:
+      security:
+      - oauth: [editor]
+      - apiKey: []
+  /articles/{id}/comments:
+    get:
+      security:
+      - oauth: [reader, editor]
+      - oauth: [admin]
+`
+
+func TestImport(t *testing.T) {
+    report, err := Import([]byte(spec), nil)
+    assert.Equal(t, nil, err)
+    want := meta.Rules{
+        {
+            ID:         0,
+            Resource:   &meta.Resource{Host: "*", Path: "/api/v1/articles/*", Method: "DELETE"},
+            Permission: &meta.Permission{AuthorizedRoles: []string{"*"}},
+        },
+        {
+            ID:         1,
+            Resource:   &meta.Resource{Host: "*", Path: "/api/v1/articles/*", Method: "GET"},
+            Permission: &meta.Permission{AuthorizedRoles: []string{"*"}, ForbiddenRoles: []string{"black_user"}},
+        },
+        {
+            ID:         2,
+            Resource:   &meta.Resource{Host: "*", Path: "/api/v1/articles", Method: "GET"},
+            Permission: &meta.Permission{AllowAnyone: true},
+        },
+        {
+            ID:         3,
+            Resource:   &meta.Resource{Host: "*", Path: "/api/v1/articles", Method: "POST"},
+            Permission: &meta.Permission{AuthorizedRoles: []string{"editor"}},
+        },
+    }
+    assert.Equal(t, want.String(), report.Rules.String())
+    assert.Equal(t, 0, len(report.Missing))
+    // the scopes of a requirement are all required
+    assert.Equal(t, []*Operation{{Method: "GET", Path: "/articles/{id}/comments"}}, report.Unsupported)
+}
+
+func TestImportUnsupported(t *testing.T) {
+    data := `
+openapi: 3.0.0
+security:
+- oauth2: [read, write]
+paths:
+  /articles:
+    get:
+      security:
+      - oauth2: [read]
+      - oauth2: [admin]
+    post:
+      security:
+      - oauth2: [write]
+        apiKey: []
+    put:
+      security:
+      - oauth2: [write]
+        apiKey: [admin]
+    delete: {}
+  /drafts:
+    x-grbac-roles: [editor]
+    get: {}
+`
+    report, err := Import([]byte(data), nil)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []*Operation{
+        {Method: "DELETE", Path: "/articles"},
+        {Method: "PUT", Path: "/articles"},
+    }, report.Unsupported)
+    assert.Equal(t, 0, len(report.Missing))
+    assert.Equal(t, `[{"id":0,"host":"*","path":"/articles","method":"GET","authorized_roles":["admin","read"],"forbidden_roles":null,"allow_anyone":false},`+
+        `{"id":1,"host":"*","path":"/articles","method":"POST","authorized_roles":["write"],"forbidden_roles":null,"allow_anyone":false},`+
+        `{"id":2,"host":"*","path":"/drafts","method":"GET","authorized_roles":["editor"],"forbidden_roles":null,"allow_anyone":false}]`, report.Rules.String())
+}
+
+func TestImportMissing(t *testing.T) {
+    data := `{
+        "openapi": "3.0.0",
+        "paths": {
+            "/users/{id}": {"get": {"operationId": "getUser"}, "put": {"x-grbac-roles": ["admin"]}},
+            "/health": {"get": {}}
+        }
+    }`
+    report, err := Import([]byte(data), &Options{Host: "domain.com", FirstID: 10})
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []*Operation{
+        {Method: "GET", Path: "/health"},
+        {Method: "GET", Path: "/users/{id}", OperationID: "getUser"},
+    }, report.Missing)
+    assert.Equal(t, `[{"id":10,"host":"domain.com","path":"/users/*","method":"PUT","authorized_roles":["admin"],"forbidden_roles":null,"allow_anyone":false}]`, report.Rules.String())
+
+    // the operations granted to the default roles are still reported
+    report, err = Import([]byte(data), &Options{DefaultRoles: []string{"*"}, BasePath: "/v2"})
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []*Operation{
+        {Method: "GET", Path: "/health"},
+        {Method: "GET", Path: "/users/{id}", OperationID: "getUser"},
+    }, report.Missing)
+    assert.Equal(t, 3, len(report.Rules))
+    assert.Equal(t, "/v2/health", report.Rules[2].Path)
+    assert.Equal(t, []string{"*"}, report.Rules[2].AuthorizedRoles)
+    assert.Equal(t, "/v2/users/*", report.Rules[0].Path)
+    assert.Equal(t, "GET", report.Rules[0].Method)
+    assert.Equal(t, []string{"*"}, report.Rules[0].AuthorizedRoles)
+}
+
+func TestImportErrors(t *testing.T) {
+    tests := []struct {
+        name string
+        data string
+        want string
+    }{
+        {name: "test0", data: `swagger: "2.0"`, want: ErrNotOpenAPI3.Error()},
+        {name: "test1", data: "openapi: 3.0.0\npaths:\n  /a:\n    get:\n      x-grbac-roles: admin\n", want: "GET /a: x-grbac-roles: " + ErrInvalidExtension.Error()},
+        {name: "test2", data: "openapi: 3.0.0\npaths:\n  /a:\n    get:\n      security: oauth\n", want: "GET /a: security: " + ErrInvalidSecurity.Error()},
+    }
+    for _, tt := range tests {
+        _, err := Import([]byte(tt.data), nil)
+        if assert.NotNil(t, err, tt.name) {
+            assert.Equal(t, tt.want, err.Error(), tt.name)
+        }
+    }
+}
@@ -0,0 +1,170 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package probe generates concrete values matched by the enhanced wildcards,
+// which are used to ask which rules apply to a resource pattern.
+package probe
+
+import (
+    "errors"
+    "strings"
+
+    "github.com/storyicon/grbac/pkg/meta"
+    "github.com/storyicon/grbac/pkg/path"
+)
+
+// ErrNoSample is returned when no sample can be generated for a pattern
+var ErrNoSample = errors.New("no sample matches the pattern")
+
+// Segment is the text used in place of the wildcards
+const Segment = "sample"
+
+// Methods are the candidates of a method pattern
+var Methods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "CONNECT", "TRACE"}
+
+// Expand is used to expand the braces of a pattern into a list of patterns:
+//  /{articles,drafts}/{1,2}   ->   /articles/1 /articles/2 /drafts/1 /drafts/2
+func Expand(pattern string) []string {
+    start, end, alternatives := firstBraces(pattern)
+    if start < 0 {
+        return []string{pattern}
+    }
+    var patterns []string
+    for _, alternative := range alternatives {
+        patterns = append(patterns, Expand(pattern[:start]+alternative+pattern[end:])...)
+    }
+    return patterns
+}
+
+// firstBraces is used to find the first top level braces of a pattern and split its alternatives
+func firstBraces(pattern string) (start int, end int, alternatives []string) {
+    start = -1
+    depth := 0
+    last := 0
+    for i := 0; i < len(pattern); i++ {
+        switch pattern[i] {
+        case '\\':
+            i++
+        case '{':
+            if depth == 0 {
+                start, last = i, i+1
+            }
+            depth++
+        case ',':
+            if depth == 1 {
+                alternatives = append(alternatives, pattern[last:i])
+                last = i + 1
+            }
+        case '}':
+            if depth == 0 {
+                continue
+            }
+            depth--
+            if depth == 0 {
+                return start, i + 1, append(alternatives, pattern[last:i])
+            }
+        }
+    }
+    return -1, -1, nil
+}
+
+// Sample is used to generate a value matched by the pattern,
+// the wildcards are replaced by Segment.
+func Sample(pattern string) (string, error) {
+    var b strings.Builder
+    for _, p := range Expand(pattern)[:1] {
+        for i := 0; i < len(p); i++ {
+            switch {
+            case p[i] == '\\' && i+1 < len(p):
+                i++
+                b.WriteByte(p[i])
+            case strings.HasPrefix(p[i:], "**"):
+                b.WriteString(Segment + "/" + Segment)
+                i++
+            case p[i] == '*':
+                b.WriteString(Segment)
+            case p[i] == '?':
+                b.WriteByte('x')
+            case p[i] == '[':
+                end := strings.IndexByte(p[i:], ']')
+                if end < 0 {
+                    return "", ErrNoSample
+                }
+                c, ok := sampleClass(p[i+1 : i+end])
+                if !ok {
+                    return "", ErrNoSample
+                }
+                b.WriteByte(c)
+                i += end
+            default:
+                b.WriteByte(p[i])
+            }
+        }
+    }
+    sample := b.String()
+    matched, err := path.Match(pattern, sample)
+    if err != nil {
+        return "", err
+    }
+    if !matched {
+        return "", ErrNoSample
+    }
+    return sample, nil
+}
+
+// sampleClass is used to pick a character of a character class
+func sampleClass(class string) (byte, bool) {
+    if strings.HasPrefix(class, "^") || strings.HasPrefix(class, "!") {
+        for _, c := range []byte("xyzXYZ0123456789") {
+            if matched, _ := path.Match("["+class+"]", string(c)); matched {
+                return c, true
+            }
+        }
+        return 0, false
+    }
+    if class == "" {
+        return 0, false
+    }
+    if class[0] == '\\' && len(class) > 1 {
+        return class[1], true
+    }
+    return class[0], true
+}
+
+// Query is used to generate a query matched by the resource.
+// The method is picked from Methods, so that the query looks like a real request.
+func Query(resource *meta.Resource) (*meta.Query, error) {
+    host, err := Sample(resource.Host)
+    if err != nil {
+        return nil, err
+    }
+    p, err := Sample(resource.Path)
+    if err != nil {
+        return nil, err
+    }
+    for _, method := range Methods {
+        matched, err := path.Match(resource.Method, method)
+        if err != nil {
+            return nil, err
+        }
+        if matched {
+            return &meta.Query{Host: host, Path: p, Method: method}, nil
+        }
+    }
+    method, err := Sample(resource.Method)
+    if err != nil {
+        return nil, err
+    }
+    return &meta.Query{Host: host, Path: p, Method: method}, nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package probe
+
+import (
+    "testing"
+
+    "github.com/storyicon/grbac/pkg/meta"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestExpand(t *testing.T) {
+    tests := []struct {
+        name    string
+        pattern string
+        want    []string
+    }{
+        {name: "test0", pattern: "/articles", want: []string{"/articles"}},
+        {name: "test1", pattern: "{GET,PUT}", want: []string{"GET", "PUT"}},
+        {name: "test2", pattern: "/{a,b}/{1,2}", want: []string{"/a/1", "/a/2", "/b/1", "/b/2"}},
+        {name: "test3", pattern: "/{a,b{c,d}}", want: []string{"/a", "/bc", "/bd"}},
+        {name: "test4", pattern: `/\{a,b}`, want: []string{`/\{a,b}`}},
+    }
+    for _, tt := range tests {
+        assert.Equal(t, tt.want, Expand(tt.pattern), tt.name)
+    }
+}
+
+func TestSample(t *testing.T) {
+    tests := []struct {
+        name    string
+        pattern string
+        want    string
+        wantErr bool
+    }{
+        {name: "test0", pattern: "/articles/*", want: "/articles/sample"},
+        {name: "test1", pattern: "/articles/**", want: "/articles/sample/sample"},
+        {name: "test2", pattern: "**", want: "sample/sample"},
+        {name: "test3", pattern: "/v?/[a-c]/[^a-z]", want: "/vx/a/X"},
+        {name: "test4", pattern: `/a\*b`, want: "/a*b"},
+        {name: "test5", pattern: "{*.domain.com,domain.com}", want: "sample.domain.com"},
+        {name: "test6", pattern: "/[", wantErr: true},
+    }
+    for _, tt := range tests {
+        got, err := Sample(tt.pattern)
+        if (err != nil) != tt.wantErr {
+            t.Errorf("%q. Sample() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+            continue
+        }
+        assert.Equal(t, tt.want, got, tt.name)
+    }
+}
+
+func TestQuery(t *testing.T) {
+    query, err := Query(&meta.Resource{Host: "*", Path: "/articles/*", Method: "*"})
+    assert.Equal(t, nil, err)
+    assert.Equal(t, &meta.Query{Host: "sample", Path: "/articles/sample", Method: "GET"}, query)
+
+    query, err = Query(&meta.Resource{Host: "domain.com", Path: "/", Method: "{PUT,POST}"})
+    assert.Equal(t, nil, err)
+    assert.Equal(t, &meta.Query{Host: "domain.com", Path: "/", Method: "POST"}, query)
+}
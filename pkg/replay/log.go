@@ -0,0 +1,132 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+    "errors"
+    "net/url"
+    "regexp"
+    "strings"
+
+    jsoniter "github.com/json-iterator/go"
+    "github.com/storyicon/grbac/pkg/meta"
+)
+
+// define the formats of the access logs
+const (
+    // FormatAuto detects the format of every line, the lines starting with { are json
+    FormatAuto = ""
+    // FormatCommon is the Common Log Format, the Combined Log Format is accepted as well
+    FormatCommon = "common"
+    // FormatJSON is json lines with host, method, path and roles fields
+    FormatJSON = "json"
+)
+
+// define a set of errors
+var (
+    ErrUnsupportedFormat = errors.New("unsupported access log format")
+    ErrMalformedLine     = errors.New("malformed access log line")
+)
+
+// Entry defines a request of an access log
+type Entry struct {
+    Line  int
+    Query *meta.Query
+    Roles []string
+}
+
+// commonLog matches the Common Log Format and the Combined Log Format:
+//  127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://referer" "agent"
+var commonLog = regexp.MustCompile(`^(\S+) (\S+) (\S+) \[([^\]]+)\] "(\S+) (\S+)(?: [^"]*)?" (\d{3}|-) (\d+|-)`)
+
+// jsonEntry defines a json line of an access log
+type jsonEntry struct {
+    Host   string   `json:"host"`
+    Method string   `json:"method"`
+    Path   string   `json:"path"`
+    Roles  []string `json:"roles"`
+}
+
+// parse is used to parse a line of an access log
+func parse(format string, text string, opts *Options) (*Entry, error) {
+    if format == FormatAuto {
+        format = FormatCommon
+        if strings.HasPrefix(text, "{") {
+            format = FormatJSON
+        }
+    }
+    switch format {
+    case FormatCommon:
+        return parseCommon(text, opts)
+    case FormatJSON:
+        return parseJSON(text, opts)
+    }
+    return nil, ErrUnsupportedFormat
+}
+
+// parseCommon is used to parse a line of the Common Log Format,
+// the host is Options.Host unless the request target is an absolute url,
+// and the roles are resolved from the authenticated user.
+func parseCommon(text string, opts *Options) (*Entry, error) {
+    match := commonLog.FindStringSubmatch(text)
+    if match == nil {
+        return nil, ErrMalformedLine
+    }
+    query, err := newQuery(opts.Host, match[5], match[6])
+    if err != nil {
+        return nil, err
+    }
+    var roles []string
+    if user := match[3]; user != "-" {
+        roles = opts.roles(user)
+    }
+    return &Entry{Query: query, Roles: roles}, nil
+}
+
+// parseJSON is used to parse a json line
+func parseJSON(text string, opts *Options) (*Entry, error) {
+    var entry jsonEntry
+    if err := jsoniter.UnmarshalFromString(text, &entry); err != nil {
+        return nil, err
+    }
+    if entry.Method == "" || entry.Path == "" {
+        return nil, ErrMalformedLine
+    }
+    host := entry.Host
+    if host == "" {
+        host = opts.Host
+    }
+    query, err := newQuery(host, entry.Method, entry.Path)
+    if err != nil {
+        return nil, err
+    }
+    return &Entry{Query: query, Roles: entry.Roles}, nil
+}
+
+// newQuery is used to build the query of a request target, the query string is dropped
+func newQuery(host, method, target string) (*meta.Query, error) {
+    u, err := url.Parse(target)
+    if err != nil {
+        return nil, err
+    }
+    if u.Host != "" {
+        host = u.Host
+    }
+    return &meta.Query{
+        Host:   host,
+        Path:   u.Path,
+        Method: strings.ToUpper(method),
+    }, nil
+}
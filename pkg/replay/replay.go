@@ -0,0 +1,220 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package replay evaluates the requests of access logs against two controllers,
+// so that the decisions changed by a new policy are found before it is deployed.
+package replay
+
+import (
+    "bufio"
+    "io"
+    "sort"
+    "strings"
+
+    "github.com/storyicon/grbac"
+)
+
+// Options defines the options of the replay
+type Options struct {
+    // Format is the format of the access log, FormatAuto by default
+    Format string
+    // Host is the host of the requests whose log does not record it
+    Host string
+    // Roles resolves the roles of the authenticated user of the Common Log Format,
+    // the user is used as the only role by default.
+    Roles func(user string) []string
+    // Route is used to group the requests by their decisions, which may be unchanged.
+    // By default, the requests are grouped by the resource of the new decisive rule,
+    // or of the old one when no new rule matches, such as "{DELETE,PUT} * /articles/*",
+    // and the requests matching no rule are grouped as NoRoute.
+    Route func(change *Change) string
+    // Limit is the maximum of changes kept in the report, 0 means no limit.
+    // The statistics always count all the changes.
+    Limit int
+}
+
+// roles is used to resolve the roles of a user
+func (opts *Options) roles(user string) []string {
+    if opts.Roles != nil {
+        return opts.Roles(user)
+    }
+    return []string{user}
+}
+
+// NoRoute is the default route of the requests matching no rule
+const NoRoute = "-"
+
+// route is used to get the route of a request
+func (opts *Options) route(change *Change) string {
+    if opts.Route != nil {
+        return opts.Route(change)
+    }
+    rule := change.New.Rule
+    if rule == nil {
+        rule = change.Old.Rule
+    }
+    if rule == nil || rule.Resource == nil {
+        return NoRoute
+    }
+    return rule.Method + " " + rule.Host + " " + rule.Path
+}
+
+// Change defines a request whose decision changes
+type Change struct {
+    *Entry
+    Old *grbac.Decision
+    New *grbac.Decision
+}
+
+// Issue defines a line which can not be replayed
+type Issue struct {
+    Line   int
+    Text   string
+    Reason string
+}
+
+// Stat defines the number of requests and changed decisions
+type Stat struct {
+    Total   int
+    Changed int
+}
+
+// add is used to count a request
+func (stat *Stat) add(changed bool) {
+    stat.Total++
+    if changed {
+        stat.Changed++
+    }
+}
+
+// RuleStat defines the statistic of a decisive rule
+type RuleStat struct {
+    // RuleID is the ID of the rule, -1 means no rule
+    RuleID int
+    Stat
+}
+
+// RouteStat defines the statistic of a route
+type RouteStat struct {
+    Route string
+    Stat
+}
+
+// Report defines the result of a replay
+type Report struct {
+    Stat
+    Changes []*Change
+    Skipped []*Issue
+
+    rules  map[int]*RuleStat
+    routes map[string]*RouteStat
+}
+
+// Rules is used to get the statistics by the IDs of the decisive rules,
+// a request is counted for both its old and new decisive rule.
+// The rules with the most changes come first.
+func (r *Report) Rules() []*RuleStat {
+    stats := make([]*RuleStat, 0, len(r.rules))
+    for _, stat := range r.rules {
+        stats = append(stats, stat)
+    }
+    sort.Slice(stats, func(i, j int) bool {
+        if stats[i].Changed != stats[j].Changed {
+            return stats[i].Changed > stats[j].Changed
+        }
+        return stats[i].RuleID < stats[j].RuleID
+    })
+    return stats
+}
+
+// Routes is used to get the statistics by routes, the routes with the most changes come first
+func (r *Report) Routes() []*RouteStat {
+    stats := make([]*RouteStat, 0, len(r.routes))
+    for _, stat := range r.routes {
+        stats = append(stats, stat)
+    }
+    sort.Slice(stats, func(i, j int) bool {
+        if stats[i].Changed != stats[j].Changed {
+            return stats[i].Changed > stats[j].Changed
+        }
+        return stats[i].Route < stats[j].Route
+    })
+    return stats
+}
+
+// count is used to count a request in the statistics
+func (r *Report) count(route string, ids []int, changed bool) {
+    r.add(changed)
+    if _, ok := r.routes[route]; !ok {
+        r.routes[route] = &RouteStat{Route: route}
+    }
+    r.routes[route].add(changed)
+    for _, id := range ids {
+        if _, ok := r.rules[id]; !ok {
+            r.rules[id] = &RuleStat{RuleID: id}
+        }
+        r.rules[id].add(changed)
+    }
+}
+
+// Replay is used to evaluate the requests of an access log against the old and the new controller
+func Replay(r io.Reader, old, new *grbac.Controller, opts Options) (*Report, error) {
+    report := &Report{
+        rules:  map[int]*RuleStat{},
+        routes: map[string]*RouteStat{},
+    }
+    scanner := bufio.NewScanner(r)
+    scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+    line := 0
+    for scanner.Scan() {
+        line++
+        text := strings.TrimSpace(scanner.Text())
+        if text == "" {
+            continue
+        }
+        entry, err := parse(opts.Format, text, &opts)
+        if err == ErrUnsupportedFormat {
+            return nil, err
+        }
+        if err != nil {
+            report.Skipped = append(report.Skipped, &Issue{Line: line, Text: text, Reason: err.Error()})
+            continue
+        }
+        entry.Line = line
+        before, err := old.Explain(entry.Query, entry.Roles)
+        if err != nil {
+            report.Skipped = append(report.Skipped, &Issue{Line: line, Text: text, Reason: err.Error()})
+            continue
+        }
+        after, err := new.Explain(entry.Query, entry.Roles)
+        if err != nil {
+            report.Skipped = append(report.Skipped, &Issue{Line: line, Text: text, Reason: err.Error()})
+            continue
+        }
+        change := &Change{Entry: entry, Old: before, New: after}
+        changed := before.State != after.State
+        if changed && (opts.Limit == 0 || len(report.Changes) < opts.Limit) {
+            report.Changes = append(report.Changes, change)
+        }
+        ids := []int{before.RuleID()}
+        if after.RuleID() != before.RuleID() {
+            ids = append(ids, after.RuleID())
+        }
+        report.count(opts.route(change), ids, changed)
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, err
+    }
+    return report, nil
+}
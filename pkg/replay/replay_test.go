@@ -0,0 +1,120 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+    "strings"
+    "testing"
+
+    "github.com/storyicon/grbac"
+    "github.com/storyicon/grbac/pkg/meta"
+    "github.com/stretchr/testify/assert"
+)
+
+var oldRules = meta.Rules{
+    {
+        ID:         0,
+        Resource:   &meta.Resource{Host: "*", Path: "**", Method: "*"},
+        Permission: &meta.Permission{AuthorizedRoles: []string{"*"}},
+    },
+    {
+        ID:         1,
+        Resource:   &meta.Resource{Host: "*", Path: "/articles/*", Method: "{DELETE,PUT}"},
+        Permission: &meta.Permission{AuthorizedRoles: []string{"editor"}},
+    },
+}
+
+var newRules = meta.Rules{
+    {
+        ID:         0,
+        Resource:   &meta.Resource{Host: "*", Path: "**", Method: "*"},
+        Permission: &meta.Permission{AuthorizedRoles: []string{"*"}},
+    },
+    {
+        ID:         1,
+        Resource:   &meta.Resource{Host: "*", Path: "/articles/*", Method: "{DELETE,PUT}"},
+        Permission: &meta.Permission{AuthorizedRoles: []string{"editor", "admin"}},
+    },
+    {
+        ID:         2,
+        Resource:   &meta.Resource{Host: "*", Path: "/login", Method: "POST"},
+        Permission: &meta.Permission{AllowAnyone: true},
+    },
+}
+
+const accessLog = `127.0.0.1 - admin [10/Oct/2000:13:55:36 -0700] "DELETE /articles/1?force=true HTTP/1.1" 403 12
+127.0.0.1 - editor [10/Oct/2000:13:55:37 -0700] "PUT /articles/1 HTTP/1.1" 200 12 "-" "curl/7.64.1"
+127.0.0.1 - - [10/Oct/2000:13:55:38 -0700] "POST http://other.com/login HTTP/1.1" 200 12
+{"host": "domain.com", "method": "delete", "path": "/articles/2", "roles": ["admin"]}
+{"host": "domain.com", "method": "GET", "path": "/articles/2", "roles": ["reader"]}
+not a log line
+`
+
+func TestReplay(t *testing.T) {
+    old, err := grbac.New(grbac.WithRules(oldRules))
+    assert.Equal(t, nil, err)
+    new, err := grbac.New(grbac.WithRules(newRules))
+    assert.Equal(t, nil, err)
+
+    report, err := Replay(strings.NewReader(accessLog), old, new, Options{Host: "domain.com"})
+    assert.Equal(t, nil, err)
+    assert.Equal(t, Stat{Total: 5, Changed: 3}, report.Stat)
+    assert.Equal(t, 1, len(report.Skipped))
+    assert.Equal(t, 6, report.Skipped[0].Line)
+
+    assert.Equal(t, 3, len(report.Changes))
+    change := report.Changes[0]
+    assert.Equal(t, 1, change.Line)
+    assert.Equal(t, &meta.Query{Host: "domain.com", Path: "/articles/1", Method: "DELETE"}, change.Query)
+    assert.Equal(t, []string{"admin"}, change.Roles)
+    assert.Equal(t, meta.PermissionUngranted, change.Old.State)
+    assert.Equal(t, meta.PermissionGranted, change.New.State)
+    assert.Equal(t, &meta.Query{Host: "other.com", Path: "/login", Method: "POST"}, report.Changes[1].Query)
+    assert.Equal(t, []string(nil), report.Changes[1].Roles)
+
+    assert.Equal(t, []*RuleStat{
+        {RuleID: 1, Stat: Stat{Total: 3, Changed: 2}},
+        {RuleID: 0, Stat: Stat{Total: 2, Changed: 1}},
+        {RuleID: 2, Stat: Stat{Total: 1, Changed: 1}},
+    }, report.Rules())
+    assert.Equal(t, []*RouteStat{
+        {Route: "{DELETE,PUT} * /articles/*", Stat: Stat{Total: 3, Changed: 2}},
+        {Route: "POST * /login", Stat: Stat{Total: 1, Changed: 1}},
+        {Route: "* * **", Stat: Stat{Total: 1, Changed: 0}},
+    }, report.Routes())
+
+    report, err = Replay(strings.NewReader(accessLog), old, new, Options{
+        Host: "domain.com",
+        Route: func(change *Change) string {
+            return change.Query.Method + " " + change.Query.Host + change.Query.Path
+        },
+    })
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []*RouteStat{
+        {Route: "DELETE domain.com/articles/1", Stat: Stat{Total: 1, Changed: 1}},
+        {Route: "DELETE domain.com/articles/2", Stat: Stat{Total: 1, Changed: 1}},
+        {Route: "POST other.com/login", Stat: Stat{Total: 1, Changed: 1}},
+        {Route: "GET domain.com/articles/2", Stat: Stat{Total: 1, Changed: 0}},
+        {Route: "PUT domain.com/articles/1", Stat: Stat{Total: 1, Changed: 0}},
+    }, report.Routes())
+
+    report, err = Replay(strings.NewReader(accessLog), old, new, Options{Format: FormatJSON, Limit: 1})
+    assert.Equal(t, nil, err)
+    assert.Equal(t, Stat{Total: 2, Changed: 1}, report.Stat)
+    assert.Equal(t, 4, len(report.Skipped))
+
+    _, err = Replay(strings.NewReader(accessLog), old, new, Options{Format: "xml"})
+    assert.Equal(t, ErrUnsupportedFormat, err)
+}
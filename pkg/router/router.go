@@ -0,0 +1,301 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package router keeps grbac rules in sync with the routes of a http.ServeMux.
+//
+// Routes are registered with their roles, and the rules are generated from them:
+//
+//  mux := router.NewMux()
+//  mux.HandleFunc("GET /articles/{id}", getArticle, "*")
+//  mux.HandleFunc("DELETE /articles/{id}", deleteArticle, "editor")
+//  rbac, err := grbac.New(grbac.WithRules(mux.Rules()))
+//
+// The patterns of the routes are converted into resources:
+//  {name}      becomes *
+//  {name...}   becomes **
+//  a trailing slash matches the whole subtree and becomes /**, unless it ends with {$}
+//  GET         also matches HEAD, like http.ServeMux does
+//
+// The methods and the wildcards are only supported by the http.ServeMux of Go 1.22+,
+// when the main module declares go 1.22 or later and GODEBUG does not set httpmuxgo121=1.
+// Otherwise the http.ServeMux treats them literally, and Mux refuses to register them.
+package router
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "net/url"
+    "strings"
+    "sync"
+
+    "github.com/storyicon/grbac/pkg/meta"
+    "github.com/storyicon/grbac/pkg/path"
+    "github.com/storyicon/grbac/pkg/probe"
+)
+
+// define a set of errors
+var (
+    ErrInvalidPattern     = errors.New("invalid pattern")
+    ErrUncovered          = errors.New("routes not covered by any rule")
+    ErrUnsupportedPattern = errors.New("the http.ServeMux does not support the methods and wildcards of Go 1.22")
+)
+
+// enhanced reports whether the http.ServeMux supports the methods and wildcards of Go 1.22
+var enhanced = func() bool {
+    mux := http.NewServeMux()
+    mux.Handle("GET /{name}", http.NotFoundHandler())
+    _, pattern := mux.Handler(&http.Request{Method: http.MethodGet, Host: "domain.com", URL: &url.URL{Path: "/grbac"}})
+    return pattern != ""
+}()
+
+// Route defines a registered route
+type Route struct {
+    // Pattern is the pattern registered to the http.ServeMux
+    Pattern string
+    // Method, Host and Path are the parts of the Pattern
+    Method string
+    Host   string
+    Path   string
+    // Resource is the grbac resource converted from the Pattern
+    Resource *meta.Resource
+    // Roles are the authorized roles of the route
+    Roles []string
+}
+
+// ParsePattern is used to convert a http.ServeMux pattern into a Route:
+//  [METHOD ][HOST]/[PATH]
+func ParsePattern(pattern string) (*Route, error) {
+    route := &Route{Pattern: pattern}
+    rest := strings.TrimLeft(pattern, " \t")
+    if index := strings.IndexAny(rest, " \t"); index >= 0 {
+        route.Method = rest[:index]
+        rest = strings.TrimLeft(rest[index:], " \t")
+    }
+    index := strings.Index(rest, "/")
+    if index < 0 {
+        return nil, fmt.Errorf("%s %q: missing path", ErrInvalidPattern, pattern)
+    }
+    route.Host, route.Path = rest[:index], rest[index:]
+    resourcePath, err := convertPath(route.Path)
+    if err != nil {
+        return nil, fmt.Errorf("%s %q: %s", ErrInvalidPattern, pattern, err)
+    }
+    route.Resource = &meta.Resource{
+        Host:   "*",
+        Path:   resourcePath,
+        Method: "*",
+    }
+    if route.Host != "" {
+        route.Resource.Host = escape(route.Host)
+    }
+    switch route.Method {
+    case "":
+    case http.MethodGet:
+        route.Resource.Method = "{GET,HEAD}"
+    default:
+        route.Resource.Method = escape(route.Method)
+    }
+    return route, nil
+}
+
+// convertPath is used to convert the path of a pattern into a resource path
+func convertPath(p string) (string, error) {
+    segments := strings.Split(p, "/")
+    for i, segment := range segments {
+        last := i == len(segments)-1
+        if !strings.HasPrefix(segment, "{") {
+            if strings.ContainsAny(segment, "{}") {
+                return "", errors.New("a wildcard must be a full path segment")
+            }
+            segments[i] = escape(segment)
+            continue
+        }
+        if !strings.HasSuffix(segment, "}") {
+            return "", errors.New("bad wildcard segment")
+        }
+        name := segment[1 : len(segment)-1]
+        switch {
+        case name == "$":
+            if !last {
+                return "", errors.New("{$} not at the end")
+            }
+            return strings.Join(segments[:i], "/") + "/", nil
+        case strings.HasSuffix(name, "..."):
+            if !last {
+                return "", errors.New("{...} wildcard not at the end")
+            }
+            segments[i] = "**"
+        default:
+            segments[i] = "*"
+        }
+    }
+    if segments[len(segments)-1] == "" {
+        segments[len(segments)-1] = "**"
+    }
+    return strings.Join(segments, "/"), nil
+}
+
+// escape is used to escape the wildcards of a literal
+func escape(s string) string {
+    var b strings.Builder
+    for _, c := range s {
+        switch c {
+        case '\\', '*', '?', '[', '{':
+            b.WriteRune('\\')
+        }
+        b.WriteRune(c)
+    }
+    return b.String()
+}
+
+// Mux wraps a http.ServeMux and records the routes registered to it
+type Mux struct {
+    *http.ServeMux
+
+    routes []*Route
+    lock   sync.RWMutex
+}
+
+// NewMux is used to initialize a Mux
+func NewMux() *Mux {
+    return &Mux{
+        ServeMux: http.NewServeMux(),
+    }
+}
+
+// Handle registers the handler for the given pattern with the authorized roles.
+// Like http.ServeMux, it panics when the pattern is invalid,
+// or when it has a method or a wildcard that the http.ServeMux would treat literally.
+func (m *Mux) Handle(pattern string, handler http.Handler, roles ...string) {
+    route, err := ParsePattern(pattern)
+    if err != nil {
+        panic(err)
+    }
+    if !enhanced && (route.Method != "" || strings.Contains(route.Path, "{")) {
+        panic(fmt.Errorf("%s: %q", ErrUnsupportedPattern, pattern))
+    }
+    route.Roles = roles
+    m.ServeMux.Handle(pattern, handler)
+    m.lock.Lock()
+    m.routes = append(m.routes, route)
+    m.lock.Unlock()
+}
+
+// HandleFunc registers the handler function for the given pattern with the authorized roles
+func (m *Mux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request), roles ...string) {
+    m.Handle(pattern, http.HandlerFunc(handler), roles...)
+}
+
+// Routes is used to return the registered routes
+func (m *Mux) Routes() []*Route {
+    m.lock.RLock()
+    defer m.lock.RUnlock()
+    return append([]*Route(nil), m.routes...)
+}
+
+// Rules is used to generate the rules of the routes registered with roles,
+// the rules of more specific routes have higher IDs.
+func (m *Mux) Rules() meta.Rules {
+    var routes []*Route
+    for _, route := range m.Routes() {
+        if len(route.Roles) > 0 {
+            routes = append(routes, route)
+        }
+    }
+    rules := meta.Rules{}
+    for _, route := range routes {
+        resource := *route.Resource
+        rules = append(rules, &meta.Rule{
+            Resource: &resource,
+            Permission: &meta.Permission{
+                AuthorizedRoles: append([]string(nil), route.Roles...),
+            },
+        })
+    }
+    rules.Prioritize(0, nil)
+    return rules
+}
+
+// Uncovered is used to find the routes that are not matched by any of the rules.
+// A route is covered when a rule matches a sample request of it,
+// the host and the method are not checked when the route does not define them.
+func (m *Mux) Uncovered(rules meta.Rules) ([]*Route, error) {
+    var uncovered []*Route
+    for _, route := range m.Routes() {
+        covered, err := isCovered(route, rules)
+        if err != nil {
+            return nil, err
+        }
+        if !covered {
+            uncovered = append(uncovered, route)
+        }
+    }
+    return uncovered, nil
+}
+
+// Check is used to ensure that every registered route is covered by the rules
+func (m *Mux) Check(rules meta.Rules) error {
+    uncovered, err := m.Uncovered(rules)
+    if err != nil {
+        return err
+    }
+    if len(uncovered) == 0 {
+        return nil
+    }
+    patterns := make([]string, 0, len(uncovered))
+    for _, route := range uncovered {
+        patterns = append(patterns, fmt.Sprintf("%q", route.Pattern))
+    }
+    return fmt.Errorf("%s: %s", ErrUncovered, strings.Join(patterns, ", "))
+}
+
+// isCovered is used to determine whether a route is matched by one of the rules
+func isCovered(route *Route, rules meta.Rules) (bool, error) {
+    sample, err := probe.Sample(route.Resource.Path)
+    if err != nil {
+        return false, err
+    }
+    for _, rule := range rules {
+        if rule.Resource == nil {
+            continue
+        }
+        patterns := [][2]string{{rule.Path, sample}}
+        if route.Host != "" {
+            patterns = append(patterns, [2]string{rule.Host, route.Host})
+        }
+        if route.Method != "" {
+            patterns = append(patterns, [2]string{rule.Method, route.Method})
+        }
+        covered, err := matchAll(patterns)
+        if err != nil {
+            return false, err
+        }
+        if covered {
+            return true, nil
+        }
+    }
+    return false, nil
+}
+
+// matchAll is used to test pairs of pattern and value
+func matchAll(patterns [][2]string) (bool, error) {
+    for _, pattern := range patterns {
+        matched, err := path.Match(pattern[0], pattern[1])
+        if err != nil || !matched {
+            return false, err
+        }
+    }
+    return true, nil
+}
@@ -0,0 +1,179 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package router
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/storyicon/grbac/pkg/meta"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestParsePattern(t *testing.T) {
+    tests := []struct {
+        name    string
+        pattern string
+        want    *meta.Resource
+        wantErr bool
+    }{
+        {name: "test0", pattern: "/articles", want: &meta.Resource{Host: "*", Path: "/articles", Method: "*"}},
+        {name: "test1", pattern: "/static/", want: &meta.Resource{Host: "*", Path: "/static/**", Method: "*"}},
+        {name: "test2", pattern: "/", want: &meta.Resource{Host: "*", Path: "/**", Method: "*"}},
+        {name: "test3", pattern: "GET /articles/{id}", want: &meta.Resource{Host: "*", Path: "/articles/*", Method: "{GET,HEAD}"}},
+        {name: "test4", pattern: "POST domain.com/articles/{id}/comments", want: &meta.Resource{Host: "domain.com", Path: "/articles/*/comments", Method: "POST"}},
+        {name: "test5", pattern: "/files/{path...}", want: &meta.Resource{Host: "*", Path: "/files/**", Method: "*"}},
+        {name: "test6", pattern: "DELETE /users/{$}", want: &meta.Resource{Host: "*", Path: "/users/", Method: "DELETE"}},
+        {name: "test7", pattern: "/a*b", want: &meta.Resource{Host: "*", Path: `/a\*b`, Method: "*"}},
+        {name: "test8", pattern: "/users/{id}.json", wantErr: true},
+        {name: "test9", pattern: "/{$}/users", wantErr: true},
+        {name: "test10", pattern: "/{path...}/users", wantErr: true},
+        {name: "test11", pattern: "domain.com", wantErr: true},
+    }
+    for _, tt := range tests {
+        route, err := ParsePattern(tt.pattern)
+        if (err != nil) != tt.wantErr {
+            t.Errorf("%q. ParsePattern() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+            continue
+        }
+        if err == nil {
+            assert.Equal(t, tt.want, route.Resource, tt.name)
+        }
+    }
+}
+
+func TestMux(t *testing.T) {
+    mux := NewMux()
+    handler := func(w http.ResponseWriter, r *http.Request) {
+        w.Write([]byte(r.URL.Path))
+    }
+    mux.HandleFunc("/articles/", handler, "*")
+    mux.HandleFunc("/articles/hot", handler, "editor", "admin")
+    mux.HandleFunc("/health", handler)
+
+    want := meta.Rules{
+        {
+            ID:         0,
+            Resource:   &meta.Resource{Host: "*", Path: "/articles/**", Method: "*"},
+            Permission: &meta.Permission{AuthorizedRoles: []string{"*"}},
+        },
+        {
+            ID:         1,
+            Resource:   &meta.Resource{Host: "*", Path: "/articles/hot", Method: "*"},
+            Permission: &meta.Permission{AuthorizedRoles: []string{"editor", "admin"}},
+        },
+    }
+    rules := mux.Rules()
+    assert.Equal(t, want.String(), rules.String())
+    assert.Equal(t, 3, len(mux.Routes()))
+
+    uncovered, err := mux.Uncovered(rules)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, 1, len(uncovered))
+    assert.Equal(t, "/health", uncovered[0].Pattern)
+    assert.Equal(t, `routes not covered by any rule: "/health"`, mux.Check(rules).Error())
+    assert.Equal(t, nil, mux.Check(append(rules, &meta.Rule{
+        Resource:   &meta.Resource{Host: "*", Path: "/health", Method: "GET"},
+        Permission: &meta.Permission{AllowAnyone: true},
+    })))
+
+    recorder := httptest.NewRecorder()
+    mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/articles/1", nil))
+    assert.Equal(t, "/articles/1", recorder.Body.String())
+}
+
+func TestMuxRouting(t *testing.T) {
+    mux := NewMux()
+    handler := func(name string) func(http.ResponseWriter, *http.Request) {
+        return func(w http.ResponseWriter, r *http.Request) {
+            w.Write([]byte(name))
+        }
+    }
+    mux.HandleFunc("/static/", handler("static"), "*")
+    mux.HandleFunc("domain.com/admin", handler("admin"), "admin")
+    requests := []struct {
+        method string
+        url    string
+        route  string
+    }{
+        {method: "GET", url: "http://domain.com/static/css/main.css", route: "static"},
+        {method: "POST", url: "http://domain.com/admin", route: "admin"},
+        {method: "POST", url: "http://other.com/admin", route: ""},
+    }
+    if enhanced {
+        mux.HandleFunc("GET /articles/{id}", handler("article"), "*")
+        mux.HandleFunc("DELETE /files/{path...}", handler("file"), "admin")
+        requests = append(requests, []struct {
+            method string
+            url    string
+            route  string
+        }{
+            {method: "GET", url: "http://domain.com/articles/1", route: "article"},
+            {method: "HEAD", url: "http://domain.com/articles/1", route: "article"},
+            {method: "DELETE", url: "http://domain.com/files/a/b.txt", route: "file"},
+        }...)
+    } else {
+        // the legacy http.ServeMux would answer 404 to /articles/1
+        assert.Panics(t, func() { mux.HandleFunc("GET /articles/{id}", handler("article"), "*") })
+        assert.Panics(t, func() { mux.HandleFunc("/articles/{id}", handler("article"), "*") })
+    }
+
+    // every routed request is matched by the rule of its route
+    rules := mux.Rules()
+    for _, request := range requests {
+        recorder := httptest.NewRecorder()
+        r := httptest.NewRequest(request.method, request.url, nil)
+        mux.ServeHTTP(recorder, r)
+        if request.route == "" {
+            assert.Equal(t, http.StatusNotFound, recorder.Code, request.url)
+            continue
+        }
+        if request.method != "HEAD" {
+            assert.Equal(t, request.route, recorder.Body.String(), request.url)
+        }
+        _, pattern := mux.Handler(r)
+        matched := false
+        for _, rule := range rules {
+            ok, err := rule.Match(&meta.Query{Host: r.Host, Path: r.URL.Path, Method: r.Method})
+            assert.Equal(t, nil, err)
+            route, _ := ParsePattern(pattern)
+            matched = matched || ok && *rule.Resource == *route.Resource
+        }
+        assert.True(t, matched, request.url)
+    }
+}
+
+func TestCheck(t *testing.T) {
+    // the route is recorded directly, since the legacy http.ServeMux can not register it
+    route, err := ParsePattern("GET domain.com/articles/{id}")
+    assert.Equal(t, nil, err)
+    mux := &Mux{ServeMux: http.NewServeMux(), routes: []*Route{route}}
+    tests := []struct {
+        name    string
+        rule    *meta.Resource
+        covered bool
+    }{
+        {name: "test0", rule: &meta.Resource{Host: "*", Path: "/articles/*", Method: "GET"}, covered: true},
+        {name: "test1", rule: &meta.Resource{Host: "*", Path: "/articles/*", Method: "POST"}},
+        {name: "test2", rule: &meta.Resource{Host: "other.com", Path: "**", Method: "*"}},
+        {name: "test3", rule: &meta.Resource{Host: "domain.com", Path: "/articles/**", Method: "*"}, covered: true},
+        {name: "test4", rule: &meta.Resource{Host: "*", Path: "/articles", Method: "*"}},
+    }
+    for _, tt := range tests {
+        err := mux.Check(meta.Rules{{Resource: tt.rule, Permission: &meta.Permission{AllowAnyone: true}}})
+        assert.Equal(t, tt.covered, err == nil, tt.name)
+    }
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+    "net/http"
+    "strings"
+
+    "github.com/storyicon/grbac"
+    "github.com/storyicon/grbac/pkg/extractor"
+    "github.com/storyicon/grbac/pkg/meta"
+)
+
+// HeaderEnvoyHeadersToRemove lists the headers Envoy removes from the upstream request when it is allowed
+const HeaderEnvoyHeadersToRemove = "X-Envoy-Auth-Headers-To-Remove"
+
+// ExtAuthzOptions defines the options of the Envoy external authorization handler
+type ExtAuthzOptions struct {
+    // Extractor extracts the roles of the check request, which carries the headers of the original request
+    Extractor extractor.RoleExtractor
+    // AllowNeglected is used to grant the requests matching no rule, which are denied by default
+    AllowNeglected bool
+    // PathPrefix is the path_prefix of the http_service, which is removed from the path of the check requests
+    PathPrefix string
+    // HeadersToRemove are removed from the upstream request when it is allowed,
+    // such as the credentials which should not reach the services
+    HeadersToRemove []string
+}
+
+// ExtAuthz implements the HTTP service of the Envoy external authorization filter.
+// Envoy forwards a check request with the method, path and headers of the original request,
+// a 200 response allows it and the headers listed in allowed_upstream_headers are added to the upstream request,
+// any other response denies it and is returned to the client with the headers listed in allowed_client_headers.
+// The decision is written in the X-Grbac-* headers in both cases.
+type ExtAuthz struct {
+    gate
+    prefix string
+    remove string
+}
+
+// NewExtAuthz is used to create an Envoy external authorization handler
+func NewExtAuthz(controller *grbac.Controller, opts ExtAuthzOptions) *ExtAuthz {
+    return &ExtAuthz{
+        gate: gate{
+            controller:     controller,
+            extractor:      opts.Extractor,
+            allowNeglected: opts.AllowNeglected,
+        },
+        prefix: strings.TrimSuffix(opts.PathPrefix, "/"),
+        remove: strings.ToLower(strings.Join(opts.HeadersToRemove, ",")),
+    }
+}
+
+// ServeHTTP is used to answer a check request
+func (e *ExtAuthz) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    path := r.URL.Path
+    if e.prefix != "" {
+        if !strings.HasPrefix(path, e.prefix+"/") {
+            http.NotFound(w, r)
+            return
+        }
+        path = strings.TrimPrefix(path, e.prefix)
+    }
+    status := e.check(w, r, &meta.Query{
+        Host:   r.Host,
+        Path:   path,
+        Method: r.Method,
+    })
+    if status == http.StatusOK && e.remove != "" {
+        w.Header().Set(HeaderEnvoyHeadersToRemove, e.remove)
+    }
+    reply(w, status)
+}
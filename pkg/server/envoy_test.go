@@ -0,0 +1,61 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+    "net/http/httptest"
+    "testing"
+
+    "github.com/storyicon/grbac"
+    "github.com/storyicon/grbac/pkg/extractor"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestExtAuthz(t *testing.T) {
+    c, err := grbac.New(grbac.WithRules(rules))
+    assert.Equal(t, nil, err)
+    e := NewExtAuthz(c, ExtAuthzOptions{
+        Extractor:       extractor.Header("X-Roles"),
+        PathPrefix:      "/ext_authz/",
+        HeadersToRemove: []string{"X-Roles", "Authorization"},
+    })
+
+    tests := []struct {
+        name     string
+        method   string
+        target   string
+        roles    string
+        status   int
+        decision string
+        remove   string
+    }{
+        {name: "test0", method: "PUT", target: "http://domain.com/ext_authz/article/1?draft=true", roles: "editor", status: 200, decision: "granted", remove: "x-roles,authorization"},
+        {name: "test1", method: "DELETE", target: "http://domain.com/ext_authz/article/1", roles: "reader", status: 403, decision: "ungranted"},
+        {name: "test2", method: "DELETE", target: "http://domain.com/ext_authz/article/1", status: 401, decision: "ungranted"},
+        {name: "test3", method: "GET", target: "http://domain.com/ext_authz/", roles: "reader", status: 200, decision: "granted", remove: "x-roles,authorization"},
+        {name: "test4", method: "GET", target: "http://domain.com/article/1", roles: "reader", status: 404},
+    }
+    for _, tt := range tests {
+        r := httptest.NewRequest(tt.method, tt.target, nil)
+        if tt.roles != "" {
+            r.Header.Set("X-Roles", tt.roles)
+        }
+        recorder := httptest.NewRecorder()
+        e.ServeHTTP(recorder, r)
+        assert.Equal(t, tt.status, recorder.Code, tt.name)
+        assert.Equal(t, tt.decision, recorder.Header().Get(HeaderDecision), tt.name)
+        assert.Equal(t, tt.remove, recorder.Header().Get(HeaderEnvoyHeadersToRemove), tt.name)
+    }
+}
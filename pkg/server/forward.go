@@ -0,0 +1,181 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+    "net/http"
+    "net/url"
+    "path"
+    "strconv"
+    "strings"
+
+    "github.com/storyicon/grbac"
+    "github.com/storyicon/grbac/pkg/audit"
+    "github.com/storyicon/grbac/pkg/extractor"
+    "github.com/storyicon/grbac/pkg/meta"
+)
+
+// define the headers of the original request set by the proxies
+const (
+    HeaderOriginalURI     = "X-Original-URI"
+    HeaderOriginalMethod  = "X-Original-Method"
+    HeaderOriginalHost    = "X-Original-Host"
+    HeaderForwardedMethod = "X-Forwarded-Method"
+    HeaderForwardedHost   = "X-Forwarded-Host"
+    HeaderForwardedURI    = "X-Forwarded-Uri"
+)
+
+// Proxy defines the proxy sending the subrequests, which sets the headers of the original request
+type Proxy string
+
+// define the supported proxies
+const (
+    // ProxyNginx reads X-Original-Method, X-Original-Host and X-Original-URI,
+    // which must be set by proxy_set_header so that the values of the client are replaced
+    ProxyNginx Proxy = "nginx"
+    // ProxyTraefik reads X-Forwarded-Method, X-Forwarded-Host and X-Forwarded-Uri,
+    // which Traefik always overwrites
+    ProxyTraefik Proxy = "traefik"
+)
+
+// proxyHeaders defines the method, host and uri headers of the proxies
+var proxyHeaders = map[Proxy][3]string{
+    ProxyNginx:   {HeaderOriginalMethod, HeaderOriginalHost, HeaderOriginalURI},
+    ProxyTraefik: {HeaderForwardedMethod, HeaderForwardedHost, HeaderForwardedURI},
+}
+
+// define the headers of the decision
+const (
+    HeaderDecision = "X-Grbac-Decision"
+    HeaderRuleID   = "X-Grbac-Rule-Id"
+    HeaderRoles    = "X-Grbac-Roles"
+)
+
+// ForwardAuthOptions defines the options of the forward auth handler
+type ForwardAuthOptions struct {
+    // Proxy is the proxy sending the subrequests, only its headers of the original request are read
+    Proxy Proxy
+    // Extractor extracts the roles of the original request, whose headers are forwarded by the proxy
+    Extractor extractor.RoleExtractor
+    // AllowNeglected is used to grant the requests matching no rule, which are denied by default
+    AllowNeglected bool
+}
+
+// gate decides the original requests of a proxy
+type gate struct {
+    controller     *grbac.Controller
+    extractor      extractor.RoleExtractor
+    allowNeglected bool
+}
+
+// check is used to decide the original request, the decision is written in the X-Grbac-* headers.
+// It returns 200 when the request is granted, 401 when the visitor is not authenticated
+// and 403 when the roles of the visitor are not granted.
+func (g *gate) check(w http.ResponseWriter, r *http.Request, q *meta.Query) int {
+    var roles []string
+    if g.extractor != nil {
+        var err error
+        roles, err = g.extractor.Extract(r)
+        if err != nil {
+            w.Header().Set(HeaderDecision, audit.OutcomeUnknown)
+            return http.StatusUnauthorized
+        }
+    }
+    decision, err := g.controller.Decide(r.Context(), q, roles)
+    if err != nil {
+        return http.StatusInternalServerError
+    }
+    w.Header().Set(HeaderDecision, audit.Outcome(decision.State))
+    w.Header().Set(HeaderRuleID, strconv.Itoa(decision.RuleID()))
+    w.Header().Set(HeaderRoles, strings.Join(roles, ","))
+    switch {
+    case decision.State.IsGranted(), decision.State.IsNeglected() && g.allowNeglected:
+        return http.StatusOK
+    case len(roles) == 0:
+        return http.StatusUnauthorized
+    }
+    return http.StatusForbidden
+}
+
+// reply is used to write the status of a check
+func reply(w http.ResponseWriter, status int) {
+    if status == http.StatusOK {
+        w.WriteHeader(status)
+        return
+    }
+    http.Error(w, http.StatusText(status), status)
+}
+
+// ForwardAuth answers the authentication subrequests of nginx auth_request and Traefik forwardAuth
+type ForwardAuth struct {
+    gate
+    headers [3]string
+}
+
+// NewForwardAuth is used to create a forward auth handler.
+// The original request is read from the headers of the proxy only, a subrequest missing one of them is rejected with 400.
+// It answers 200 when the request is granted, 401 when the visitor is not authenticated
+// and 403 when the roles of the visitor are not granted, with the decision in the X-Grbac-* headers.
+func NewForwardAuth(controller *grbac.Controller, opts ForwardAuthOptions) (*ForwardAuth, error) {
+    headers, ok := proxyHeaders[opts.Proxy]
+    if !ok {
+        return nil, ErrUnknownProxy
+    }
+    return &ForwardAuth{
+        gate: gate{
+            controller:     controller,
+            extractor:      opts.Extractor,
+            allowNeglected: opts.AllowNeglected,
+        },
+        headers: headers,
+    }, nil
+}
+
+// original is used to get the original request of a subrequest from the method, host and uri headers,
+// the path is cleaned so that /public/../admin is decided as /admin, which the upstream serves.
+func original(r *http.Request, headers [3]string) (*meta.Query, error) {
+    method, host, uri := r.Header.Get(headers[0]), r.Header.Get(headers[1]), r.Header.Get(headers[2])
+    if method == "" || host == "" || uri == "" {
+        return nil, ErrMissingOriginal
+    }
+    u, err := url.ParseRequestURI(uri)
+    if err != nil {
+        return nil, err
+    }
+    return &meta.Query{
+        Host:   host,
+        Path:   cleanPath(u.Path),
+        Method: strings.ToUpper(method),
+    }, nil
+}
+
+// cleanPath is used to resolve the . and .. elements of a path, keeping its trailing slash
+func cleanPath(p string) string {
+    cleaned := path.Clean("/" + p)
+    if strings.HasSuffix(p, "/") && cleaned != "/" {
+        cleaned += "/"
+    }
+    return cleaned
+}
+
+// ServeHTTP is used to answer a subrequest
+func (f *ForwardAuth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    q, err := original(r, f.headers)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    reply(w, f.check(w, r, q))
+}
@@ -0,0 +1,179 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/storyicon/grbac"
+    "github.com/storyicon/grbac/pkg/extractor"
+    "github.com/storyicon/grbac/pkg/meta"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestForwardAuth(t *testing.T) {
+    c, err := grbac.New(grbac.WithRules(rules[1:]))
+    assert.Equal(t, nil, err)
+    f, err := NewForwardAuth(c, ForwardAuthOptions{
+        Proxy: ProxyNginx,
+        Extractor: extractor.Func(func(r *http.Request) ([]string, error) {
+            if r.Header.Get("Authorization") == "invalid" {
+                return nil, extractor.ErrUnauthenticated
+            }
+            return extractor.Header("X-Roles").Extract(r)
+        }),
+    })
+    assert.Equal(t, nil, err)
+
+    tests := []struct {
+        name     string
+        headers  map[string]string
+        status   int
+        decision string
+        ruleID   string
+    }{
+        {
+            name:     "test0",
+            headers:  map[string]string{"X-Original-Method": "PUT", "X-Original-Host": "domain.com", "X-Original-URI": "/article/1?draft=true", "X-Roles": "editor"},
+            status:   200,
+            decision: "granted",
+            ruleID:   "1",
+        },
+        {
+            name:     "test1",
+            headers:  map[string]string{"X-Original-Method": "DELETE", "X-Original-Host": "domain.com", "X-Original-URI": "/article/1", "X-Roles": "reader"},
+            status:   403,
+            decision: "ungranted",
+            ruleID:   "1",
+        },
+        {
+            name:     "test2",
+            headers:  map[string]string{"X-Original-Method": "DELETE", "X-Original-Host": "domain.com", "X-Original-URI": "/article/1"},
+            status:   401,
+            decision: "ungranted",
+            ruleID:   "1",
+        },
+        {
+            name:     "test3",
+            headers:  map[string]string{"X-Original-Method": "DELETE", "X-Original-Host": "domain.com", "X-Original-URI": "/article/1", "Authorization": "invalid"},
+            status:   401,
+            decision: "unknown",
+        },
+        {
+            name:     "test4",
+            headers:  map[string]string{"X-Original-Method": "GET", "X-Original-Host": "domain.com", "X-Original-URI": "/index.html", "X-Roles": "editor"},
+            status:   403,
+            decision: "neglected",
+            ruleID:   "-1",
+        },
+    }
+    for _, tt := range tests {
+        r := httptest.NewRequest("GET", "http://auth.local/auth", nil)
+        for key, value := range tt.headers {
+            r.Header.Set(key, value)
+        }
+        recorder := httptest.NewRecorder()
+        f.ServeHTTP(recorder, r)
+        assert.Equal(t, tt.status, recorder.Code, tt.name)
+        assert.Equal(t, tt.decision, recorder.Header().Get(HeaderDecision), tt.name)
+        assert.Equal(t, tt.ruleID, recorder.Header().Get(HeaderRuleID), tt.name)
+    }
+
+    f.allowNeglected = true
+    r := httptest.NewRequest("GET", "http://auth.local/auth", nil)
+    r.Header.Set("X-Original-Method", "GET")
+    r.Header.Set("X-Original-Host", "domain.com")
+    r.Header.Set("X-Original-URI", "/index.html")
+    recorder := httptest.NewRecorder()
+    f.ServeHTTP(recorder, r)
+    assert.Equal(t, 200, recorder.Code)
+
+    // the subrequest itself is never decided
+    r = httptest.NewRequest("GET", "http://domain.com/index.html", nil)
+    recorder = httptest.NewRecorder()
+    f.ServeHTTP(recorder, r)
+    assert.Equal(t, 400, recorder.Code)
+
+    _, err = NewForwardAuth(c, ForwardAuthOptions{})
+    assert.Equal(t, ErrUnknownProxy, err)
+}
+
+func TestOriginal(t *testing.T) {
+    tests := []struct {
+        name    string
+        proxy   Proxy
+        headers map[string]string
+        want    *meta.Query
+        err     bool
+    }{
+        {
+            name:    "test0",
+            proxy:   ProxyTraefik,
+            headers: map[string]string{"X-Forwarded-Method": "post", "X-Forwarded-Host": "domain.com", "X-Forwarded-Uri": "/article?id=1"},
+            want:    &meta.Query{Host: "domain.com", Path: "/article", Method: "POST"},
+        },
+        {
+            name:    "test1",
+            proxy:   ProxyTraefik,
+            headers: map[string]string{"X-Forwarded-Method": "post", "X-Forwarded-Host": "domain.com", "X-Forwarded-Uri": "article"},
+            err:     true,
+        },
+        {
+            // the X-Forwarded-* headers sent by the client are ignored behind nginx
+            name:    "test2",
+            proxy:   ProxyNginx,
+            headers: map[string]string{
+                "X-Original-Method": "GET", "X-Original-Host": "domain.com", "X-Original-URI": "/admin",
+                "X-Forwarded-Method": "GET", "X-Forwarded-Host": "public.com", "X-Forwarded-Uri": "/public",
+            },
+            want: &meta.Query{Host: "domain.com", Path: "/admin", Method: "GET"},
+        },
+        {
+            name:    "test3",
+            proxy:   ProxyNginx,
+            headers: map[string]string{"X-Forwarded-Method": "GET", "X-Forwarded-Host": "domain.com", "X-Forwarded-Uri": "/public"},
+            err:     true,
+        },
+        {
+            name:    "test4",
+            proxy:   ProxyTraefik,
+            headers: map[string]string{"X-Original-Method": "GET", "X-Original-URI": "/public", "X-Forwarded-Method": "GET", "X-Forwarded-Host": "domain.com", "X-Forwarded-Uri": "/admin"},
+            want:    &meta.Query{Host: "domain.com", Path: "/admin", Method: "GET"},
+        },
+        {
+            name:    "test5",
+            proxy:   ProxyNginx,
+            headers: map[string]string{"X-Original-Method": "GET", "X-Original-Host": "domain.com", "X-Original-URI": "/public/../admin"},
+            want:    &meta.Query{Host: "domain.com", Path: "/admin", Method: "GET"},
+        },
+        {
+            name:    "test6",
+            proxy:   ProxyNginx,
+            headers: map[string]string{"X-Original-Method": "GET", "X-Original-Host": "domain.com", "X-Original-URI": "/public/%2e%2e/admin/./users/"},
+            want:    &meta.Query{Host: "domain.com", Path: "/admin/users/", Method: "GET"},
+        },
+    }
+    for _, tt := range tests {
+        r := httptest.NewRequest("GET", "http://auth.local/auth", nil)
+        for key, value := range tt.headers {
+            r.Header.Set(key, value)
+        }
+        q, err := original(r, proxyHeaders[tt.proxy])
+        assert.Equal(t, tt.err, err != nil, tt.name)
+        assert.Equal(t, tt.want, q, tt.name)
+    }
+}
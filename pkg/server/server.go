@@ -0,0 +1,351 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package server exposes a controller as a policy decision point over a JSON API,
+// so that services written in any language can ask grbac for decisions:
+//
+//  POST /v1/decide         {"host": "domain.com", "path": "/article/1", "method": "DELETE", "roles": ["editor"]}
+//  POST /v1/decide/batch   {"roles": ["editor"], "queries": [{"host": ..., "path": ..., "method": ...}]}
+//  POST /v1/explain        like /v1/decide, with the decisive rule and the matched rules
+//  GET  /v1/policy         the rules in effect and their version
+//  GET  /healthz           the loading status of the rules
+package server
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "net/http"
+    "strings"
+    "time"
+
+    jsoniter "github.com/json-iterator/go"
+    "github.com/storyicon/grbac"
+    "github.com/storyicon/grbac/pkg/audit"
+    "github.com/storyicon/grbac/pkg/meta"
+)
+
+// define a set of errors
+var (
+    ErrMissingField     = errors.New("host, path and method are required")
+    ErrBatchTooLarge    = errors.New("too many queries in the batch")
+    ErrBodyTooLarge     = errors.New("request body too large")
+    ErrMethodNotAllowed = errors.New("method not allowed")
+    ErrUnknownProxy     = errors.New("unknown proxy, nginx or traefik is expected")
+    ErrMissingOriginal  = errors.New("missing header of the original request")
+)
+
+// define the default limits
+const (
+    DefaultMaxBodyBytes = 1 << 20
+    DefaultMaxBatch     = 100
+)
+
+// Options defines the options of the handler
+type Options struct {
+    // MaxBodyBytes is the maximum size of a request body, DefaultMaxBodyBytes by default
+    MaxBodyBytes int64
+    // MaxBatch is the maximum number of queries of a batch, DefaultMaxBatch by default
+    MaxBatch int
+}
+
+// Query defines a query of the API
+type Query struct {
+    Host   string `json:"host"`
+    Path   string `json:"path"`
+    Method string `json:"method"`
+}
+
+// DecideRequest defines the body of /v1/decide and /v1/explain
+type DecideRequest struct {
+    Query
+    Roles []string `json:"roles"`
+}
+
+// BatchRequest defines the body of /v1/decide/batch
+type BatchRequest struct {
+    Roles   []string `json:"roles"`
+    Queries []*Query `json:"queries"`
+}
+
+// Decision defines a decision of the API
+type Decision struct {
+    // State is the outcome of the decision, such as granted or ungranted
+    State   string `json:"state"`
+    Granted bool   `json:"granted"`
+}
+
+// BatchResponse defines the response of /v1/decide/batch
+type BatchResponse struct {
+    Decisions []*Decision `json:"decisions"`
+}
+
+// Explanation defines the response of /v1/explain
+type Explanation struct {
+    Decision
+    // RuleID is the ID of the decisive rule, -1 when no rule matches
+    RuleID  int        `json:"rule_id"`
+    Rule    *meta.Rule `json:"rule"`
+    Matched meta.Rules `json:"matched"`
+    Version string     `json:"version"`
+}
+
+// Policy defines the response of /v1/policy
+type Policy struct {
+    Version string     `json:"version"`
+    Rules   meta.Rules `json:"rules"`
+}
+
+// Health defines the response of /healthz
+type Health struct {
+    // Status is ok, or degraded when the last loading failed and the previous rules are still in effect
+    Status      string    `json:"status"`
+    Version     string    `json:"version"`
+    Rules       int       `json:"rules"`
+    LastAttempt time.Time `json:"last_attempt"`
+    LastSuccess time.Time `json:"last_success"`
+    LastError   string    `json:"last_error,omitempty"`
+}
+
+// errorResponse defines the body of a failed request
+type errorResponse struct {
+    Error string `json:"error"`
+}
+
+// Handler serves the API of a controller
+type Handler struct {
+    controller *grbac.Controller
+    opts       Options
+    mux        *http.ServeMux
+}
+
+// New is used to create the handler of the API of the controller
+func New(controller *grbac.Controller, opts Options) *Handler {
+    if opts.MaxBodyBytes <= 0 {
+        opts.MaxBodyBytes = DefaultMaxBodyBytes
+    }
+    if opts.MaxBatch <= 0 {
+        opts.MaxBatch = DefaultMaxBatch
+    }
+    h := &Handler{
+        controller: controller,
+        opts:       opts,
+        mux:        http.NewServeMux(),
+    }
+    h.mux.HandleFunc("/v1/decide", h.post(h.decide))
+    h.mux.HandleFunc("/v1/decide/batch", h.post(h.batch))
+    h.mux.HandleFunc("/v1/explain", h.post(h.explain))
+    h.mux.HandleFunc("/v1/policy", h.get(h.policy))
+    h.mux.HandleFunc("/healthz", h.get(h.health))
+    return h
+}
+
+// ServeHTTP is used to serve the API
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    h.mux.ServeHTTP(w, r)
+}
+
+// endpoint defines the handler of an endpoint, which returns the response or an error with its status code
+type endpoint func(r *http.Request) (interface{}, int, error)
+
+// post is used to accept only the POST requests
+func (h *Handler) post(fn endpoint) http.HandlerFunc {
+    return h.serve(http.MethodPost, fn)
+}
+
+// get is used to accept only the GET and HEAD requests
+func (h *Handler) get(fn endpoint) http.HandlerFunc {
+    return h.serve(http.MethodGet, fn)
+}
+
+// serve is used to check the method, limit the body and write the response of an endpoint
+func (h *Handler) serve(method string, fn endpoint) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != method && !(method == http.MethodGet && r.Method == http.MethodHead) {
+            w.Header().Set("Allow", method)
+            write(w, http.StatusMethodNotAllowed, &errorResponse{Error: ErrMethodNotAllowed.Error()})
+            return
+        }
+        r.Body = &limitedBody{ReadCloser: r.Body, remaining: h.opts.MaxBodyBytes}
+        response, status, err := fn(r)
+        if err != nil {
+            write(w, status, &errorResponse{Error: err.Error()})
+            return
+        }
+        write(w, status, response)
+    }
+}
+
+// write is used to write a json response
+func write(w http.ResponseWriter, status int, response interface{}) {
+    data, err := jsoniter.Marshal(response)
+    if err != nil {
+        status = http.StatusInternalServerError
+        data = []byte(fmt.Sprintf(`{"error":%q}`, err.Error()))
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    w.Write(append(data, '\n'))
+}
+
+// limitedBody defines a request body returning ErrBodyTooLarge when it exceeds the remaining bytes
+type limitedBody struct {
+    io.ReadCloser
+    remaining int64
+}
+
+// Read is used to read the body, a byte beyond the limit is read to tell a body of the exact limit apart
+func (b *limitedBody) Read(p []byte) (int, error) {
+    if len(p) == 0 {
+        return 0, nil
+    }
+    if b.remaining <= 0 {
+        var extra [1]byte
+        n, err := b.ReadCloser.Read(extra[:])
+        if n > 0 {
+            return 0, ErrBodyTooLarge
+        }
+        return 0, err
+    }
+    if int64(len(p)) > b.remaining {
+        p = p[:b.remaining]
+    }
+    n, err := b.ReadCloser.Read(p)
+    b.remaining -= int64(n)
+    return n, err
+}
+
+// decode is used to decode the json body of a request
+func decode(r *http.Request, v interface{}) (int, error) {
+    data, err := ioutil.ReadAll(r.Body)
+    if err == ErrBodyTooLarge {
+        return http.StatusRequestEntityTooLarge, err
+    }
+    if err != nil {
+        return http.StatusBadRequest, err
+    }
+    if err := jsoniter.Unmarshal(data, v); err != nil {
+        return http.StatusBadRequest, err
+    }
+    return http.StatusOK, nil
+}
+
+// query is used to validate a query of the API
+func (q *Query) query() (*meta.Query, error) {
+    if q == nil || q.Host == "" || q.Path == "" || q.Method == "" {
+        return nil, ErrMissingField
+    }
+    return &meta.Query{Host: q.Host, Path: q.Path, Method: strings.ToUpper(q.Method)}, nil
+}
+
+// newDecision is used to create the decision of a state
+func newDecision(state meta.PermissionState) Decision {
+    return Decision{State: audit.Outcome(state), Granted: state.IsGranted()}
+}
+
+// decide is used to serve /v1/decide
+func (h *Handler) decide(r *http.Request) (interface{}, int, error) {
+    var req DecideRequest
+    if status, err := decode(r, &req); err != nil {
+        return nil, status, err
+    }
+    q, err := req.query()
+    if err != nil {
+        return nil, http.StatusBadRequest, err
+    }
+    state, err := h.controller.IsQueryGrantedContext(r.Context(), q, req.Roles)
+    if err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+    decision := newDecision(state)
+    return &decision, http.StatusOK, nil
+}
+
+// batch is used to serve /v1/decide/batch
+func (h *Handler) batch(r *http.Request) (interface{}, int, error) {
+    var req BatchRequest
+    if status, err := decode(r, &req); err != nil {
+        return nil, status, err
+    }
+    if len(req.Queries) > h.opts.MaxBatch {
+        return nil, http.StatusRequestEntityTooLarge, ErrBatchTooLarge
+    }
+    queries := make([]*meta.Query, 0, len(req.Queries))
+    for _, query := range req.Queries {
+        q, err := query.query()
+        if err != nil {
+            return nil, http.StatusBadRequest, err
+        }
+        queries = append(queries, q)
+    }
+    states, err := h.controller.IsQueriesGrantedContext(r.Context(), queries, req.Roles)
+    if err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+    response := &BatchResponse{Decisions: make([]*Decision, 0, len(states))}
+    for _, state := range states {
+        decision := newDecision(state)
+        response.Decisions = append(response.Decisions, &decision)
+    }
+    return response, http.StatusOK, nil
+}
+
+// explain is used to serve /v1/explain
+func (h *Handler) explain(r *http.Request) (interface{}, int, error) {
+    var req DecideRequest
+    if status, err := decode(r, &req); err != nil {
+        return nil, status, err
+    }
+    q, err := req.query()
+    if err != nil {
+        return nil, http.StatusBadRequest, err
+    }
+    decision, err := h.controller.Explain(q, req.Roles)
+    if err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+    return &Explanation{
+        Decision: newDecision(decision.State),
+        RuleID:   decision.RuleID(),
+        Rule:     decision.Rule,
+        Matched:  decision.Matched,
+        Version:  h.controller.Version(),
+    }, http.StatusOK, nil
+}
+
+// policy is used to serve /v1/policy
+func (h *Handler) policy(r *http.Request) (interface{}, int, error) {
+    return &Policy{
+        Version: h.controller.Version(),
+        Rules:   h.controller.Rules(),
+    }, http.StatusOK, nil
+}
+
+// health is used to serve /healthz
+func (h *Handler) health(r *http.Request) (interface{}, int, error) {
+    status := h.controller.Status()
+    health := &Health{
+        Status:      "ok",
+        Version:     status.Version,
+        Rules:       status.Rules,
+        LastAttempt: status.LastAttempt,
+        LastSuccess: status.LastSuccess,
+    }
+    if status.LastError != nil {
+        health.Status = "degraded"
+        health.LastError = status.LastError.Error()
+    }
+    return health, http.StatusOK, nil
+}
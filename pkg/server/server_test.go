@@ -0,0 +1,137 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    jsoniter "github.com/json-iterator/go"
+    "github.com/storyicon/grbac"
+    "github.com/storyicon/grbac/pkg/meta"
+    "github.com/stretchr/testify/assert"
+)
+
+var rules = meta.Rules{
+    {
+        ID:         0,
+        Resource:   &meta.Resource{Host: "*", Path: "**", Method: "*"},
+        Permission: &meta.Permission{AuthorizedRoles: []string{"*"}},
+    },
+    {
+        ID:         1,
+        Resource:   &meta.Resource{Host: "*", Path: "/article/*", Method: "{DELETE,PUT}"},
+        Permission: &meta.Permission{AuthorizedRoles: []string{"editor"}},
+    },
+}
+
+func newHandler(t *testing.T) *Handler {
+    c, err := grbac.New(grbac.WithRules(rules))
+    assert.Equal(t, nil, err)
+    return New(c, Options{MaxBodyBytes: 512, MaxBatch: 2})
+}
+
+func serve(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+    recorder := httptest.NewRecorder()
+    h.ServeHTTP(recorder, httptest.NewRequest(method, target, strings.NewReader(body)))
+    return recorder
+}
+
+func TestHandler_Decide(t *testing.T) {
+    h := newHandler(t)
+    body := `{"host": "domain.com", "path": "/article/1", "method": "delete", "roles": ["editor"]}`
+    limit := body + strings.Repeat(" ", 512-len(body))
+    tests := []struct {
+        name   string
+        method string
+        body   string
+        status int
+        want   string
+    }{
+        {name: "test0", method: "POST", body: `{"host": "domain.com", "path": "/article/1", "method": "delete", "roles": ["editor"]}`, status: 200, want: `{"state":"granted","granted":true}`},
+        {name: "test1", method: "POST", body: `{"host": "domain.com", "path": "/article/1", "method": "DELETE", "roles": ["reader"]}`, status: 200, want: `{"state":"ungranted","granted":false}`},
+        {name: "test2", method: "POST", body: `{"host": "domain.com", "path": "/article/1"}`, status: 400, want: `{"error":"host, path and method are required"}`},
+        {name: "test3", method: "POST", body: `{"host": `, status: 400},
+        {name: "test4", method: "POST", body: `{"host": "` + strings.Repeat("x", 1024) + `"}`, status: 413, want: `{"error":"request body too large"}`},
+        {name: "test5", method: "GET", status: 405, want: `{"error":"method not allowed"}`},
+        {name: "test6", method: "POST", body: limit, status: 200, want: `{"state":"granted","granted":true}`},
+        {name: "test7", method: "POST", body: limit + " ", status: 413, want: `{"error":"request body too large"}`},
+    }
+    for _, tt := range tests {
+        recorder := serve(h, tt.method, "/v1/decide", tt.body)
+        assert.Equal(t, tt.status, recorder.Code, tt.name)
+        assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"), tt.name)
+        if tt.want != "" {
+            assert.Equal(t, tt.want+"\n", recorder.Body.String(), tt.name)
+        }
+    }
+}
+
+func TestHandler_Batch(t *testing.T) {
+    h := newHandler(t)
+    recorder := serve(h, "POST", "/v1/decide/batch", `{"roles": ["editor"], "queries": [
+        {"host": "domain.com", "path": "/article/1", "method": "PUT"},
+        {"host": "domain.com", "path": "/", "method": "GET"}
+    ]}`)
+    assert.Equal(t, 200, recorder.Code)
+    assert.Equal(t, `{"decisions":[{"state":"granted","granted":true},{"state":"granted","granted":true}]}`+"\n", recorder.Body.String())
+
+    recorder = serve(h, "POST", "/v1/decide/batch", `{"roles": [], "queries": [{}, {}, {}]}`)
+    assert.Equal(t, 413, recorder.Code)
+    recorder = serve(h, "POST", "/v1/decide/batch", `{"roles": [], "queries": [{}]}`)
+    assert.Equal(t, 400, recorder.Code)
+}
+
+func TestHandler_Explain(t *testing.T) {
+    h := newHandler(t)
+    recorder := serve(h, "POST", "/v1/explain", `{"host": "domain.com", "path": "/article/1", "method": "PUT", "roles": ["reader"]}`)
+    assert.Equal(t, 200, recorder.Code)
+    var explanation Explanation
+    assert.Equal(t, nil, jsoniter.Unmarshal(recorder.Body.Bytes(), &explanation))
+    assert.Equal(t, "ungranted", explanation.State)
+    assert.Equal(t, 1, explanation.RuleID)
+    assert.Equal(t, "/article/*", explanation.Rule.Path)
+    assert.Equal(t, 2, len(explanation.Matched))
+    assert.Equal(t, h.controller.Version(), explanation.Version)
+}
+
+func TestHandler_Policy(t *testing.T) {
+    h := newHandler(t)
+    recorder := serve(h, "GET", "/v1/policy", "")
+    assert.Equal(t, 200, recorder.Code)
+    var policy Policy
+    assert.Equal(t, nil, jsoniter.Unmarshal(recorder.Body.Bytes(), &policy))
+    assert.Equal(t, h.controller.Version(), policy.Version)
+    assert.Equal(t, 2, len(policy.Rules))
+    assert.Equal(t, []string{"editor"}, policy.Rules[1].AuthorizedRoles)
+
+    recorder = serve(h, "POST", "/v1/policy", "")
+    assert.Equal(t, 405, recorder.Code)
+    assert.Equal(t, "GET", recorder.Header().Get("Allow"))
+}
+
+func TestHandler_Health(t *testing.T) {
+    h := newHandler(t)
+    recorder := serve(h, "GET", "/healthz", "")
+    assert.Equal(t, 200, recorder.Code)
+    var health Health
+    assert.Equal(t, nil, jsoniter.Unmarshal(recorder.Body.Bytes(), &health))
+    assert.Equal(t, "ok", health.Status)
+    assert.Equal(t, 2, health.Rules)
+    assert.Equal(t, "", health.LastError)
+    assert.False(t, health.LastSuccess.IsZero())
+}
@@ -0,0 +1,176 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grbac
+
+import (
+    "github.com/storyicon/grbac/pkg/meta"
+    "github.com/storyicon/grbac/pkg/probe"
+    "github.com/storyicon/grbac/pkg/tree"
+)
+
+// ResourceAccess defines the effective access of roles to the resource of a rule
+type ResourceAccess struct {
+    // Resource is the resource pattern of the rule
+    Resource *Resource
+    // RuleID is the ID of the rule deciding the access to the resource,
+    // which differs from the ID of the rule when the rule is overridden by a rule with a higher ID,
+    // and is -1 when no rule matches the sample request of the resource.
+    RuleID int
+    // State is the access to the resource
+    State PermissionState
+    // Exceptions are the resources inside Resource whose access differs from State,
+    // because they are overridden by rules with higher IDs.
+    Exceptions []*Resource
+}
+
+// IsPartial is used to determine whether the access to the resource is partially overridden
+func (access *ResourceAccess) IsPartial() bool {
+    return len(access.Exceptions) > 0
+}
+
+// getRules is used to get the current rules
+func (c *Controller) getRules() Rules {
+    c.rulesLock.RLock()
+    defer c.rulesLock.RUnlock()
+    return c.rules
+}
+
+// decide is used to find the decisive rule of a sample request of the resource in the tree,
+// and the access of the roles to it.
+func decide(t *tree.Tree, resource *Resource, roles []string) (*Rule, PermissionState, error) {
+    query, err := probe.Query(resource)
+    if err != nil {
+        return nil, meta.PermissionUnknown, err
+    }
+    rules, err := findRules(t, query)
+    if err != nil {
+        return nil, meta.PermissionUnknown, err
+    }
+    state, err := rules.IsRolesGranted(roles)
+    if err != nil {
+        return nil, meta.PermissionUnknown, err
+    }
+    return rules.GetDecisiveRule(), state, nil
+}
+
+// ResourcesFor is used to list the effective access of the roles to the resources of all rules.
+// The access to a resource is decided the way IsQueryGranted does, by the matched rule with the highest ID,
+// and the more specific rules that give a different access inside a wildcard resource are reported as its exceptions.
+// Use State.IsGranted() to pick the allowed resources.
+func (c *Controller) ResourcesFor(roles []string) ([]*ResourceAccess, error) {
+    rules, _, t := c.snapshot()
+    accesses := make([]*ResourceAccess, 0, len(rules))
+    for _, rule := range rules {
+        decisive, state, err := decide(t, rule.Resource, roles)
+        if err != nil {
+            return nil, err
+        }
+        access := &ResourceAccess{
+            Resource: rule.Resource,
+            RuleID:   -1,
+            State:    state,
+        }
+        if decisive != nil {
+            access.RuleID = decisive.ID
+        }
+        for _, other := range rules {
+            if other == rule || other.ID < rule.ID {
+                continue
+            }
+            query, err := probe.Query(other.Resource)
+            if err != nil {
+                return nil, err
+            }
+            inside, err := rule.Match(query)
+            if err != nil {
+                return nil, err
+            }
+            if !inside {
+                continue
+            }
+            _, otherState, err := decide(t, other.Resource, roles)
+            if err != nil {
+                return nil, err
+            }
+            if otherState != state {
+                access.Exceptions = append(access.Exceptions, other.Resource)
+            }
+        }
+        accesses = append(accesses, access)
+    }
+    return accesses, nil
+}
+
+// RoleAccess defines the roles that can access a query
+type RoleAccess struct {
+    // Rule is the rule deciding the access, nil when no rule matches the query
+    Rule *Rule
+    // Matched are all the rules matching the query
+    Matched Rules
+    // AuthorizedRoles and ForbiddenRoles are the explicit roles of the decisive rule, * excluded
+    AuthorizedRoles []string
+    ForbiddenRoles  []string
+    // AnyRole is true when the decisive rule authorizes any role(*),
+    // requests with at least one role which is not forbidden are granted.
+    AnyRole bool
+    // AnyoneForbidden is true when the decisive rule forbids any role(*)
+    AnyoneForbidden bool
+    // AllowAnyone is true when the decisive rule allows anyone, including the requests without roles
+    AllowAnyone bool
+}
+
+// IsPublic is used to determine whether the query is effectively public,
+// which means it is granted to anyone, or to any request with a role that is not forbidden.
+func (access *RoleAccess) IsPublic() bool {
+    return access.AllowAnyone || (access.AnyRole && !access.AnyoneForbidden)
+}
+
+// IsNeglected is used to determine whether no rule matches the query
+func (access *RoleAccess) IsNeglected() bool {
+    return access.Rule == nil
+}
+
+// RolesFor is used to list the roles that can access the query.
+// All the rules matching the query are collected, and the one with the highest ID decides,
+// like IsQueryGranted does.
+func (c *Controller) RolesFor(q *Query) (*RoleAccess, error) {
+    rules, err := c.find(q)
+    if err != nil {
+        return nil, err
+    }
+    access := &RoleAccess{
+        Rule:    rules.GetDecisiveRule(),
+        Matched: rules,
+    }
+    if access.Rule == nil || access.Rule.Permission == nil {
+        return access, nil
+    }
+    access.AllowAnyone = access.Rule.AllowAnyone
+    for _, role := range access.Rule.AuthorizedRoles {
+        if role == "*" {
+            access.AnyRole = true
+            continue
+        }
+        access.AuthorizedRoles = append(access.AuthorizedRoles, role)
+    }
+    for _, role := range access.Rule.ForbiddenRoles {
+        if role == "*" {
+            access.AnyoneForbidden = true
+            continue
+        }
+        access.ForbiddenRoles = append(access.ForbiddenRoles, role)
+    }
+    return access, nil
+}
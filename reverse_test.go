@@ -0,0 +1,130 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grbac
+
+import (
+    "sync/atomic"
+    "testing"
+
+    "github.com/storyicon/grbac/pkg/meta"
+    "github.com/stretchr/testify/assert"
+)
+
+var reverseRules = Rules{
+    {ID: 0, Resource: &Resource{Host: `*`, Path: `**`, Method: `*`}, Permission: &Permission{AuthorizedRoles: []string{"*"}, ForbiddenRoles: []string{"black_user"}}},
+    {ID: 1, Resource: &Resource{Host: `domain.com`, Path: `/article/*`, Method: `{DELETE,PUT}`}, Permission: &Permission{AuthorizedRoles: []string{"editor"}}},
+    {ID: 2, Resource: &Resource{Host: `domain.com`, Path: `/article/draft`, Method: `PUT`}, Permission: &Permission{AuthorizedRoles: []string{"editor"}, ForbiddenRoles: []string{"intern"}}},
+    {ID: 3, Resource: &Resource{Host: `*`, Path: `/login`, Method: `POST`}, Permission: &Permission{AllowAnyone: true}},
+}
+
+func TestController_ResourcesFor(t *testing.T) {
+    c, err := New(WithRules(reverseRules))
+    assert.Equal(t, nil, err)
+
+    accesses, err := c.ResourcesFor([]string{"editor"})
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []*ResourceAccess{
+        {Resource: reverseRules[0].Resource, RuleID: 0, State: meta.PermissionGranted},
+        {Resource: reverseRules[1].Resource, RuleID: 1, State: meta.PermissionGranted},
+        {Resource: reverseRules[2].Resource, RuleID: 2, State: meta.PermissionGranted},
+        {Resource: reverseRules[3].Resource, RuleID: 3, State: meta.PermissionGranted},
+    }, accesses)
+
+    accesses, err = c.ResourcesFor([]string{"intern"})
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []*ResourceAccess{
+        {Resource: reverseRules[0].Resource, RuleID: 0, State: meta.PermissionGranted, Exceptions: []*Resource{reverseRules[1].Resource, reverseRules[2].Resource}},
+        {Resource: reverseRules[1].Resource, RuleID: 1, State: meta.PermissionUngranted},
+        {Resource: reverseRules[2].Resource, RuleID: 2, State: meta.PermissionUngranted},
+        {Resource: reverseRules[3].Resource, RuleID: 3, State: meta.PermissionGranted},
+    }, accesses)
+    assert.True(t, accesses[0].IsPartial())
+    assert.False(t, accesses[1].IsPartial())
+
+    accesses, err = c.ResourcesFor(nil)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, meta.PermissionUngranted, accesses[0].State)
+    assert.Equal(t, []*Resource{reverseRules[3].Resource}, accesses[0].Exceptions)
+}
+
+func TestController_ResourcesForReload(t *testing.T) {
+    // the rules are reloaded alternately with and without the rules 1 and 2,
+    // ResourcesFor must never see the rules of one set and the tree of the other
+    var count int32
+    c, err := New(WithLoader(func() (Rules, error) {
+        if atomic.AddInt32(&count, 1)%2 == 0 {
+            return Rules{reverseRules[0], reverseRules[3]}, nil
+        }
+        return reverseRules, nil
+    }, -1))
+    assert.Equal(t, nil, err)
+
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        for i := 0; i < 1000; i++ {
+            c.reload()
+        }
+    }()
+    for {
+        select {
+        case <-done:
+            return
+        default:
+        }
+        accesses, err := c.ResourcesFor([]string{"editor"})
+        assert.Equal(t, nil, err)
+        for _, access := range accesses {
+            assert.NotEqual(t, -1, access.RuleID)
+        }
+    }
+}
+
+func TestController_RolesFor(t *testing.T) {
+    c, err := New(WithRules(reverseRules))
+    assert.Equal(t, nil, err)
+
+    tests := []struct {
+        name            string
+        query           *Query
+        ruleID          int
+        matched         int
+        authorizedRoles []string
+        forbiddenRoles  []string
+        public          bool
+    }{
+        {name: "test0", query: &Query{Host: "domain.com", Path: "/article/1", Method: "DELETE"}, ruleID: 1, matched: 2, authorizedRoles: []string{"editor"}},
+        {name: "test1", query: &Query{Host: "domain.com", Path: "/article/draft", Method: "PUT"}, ruleID: 2, matched: 3, authorizedRoles: []string{"editor"}, forbiddenRoles: []string{"intern"}},
+        {name: "test2", query: &Query{Host: "domain.com", Path: "/article/1", Method: "GET"}, ruleID: 0, matched: 1, forbiddenRoles: []string{"black_user"}, public: true},
+        {name: "test3", query: &Query{Host: "x.com", Path: "/login", Method: "POST"}, ruleID: 3, matched: 2, public: true},
+    }
+    for _, tt := range tests {
+        access, err := c.RolesFor(tt.query)
+        assert.Equal(t, nil, err, tt.name)
+        assert.Equal(t, tt.ruleID, access.Rule.ID, tt.name)
+        assert.Equal(t, tt.matched, len(access.Matched), tt.name)
+        assert.Equal(t, tt.authorizedRoles, access.AuthorizedRoles, tt.name)
+        assert.Equal(t, tt.forbiddenRoles, access.ForbiddenRoles, tt.name)
+        assert.Equal(t, tt.public, access.IsPublic(), tt.name)
+        assert.False(t, access.IsNeglected(), tt.name)
+    }
+
+    c, err = New(WithRules(reverseRules[1:]))
+    assert.Equal(t, nil, err)
+    access, err := c.RolesFor(&Query{Host: "domain.com", Path: "/", Method: "GET"})
+    assert.Equal(t, nil, err)
+    assert.True(t, access.IsNeglected())
+    assert.False(t, access.IsPublic())
+}
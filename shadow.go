@@ -0,0 +1,138 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grbac
+
+import (
+    "github.com/storyicon/grbac/pkg/logging"
+)
+
+// Mismatch defines a query whose shadow decision differs from the enforced one
+type Mismatch struct {
+    Query *Query
+    Roles []string
+    // Primary is the enforced decision
+    Primary *Decision
+    // Shadow is the decision of the shadow rules
+    Shadow *Decision
+}
+
+// MismatchHandler is called when the shadow decision of a query differs from the enforced one
+type MismatchHandler func(mismatch *Mismatch)
+
+// shadow defines the candidate rules evaluated alongside the enforced ones
+type shadow struct {
+    options    ControllerOption
+    controller *Controller
+    handler    MismatchHandler
+}
+
+// WithShadow is used to evaluate every query against the rules of a shadow loader as well,
+// the shadow loader is configured like the primary one, such as WithYAML("rules.next.yaml", time.Minute).
+// Only the primary decision is enforced, the handler is called synchronously
+// with both decisions when their states differ, so it should return quickly.
+// The shadow rules are logged by the logger of the primary controller, a failure to load them
+// does not fail New, it is logged and the loading is retried at the interval of the shadow loader,
+// or every 5 seconds when the loader itself can not be built, such as WithYAML with a missing file.
+// The queries are not compared until the shadow rules are loaded.
+func WithShadow(loaderOptions ControllerOption, handler MismatchHandler) ControllerOption {
+    return func(c *Controller) error {
+        c.shadow = &shadow{
+            options: loaderOptions,
+            handler: handler,
+        }
+        return nil
+    }
+}
+
+// start is used to build the shadow controller once the primary rules are loaded.
+// When the loader options fail, such as WithYAML with a missing file, they are applied again
+// by the loader of the shadow controller, which is retried at the default interval.
+func (s *shadow) start(logger Logger) error {
+    c := &Controller{
+        logger: logger,
+    }
+    err := s.options(c)
+    if err == ErrUndefinedLoader {
+        return err
+    }
+    if err != nil {
+        logger.Warn("grbac failed to build the shadow loader, the queries are not compared until it is built",
+            logging.Err(err),
+        )
+        c.loader = s.retry(c)
+    } else if c.loader == nil {
+        return ErrUndefinedLoader
+    } else if err := c.reload(); err != nil {
+        logger.Warn("grbac failed to load the shadow rules, the queries are not compared until they are loaded",
+            logging.Err(err),
+        )
+    }
+    go c.runCronTab()
+    s.controller = c
+    return nil
+}
+
+// retry is used to create a loader applying the loader options again,
+// which replaces itself with the loader of the options once they succeed
+func (s *shadow) retry(c *Controller) func() (Rules, error) {
+    return func() (Rules, error) {
+        built := &Controller{}
+        if err := s.options(built); err != nil {
+            return nil, err
+        }
+        if built.loader == nil {
+            return nil, ErrUndefinedLoader
+        }
+        // the loader is only called by the goroutine reloading the shadow rules
+        c.loader = built.loader
+        return built.loader()
+    }
+}
+
+// compare is used to evaluate the query against the shadow rules and report the mismatch
+func (s *shadow) compare(c *Controller, q *Query, roles []string, primary *Decision) {
+    if s.controller.getTree() == nil {
+        return
+    }
+    decision, err := s.controller.Explain(q, roles)
+    if err != nil {
+        c.logger.Error("grbac failed to evaluate the shadow rules",
+            logging.Err(err),
+            logging.Any("host", q.Host),
+            logging.Any("path", q.Path),
+            logging.Any("method", q.Method),
+        )
+        return
+    }
+    if decision.State == primary.State || s.handler == nil {
+        return
+    }
+    defer func() {
+        if r := recover(); r != nil {
+            c.logger.Error("grbac recovered from a panic of the mismatch handler",
+                logging.Any("panic", r),
+                logging.Any("host", q.Host),
+                logging.Any("path", q.Path),
+                logging.Any("method", q.Method),
+            )
+        }
+    }()
+    s.handler(&Mismatch{
+        Query:   q,
+        Roles:   roles,
+        Primary: primary,
+        Shadow:  decision,
+    })
+}
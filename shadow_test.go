@@ -0,0 +1,152 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grbac
+
+import (
+    "bytes"
+    "errors"
+    "io/ioutil"
+    "log"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "github.com/storyicon/grbac/pkg/logging"
+    "github.com/storyicon/grbac/pkg/meta"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestWithShadow(t *testing.T) {
+    candidate := append(Rules{}, reverseRules...)
+    candidate = append(candidate, &Rule{
+        ID:         4,
+        Resource:   &Resource{Host: "domain.com", Path: "/article/*", Method: "DELETE"},
+        Permission: &Permission{AuthorizedRoles: []string{"admin"}},
+    })
+    var mismatches []*Mismatch
+    c, err := New(WithRules(reverseRules), WithShadow(WithRules(candidate), func(mismatch *Mismatch) {
+        mismatches = append(mismatches, mismatch)
+    }))
+    assert.Equal(t, nil, err)
+
+    state, err := c.IsRequestGranted(httptest.NewRequest("DELETE", "http://domain.com/article/1", nil), []string{"editor"})
+    assert.Equal(t, nil, err)
+    assert.Equal(t, meta.PermissionGranted, state)
+    assert.Equal(t, 1, len(mismatches))
+    assert.Equal(t, &Query{Host: "domain.com", Path: "/article/1", Method: "DELETE"}, mismatches[0].Query)
+    assert.Equal(t, []string{"editor"}, mismatches[0].Roles)
+    assert.Equal(t, 1, mismatches[0].Primary.RuleID())
+    assert.Equal(t, meta.PermissionGranted, mismatches[0].Primary.State)
+    assert.Equal(t, 4, mismatches[0].Shadow.RuleID())
+    assert.Equal(t, meta.PermissionUngranted, mismatches[0].Shadow.State)
+
+    state, err = c.IsQueryGranted(&Query{Host: "domain.com", Path: "/article/1", Method: "PUT"}, []string{"editor"})
+    assert.Equal(t, nil, err)
+    assert.Equal(t, meta.PermissionGranted, state)
+    assert.Equal(t, 1, len(mismatches))
+
+    _, err = New(WithRules(reverseRules), WithShadow(WithLoader(nil, 0), nil))
+    assert.Equal(t, ErrUndefinedLoader, err)
+}
+
+func TestWithShadowFailure(t *testing.T) {
+    buffer := &bytes.Buffer{}
+    logger := logging.NewStd(log.New(buffer, "", 0), logging.LevelDebug)
+    fail := true
+    candidate := Rules{
+        {
+            ID:         0,
+            Resource:   &Resource{Host: "*", Path: "**", Method: "*"},
+            Permission: &Permission{AllowAnyone: true},
+        },
+    }
+    var mismatches int
+    c, err := New(WithRules(reverseRules), WithShadow(WithLoader(func() (Rules, error) {
+        if fail {
+            return nil, errors.New("connection refused")
+        }
+        return candidate, nil
+    }, -1), func(mismatch *Mismatch) {
+        mismatches++
+        panic("handler failure")
+    }), WithLogger(logger))
+    assert.Equal(t, nil, err)
+    assert.Contains(t, buffer.String(), `level=error msg="grbac failed to load the rules" error="connection refused"`)
+    assert.Contains(t, buffer.String(), `level=warn msg="grbac failed to load the shadow rules, the queries are not compared until they are loaded" error="connection refused"`)
+
+    query := &Query{Host: "domain.com", Path: "/article/1", Method: "DELETE"}
+    state, err := c.IsQueryGranted(query, nil)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, meta.PermissionUngranted, state)
+    assert.Equal(t, 0, mismatches)
+
+    fail = false
+    assert.Equal(t, nil, c.shadow.controller.reload())
+    state, err = c.IsQueryGranted(query, nil)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, meta.PermissionUngranted, state)
+    assert.Equal(t, 1, mismatches)
+    assert.Contains(t, buffer.String(), `level=error msg="grbac recovered from a panic of the mismatch handler" panic="handler failure"`)
+}
+
+func TestWithShadowFile(t *testing.T) {
+    dir, err := ioutil.TempDir("", "shadow")
+    assert.Equal(t, nil, err)
+    defer os.RemoveAll(dir)
+    name := filepath.Join(dir, "rules.next.yaml")
+
+    buffer := &bytes.Buffer{}
+    logger := logging.NewStd(log.New(buffer, "", 0), logging.LevelDebug)
+    var mismatches int
+    shadow := WithShadow(WithYAML(name, time.Minute), func(mismatch *Mismatch) {
+        mismatches++
+    })
+    c, err := New(WithRules(reverseRules), shadow, WithLogger(logger))
+    assert.Equal(t, nil, err)
+    assert.Contains(t, buffer.String(), `level=warn msg="grbac failed to build the shadow loader, the queries are not compared until it is built"`)
+
+    query := &Query{Host: "domain.com", Path: "/article/1", Method: "DELETE"}
+    _, err = c.IsQueryGranted(query, []string{"editor"})
+    assert.Equal(t, nil, err)
+    assert.Equal(t, 0, mismatches)
+
+    // a broken file is still retried
+    assert.Equal(t, nil, ioutil.WriteFile(name, []byte("- id: [\n"), 0644))
+    assert.NotEqual(t, nil, c.shadow.controller.reload())
+    _, err = c.IsQueryGranted(query, []string{"editor"})
+    assert.Equal(t, nil, err)
+    assert.Equal(t, 0, mismatches)
+
+    data := `
+- id: 0
+  host: "*"
+  path: "**"
+  method: "*"
+  authorized_roles: [admin]
+`
+    assert.Equal(t, nil, ioutil.WriteFile(name, []byte(data), 0644))
+    assert.Equal(t, nil, c.shadow.controller.reload())
+    _, err = c.IsQueryGranted(query, []string{"editor"})
+    assert.Equal(t, nil, err)
+    assert.Equal(t, 1, mismatches)
+
+    // the shadow is not started when the primary rules fail to load
+    _, err = New(WithLoader(func() (Rules, error) {
+        return nil, errors.New("connection refused")
+    }, -1), shadow, WithLogger(logger))
+    assert.NotEqual(t, nil, err)
+}
@@ -0,0 +1,62 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grbac
+
+import (
+    "time"
+)
+
+// Status defines the loading status of the rules
+type Status struct {
+    // Version is the version of the rules in effect
+    Version string
+    // Rules is the number of rules in effect
+    Rules int
+    // LastAttempt is the time of the last loading
+    LastAttempt time.Time
+    // LastSuccess is the time of the last successful loading
+    LastSuccess time.Time
+    // LastError is the error of the last loading, nil when it succeeded.
+    // The rules of the last successful loading stay in effect when it fails.
+    LastError error
+}
+
+// setStatus is used to record the result of a loading
+func (c *Controller) setStatus(start time.Time, err error) {
+    c.statusLock.Lock()
+    defer c.statusLock.Unlock()
+    c.status.LastAttempt = start
+    c.status.LastError = err
+    if err == nil {
+        c.status.LastSuccess = start
+    }
+}
+
+// Status is used to get the loading status of the rules
+func (c *Controller) Status() Status {
+    c.statusLock.RLock()
+    status := c.status
+    c.statusLock.RUnlock()
+    c.rulesLock.RLock()
+    status.Version = c.version
+    status.Rules = len(c.rules)
+    c.rulesLock.RUnlock()
+    return status
+}
+
+// Rules is used to get a copy of the rules in effect
+func (c *Controller) Rules() Rules {
+    return append(Rules(nil), c.getRules()...)
+}
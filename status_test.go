@@ -0,0 +1,47 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grbac
+
+import (
+    "errors"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestController_Status(t *testing.T) {
+    fail := false
+    c, err := New(WithLoader(func() (Rules, error) {
+        if fail {
+            return nil, errors.New("connection refused")
+        }
+        return reverseRules, nil
+    }, -1))
+    assert.Equal(t, nil, err)
+
+    status := c.Status()
+    assert.Equal(t, c.Version(), status.Version)
+    assert.Equal(t, 4, status.Rules)
+    assert.Equal(t, nil, status.LastError)
+    assert.Equal(t, status.LastAttempt, status.LastSuccess)
+    assert.Equal(t, reverseRules, c.Rules())
+
+    fail = true
+    assert.NotEqual(t, nil, c.reload())
+    status = c.Status()
+    assert.Equal(t, 4, status.Rules)
+    assert.Equal(t, "connection refused", status.LastError.Error())
+    assert.True(t, status.LastAttempt.After(status.LastSuccess))
+}
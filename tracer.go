@@ -0,0 +1,96 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grbac
+
+import (
+    "context"
+
+    "github.com/storyicon/grbac/pkg/tree"
+)
+
+// define the names of the spans
+const (
+    SpanQuery  = "grbac.IsQueryGranted"
+    SpanLookup = "grbac.lookup"
+    SpanReload = "grbac.reload"
+)
+
+// define the keys of the attributes
+const (
+    AttributeHost       = "grbac.host"
+    AttributePath       = "grbac.path"
+    AttributeMethod     = "grbac.method"
+    AttributeDecision   = "grbac.decision"
+    AttributeRuleID     = "grbac.rule_id"
+    AttributeCandidates = "grbac.candidates"
+    AttributeVersion    = "grbac.version"
+    AttributeRules      = "grbac.rules"
+)
+
+// Attribute defines an attribute of a span, the value is a string, an int or a bool
+type Attribute struct {
+    Key   string
+    Value interface{}
+}
+
+// Span defines a traced operation
+type Span interface {
+    SetAttributes(attributes ...Attribute)
+    RecordError(err error)
+    End()
+}
+
+// Tracer starts the spans of the controller.
+// It mirrors the tracer of OpenTelemetry, so an adapter only has to convert the attributes:
+//  func (t otelTracer) Start(ctx context.Context, name string) (context.Context, grbac.Span) {
+//      ctx, span := t.tracer.Start(ctx, name)
+//      return ctx, otelSpan{span}
+//  }
+type Tracer interface {
+    Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// WithTracer is used to trace the evaluation of the queries, the tree lookup and the loading of the rules
+func WithTracer(tracer Tracer) ControllerOption {
+    return func(c *Controller) error {
+        c.tracer = tracer
+        return nil
+    }
+}
+
+// queryAttributes is used to get the attributes of a query
+func queryAttributes(q *Query) []Attribute {
+    return []Attribute{
+        {Key: AttributeHost, Value: q.Host},
+        {Key: AttributePath, Value: q.Path},
+        {Key: AttributeMethod, Value: q.Method},
+    }
+}
+
+// lookup is used to find the rules matching the query in the tree
+func (c *Controller) lookup(ctx context.Context, t *tree.Tree, q *Query) (Rules, error) {
+    if c.tracer == nil {
+        return findRules(t, q)
+    }
+    _, span := c.tracer.Start(ctx, SpanLookup)
+    defer span.End()
+    rules, err := findRules(t, q)
+    if err != nil {
+        span.RecordError(err)
+        return nil, err
+    }
+    span.SetAttributes(Attribute{Key: AttributeCandidates, Value: len(rules)})
+    return rules, nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grbac
+
+import (
+    "context"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+type parentKey struct{}
+
+type recordedSpan struct {
+    name       string
+    parent     string
+    attributes map[string]interface{}
+    errors     []error
+    ended      bool
+}
+
+func (s *recordedSpan) SetAttributes(attributes ...Attribute) {
+    for _, attribute := range attributes {
+        s.attributes[attribute.Key] = attribute.Value
+    }
+}
+
+func (s *recordedSpan) RecordError(err error) {
+    s.errors = append(s.errors, err)
+}
+
+func (s *recordedSpan) End() {
+    s.ended = true
+}
+
+type recordingTracer struct {
+    spans   []*recordedSpan
+    onStart func(name string)
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+    if t.onStart != nil {
+        t.onStart(name)
+    }
+    parent, _ := ctx.Value(parentKey{}).(string)
+    span := &recordedSpan{name: name, parent: parent, attributes: map[string]interface{}{}}
+    t.spans = append(t.spans, span)
+    return context.WithValue(ctx, parentKey{}, name), span
+}
+
+func TestWithTracer(t *testing.T) {
+    tracer := &recordingTracer{}
+    c, err := New(WithRules(reverseRules), WithTracer(tracer))
+    assert.Equal(t, nil, err)
+    assert.Equal(t, 1, len(tracer.spans))
+    assert.Equal(t, SpanReload, tracer.spans[0].name)
+    assert.Equal(t, 4, tracer.spans[0].attributes[AttributeRules])
+    assert.Equal(t, c.Version(), tracer.spans[0].attributes[AttributeVersion])
+    assert.True(t, tracer.spans[0].ended)
+
+    ctx := context.WithValue(context.Background(), parentKey{}, "http")
+    r := httptest.NewRequest("PUT", "http://domain.com/article/draft", nil).WithContext(ctx)
+    state, err := c.IsRequestGranted(r, []string{"intern"})
+    assert.Equal(t, nil, err)
+    assert.False(t, state.IsGranted())
+
+    assert.Equal(t, 3, len(tracer.spans))
+    query, lookup := tracer.spans[1], tracer.spans[2]
+    assert.Equal(t, SpanQuery, query.name)
+    assert.Equal(t, "http", query.parent)
+    assert.Equal(t, map[string]interface{}{
+        AttributeHost:       "domain.com",
+        AttributePath:       "/article/draft",
+        AttributeMethod:     "PUT",
+        AttributeDecision:   "ungranted",
+        AttributeRuleID:     2,
+        AttributeCandidates: 3,
+        AttributeVersion:    c.Version(),
+    }, query.attributes)
+    assert.True(t, query.ended)
+    assert.Equal(t, SpanLookup, lookup.name)
+    assert.Equal(t, SpanQuery, lookup.parent)
+    assert.Equal(t, 3, lookup.attributes[AttributeCandidates])
+    assert.True(t, lookup.ended)
+}
+
+func TestWithTracerReload(t *testing.T) {
+    sets := []Rules{reverseRules, reverseRules[:2]}
+    loads := 0
+    tracer := &recordingTracer{}
+    c, err := New(WithLoader(func() (Rules, error) {
+        loads++
+        return sets[loads%2], nil
+    }, -1), WithTracer(tracer))
+    assert.Equal(t, nil, err)
+    version := c.Version()
+
+    // the rules are reloaded between the lookup and the attributes of the query span
+    tracer.onStart = func(name string) {
+        if name == SpanLookup {
+            c.reload()
+        }
+    }
+    decision, err := c.Decide(context.Background(), &Query{Host: "domain.com", Path: "/article/draft", Method: "PUT"}, []string{"editor"})
+    assert.Equal(t, nil, err)
+    assert.NotEqual(t, version, c.Version())
+    assert.Equal(t, 1, decision.RuleID())
+    assert.Equal(t, version, decision.Version)
+    for _, span := range tracer.spans {
+        if span.name == SpanQuery {
+            assert.Equal(t, version, span.attributes[AttributeVersion])
+            assert.Equal(t, 1, span.attributes[AttributeRuleID])
+        }
+    }
+}